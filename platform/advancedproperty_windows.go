@@ -0,0 +1,228 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+)
+
+// advancedProperty is an advanced property of a network adapter, as reported by Get-NetAdapterAdvancedProperty
+type advancedProperty struct {
+	RegistryKeyword          string
+	RegistryValue            []string
+	DisplayValue             string
+	ValidRegistryValues      []string
+	ValidDisplayValues       []string
+	NumericParameterMinValue *int
+	NumericParameterMaxValue *int
+}
+
+// value returns the registry value of the property
+func (p *advancedProperty) value() string {
+	if len(p.RegistryValue) == 0 {
+		return ""
+	}
+
+	return p.RegistryValue[0]
+}
+
+// validate returns an error if registryValue is not a value supported by the property
+func (p *advancedProperty) validate(registryValue string) error {
+	if len(p.ValidRegistryValues) > 0 {
+		for _, v := range p.ValidRegistryValues {
+			if v == registryValue {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("value %s of %s is not one of the supported values %v", registryValue, p.RegistryKeyword, p.ValidRegistryValues)
+	}
+
+	if p.NumericParameterMinValue != nil && p.NumericParameterMaxValue != nil {
+		v, err := strconv.Atoi(registryValue)
+		if err != nil || v < *p.NumericParameterMinValue || v > *p.NumericParameterMaxValue {
+			return fmt.Errorf("value %s of %s is not in the supported range [%d, %d]",
+				registryValue, p.RegistryKeyword, *p.NumericParameterMinValue, *p.NumericParameterMaxValue)
+		}
+	}
+
+	return nil
+}
+
+// registryValueOf returns the registry value of the display value of the property, case-insensitively.
+// Returns an error if displayValue is not one of the values displayed for the property.
+func (p *advancedProperty) registryValueOf(displayValue string) (string, error) {
+	for i, v := range p.ValidDisplayValues {
+		if strings.EqualFold(v, displayValue) && i < len(p.ValidRegistryValues) {
+			return p.ValidRegistryValues[i], nil
+		}
+	}
+
+	return "", fmt.Errorf("value %q of %s is not one of the supported values %q", displayValue, p.RegistryKeyword, p.ValidDisplayValues)
+}
+
+// largestSupportedValue returns the largest numeric value supported by the property not greater than limit.
+// limit itself is returned if the property doesn't restrict its values.
+func (p *advancedProperty) largestSupportedValue(limit int) (string, error) {
+	if len(p.ValidRegistryValues) > 0 {
+		largest := -1
+		for _, v := range p.ValidRegistryValues {
+			if n, err := strconv.Atoi(v); err == nil && n <= limit && n > largest {
+				largest = n
+			}
+		}
+
+		if largest < 0 {
+			return "", fmt.Errorf("none of the supported values %v of %s is at most %d", p.ValidRegistryValues, p.RegistryKeyword, limit)
+		}
+
+		return strconv.Itoa(largest), nil
+	}
+
+	if p.NumericParameterMinValue != nil && p.NumericParameterMaxValue != nil {
+		if limit < *p.NumericParameterMinValue {
+			return "", fmt.Errorf("minimum value %d of %s is greater than %d", *p.NumericParameterMinValue, p.RegistryKeyword, limit)
+		}
+
+		if limit > *p.NumericParameterMaxValue {
+			limit = *p.NumericParameterMaxValue
+		}
+	}
+
+	return strconv.Itoa(limit), nil
+}
+
+// getAdvancedProperty returns the advanced property of the adapter with the given registry keyword.
+// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if the adapter has no such property.
+func getAdvancedProperty(execClient ExecClient, adapterName, keyword string) (advancedProperty, error) {
+	properties, err := getAdvancedProperties(execClient, adapterName, keyword)
+	if err != nil {
+		return advancedProperty{}, err
+	}
+
+	property, ok := properties[keyword]
+	if !ok {
+		return advancedProperty{}, fmt.Errorf("advanced property %s of %s: %w", keyword, adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	return property, nil
+}
+
+// getAdvancedProperties returns the advanced properties of the adapter with the given registry keywords,
+// by keyword. The properties the adapter does not have are missing from the result.
+// Returns ErrAdapterNotFound if there is no such adapter.
+func getAdvancedProperties(execClient ExecClient, adapterName string, keywords ...string) (map[string]advancedProperty, error) {
+	na := &networkAdapter{execClient: execClient}
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return nil, err
+	}
+
+	cmd := fmt.Sprintf("Get-NetAdapterAdvancedProperty -Name '%s' -RegistryKeyword %s -ErrorAction SilentlyContinue | "+
+		"Select-Object RegistryKeyword, RegistryValue, DisplayValue, ValidRegistryValues, ValidDisplayValues, "+
+		"NumericParameterMinValue, NumericParameterMaxValue", escapePowershellString(adapterName), powershellStringList(keywords))
+	list, err := ExecutePowershellJSON[[]advancedProperty](execClient, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get advanced properties %v of %s: %w", keywords, adapterName, err)
+	}
+
+	properties := make(map[string]advancedProperty, len(list))
+	for _, property := range list {
+		properties[property.RegistryKeyword] = property
+	}
+
+	return properties, nil
+}
+
+// getEnumAdvancedProperty returns the key of values mapped to the registry value of the advanced property
+// of the adapter with the given registry keyword, e.g. the mode of a setting with standardized registry values.
+// Returns ErrFeatureUnsupported if the adapter has no such property.
+func getEnumAdvancedProperty[T comparable](execClient ExecClient, adapterName, keyword string, values map[T]string) (T, error) {
+	property, err := getAdvancedProperty(execClient, adapterName, keyword)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return enumAdvancedPropertyValue(adapterName, property, values)
+}
+
+// enumAdvancedPropertyValue returns the key of values mapped to the registry value of the advanced property
+func enumAdvancedPropertyValue[T comparable](adapterName string, property advancedProperty, values map[T]string) (T, error) {
+	for v, registryValue := range values {
+		if registryValue == property.value() {
+			return v, nil
+		}
+	}
+
+	var zero T
+	return zero, fmt.Errorf("unknown %s value %q of %s", property.RegistryKeyword, property.value(), adapterName)
+}
+
+// setEnumAdvancedProperty sets the advanced property of the adapter with the given registry keyword
+// to the registry value v is mapped to by values, if it differs from its current value.
+// Returns ErrFeatureUnsupported if the adapter has no such property.
+func setEnumAdvancedProperty[T comparable](execClient ExecClient, adapterName, keyword string, values map[T]string, v T) error {
+	registryValue, ok := values[v]
+	if !ok {
+		return fmt.Errorf("invalid %s value %v", keyword, v)
+	}
+
+	return setAdvancedPropertyIfChanged(execClient, adapterName, keyword, registryValue)
+}
+
+// setAdvancedPropertyIfChanged sets the advanced property of the adapter with the given registry keyword
+// if registryValue is supported by the property and differs from its current value.
+// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if the adapter has no such property.
+func setAdvancedPropertyIfChanged(execClient ExecClient, adapterName, keyword, registryValue string) error {
+	property, err := getAdvancedProperty(execClient, adapterName, keyword)
+	if err != nil {
+		return err
+	}
+
+	if err = property.validate(registryValue); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", adapterName, err)
+	}
+
+	if property.value() == registryValue {
+		return nil
+	}
+
+	return setAdvancedProperty(execClient, adapterName, keyword, registryValue)
+}
+
+// setAdvancedProperty sets the advanced property of the adapter with the given registry keyword.
+// The property is expected to have been got first, which fails with ErrAdapterNotFound if there is no such adapter.
+func setAdvancedProperty(execClient ExecClient, adapterName, keyword, registryValue string) error {
+	cmd := fmt.Sprintf("Set-NetAdapterAdvancedProperty -Name '%s' -RegistryKeyword '%s' -RegistryValue '%s'",
+		escapePowershellString(adapterName), escapePowershellString(keyword), escapePowershellString(registryValue))
+	if _, err := execClient.ExecutePowershellCommand(cmd); err != nil {
+		return fmt.Errorf("failed to set advanced property %s of %s to %s: %w", keyword, adapterName, registryValue, err)
+	}
+
+	return nil
+}
+
+// ResetAdapterAdvancedProperties resets the advanced properties of the adapter with the given registry keywords
+// to their driver defaults, or all of its advanced properties if no keyword is given
+func ResetAdapterAdvancedProperties(execClient ExecClient, adapterName string, keywords ...string) error {
+	na := &networkAdapter{execClient: execClient}
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("Reset-NetAdapterAdvancedProperty -Name '%s' -DisplayName '*'", escapePowershellString(adapterName))
+	if len(keywords) > 0 {
+		cmd = fmt.Sprintf("Reset-NetAdapterAdvancedProperty -Name '%s' -RegistryKeyword %s", escapePowershellString(adapterName), powershellStringList(keywords))
+	}
+
+	if _, err := execClient.ExecutePowershellCommand(cmd); err != nil {
+		return fmt.Errorf("failed to reset advanced properties of %s: %w", adapterName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetAdapterAdvancedProperties(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	require.NoError(t, ResetAdapterAdvancedProperties(mockExecClient, "Ethernet 2"))
+	require.NoError(t, ResetAdapterAdvancedProperties(mockExecClient, "Ethernet 2", "*FlowControl", "*LsoV2IPv4"))
+	assert.Equal(t, []string{
+		GetAdapterNamesCommand,
+		"Reset-NetAdapterAdvancedProperty -Name 'Ethernet 2' -DisplayName '*'",
+		GetAdapterNamesCommand,
+		"Reset-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '*FlowControl', '*LsoV2IPv4'",
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestResetAdapterAdvancedPropertiesUnknownAdapter(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	require.Error(t, ResetAdapterAdvancedProperties(mockExecClient, "Ethernet 3"))
+	assert.Equal(t, []string{GetAdapterNamesCommand}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestAdvancedPropertiesUnknownAdapter(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	_, err := GetFlowControl(mockExecClient, "Ethernet 3")
+	require.ErrorIs(t, err, adapter.ErrAdapterNotFound)
+	_, err = GetOffloadSettings(mockExecClient, "Ethernet 3")
+	require.ErrorIs(t, err, adapter.ErrAdapterNotFound)
+	require.ErrorIs(t, SetFlowControl(mockExecClient, "Ethernet 3", FlowControlDisabled), adapter.ErrAdapterNotFound)
+	require.ErrorIs(t, SetTCPChecksumOffload(mockExecClient, "Ethernet 3", AfINET, OffloadDisabled), adapter.ErrAdapterNotFound)
+	require.ErrorIs(t, SetUDPChecksumOffload(mockExecClient, "Ethernet 3", AfINET6, OffloadRxTxEnabled), adapter.ErrAdapterNotFound)
+	require.ErrorIs(t, SetLSOv2(mockExecClient, "Ethernet 3", AfINET, OffloadEnabled), adapter.ErrAdapterNotFound)
+	require.ErrorIs(t, ConfigureRSSQueues(mockExecClient, "Ethernet 3", 4), adapter.ErrAdapterNotFound)
+
+	for _, cmd := range mockExecClient.RecordedPowershellCommands() {
+		assert.Equal(t, GetAdapterNamesCommand, cmd)
+	}
+}
+
+// advancedPropertySettings are the settings of an adapter backed by one of its advanced properties
+var advancedPropertySettings = []struct {
+	name    string
+	keyword string
+	// fixture is the advanced property of the adapter, as reported by Get-NetAdapterAdvancedProperty
+	fixture string
+	// get returns the setting, expected to be want. Nil for a write-only setting.
+	get  func(execClient ExecClient, adapterName string) (interface{}, error)
+	want interface{}
+	// set changes the setting, expected to set the advanced property to registryValue
+	set           func(execClient ExecClient, adapterName string) error
+	registryValue string
+	// setCurrent sets the setting to its current value, expected to set nothing
+	setCurrent func(execClient ExecClient, adapterName string) error
+	// setInvalid sets invalid values, each expected to fail without setting anything. Empty if no value is invalid.
+	setInvalid []func(execClient ExecClient, adapterName string) error
+}{
+	{
+		name:    "interrupt moderation",
+		keyword: "*InterruptModeration",
+		fixture: `{
+    "RegistryKeyword":  "*InterruptModeration",
+    "RegistryValue":  ["1"],
+    "DisplayValue":  "Enabled",
+    "ValidRegistryValues":  ["0", "1"],
+    "ValidDisplayValues":  ["Disabled", "Enabled"],
+    "NumericParameterMinValue":  null,
+    "NumericParameterMaxValue":  null
+}`,
+		get: func(execClient ExecClient, adapterName string) (interface{}, error) {
+			return GetInterruptModeration(execClient, adapterName)
+		},
+		want: InterruptModerationEnabled,
+		set: func(execClient ExecClient, adapterName string) error {
+			return SetInterruptModeration(execClient, adapterName, InterruptModerationDisabled)
+		},
+		registryValue: "0",
+		setCurrent: func(execClient ExecClient, adapterName string) error {
+			return SetInterruptModeration(execClient, adapterName, InterruptModerationEnabled)
+		},
+		setInvalid: []func(execClient ExecClient, adapterName string) error{
+			func(execClient ExecClient, adapterName string) error {
+				return SetInterruptModeration(execClient, adapterName, "Adaptive")
+			},
+		},
+	},
+	{
+		name:    "flow control",
+		keyword: "*FlowControl",
+		fixture: `{
+    "RegistryKeyword":  "*FlowControl",
+    "RegistryValue":  ["3"],
+    "DisplayValue":  "Rx & Tx Enabled",
+    "ValidRegistryValues":  ["0", "1", "2", "3"],
+    "ValidDisplayValues":  ["Disabled", "Tx Enabled", "Rx Enabled", "Rx & Tx Enabled"],
+    "NumericParameterMinValue":  null,
+    "NumericParameterMaxValue":  null
+}`,
+		get: func(execClient ExecClient, adapterName string) (interface{}, error) {
+			return GetFlowControl(execClient, adapterName)
+		},
+		want: FlowControlRxTxEnabled,
+		set: func(execClient ExecClient, adapterName string) error {
+			return SetFlowControl(execClient, adapterName, FlowControlDisabled)
+		},
+		registryValue: "0",
+		setCurrent: func(execClient ExecClient, adapterName string) error {
+			return SetFlowControl(execClient, adapterName, FlowControlRxTxEnabled)
+		},
+		setInvalid: []func(execClient ExecClient, adapterName string) error{
+			func(execClient ExecClient, adapterName string) error {
+				return SetFlowControl(execClient, adapterName, "Enabled")
+			},
+		},
+	},
+	{
+		name:    "speed and duplex",
+		keyword: "*SpeedDuplex",
+		fixture: `{
+    "RegistryKeyword":  "*SpeedDuplex",
+    "RegistryValue":  ["0"],
+    "DisplayValue":  "Auto Negotiation",
+    "ValidRegistryValues":  ["0", "4", "6", "7"],
+    "ValidDisplayValues":  ["Auto Negotiation", "100 Mbps Full Duplex", "1.0 Gbps Full Duplex", "10 Gbps Full Duplex"],
+    "NumericParameterMinValue":  null,
+    "NumericParameterMaxValue":  null
+}`,
+		get: func(execClient ExecClient, adapterName string) (interface{}, error) {
+			return GetSpeedDuplex(execClient, adapterName)
+		},
+		want: "Auto Negotiation",
+		set: func(execClient ExecClient, adapterName string) error {
+			// display values match case-insensitively
+			return SetSpeedDuplex(execClient, adapterName, "10 gbps full duplex")
+		},
+		registryValue: "7",
+		setCurrent: func(execClient ExecClient, adapterName string) error {
+			return SetSpeedDuplex(execClient, adapterName, "Auto Negotiation")
+		},
+		setInvalid: []func(execClient ExecClient, adapterName string) error{
+			func(execClient ExecClient, adapterName string) error {
+				return SetSpeedDuplex(execClient, adapterName, "25 Gbps Full Duplex")
+			},
+		},
+	},
+	{
+		name:    "VMMQ",
+		keyword: "*RssOnHostVPorts",
+		fixture: `{
+    "RegistryKeyword":  "*RssOnHostVPorts",
+    "RegistryValue":  ["0"],
+    "DisplayValue":  "Disabled",
+    "ValidRegistryValues":  ["0", "1"],
+    "ValidDisplayValues":  ["Disabled", "Enabled"],
+    "NumericParameterMinValue":  null,
+    "NumericParameterMaxValue":  null
+}`,
+		get: func(execClient ExecClient, adapterName string) (interface{}, error) {
+			return GetVMMQEnabled(execClient, adapterName)
+		},
+		want: false,
+		set: func(execClient ExecClient, adapterName string) error {
+			return SetVMMQEnabled(execClient, adapterName, true)
+		},
+		registryValue: "1",
+		setCurrent: func(execClient ExecClient, adapterName string) error {
+			return SetVMMQEnabled(execClient, adapterName, false)
+		},
+	},
+	{
+		name:    "VMMQ queue pairs",
+		keyword: "*NumQueuePairsForDefaultVPort",
+		fixture: `{
+    "RegistryKeyword":  "*NumQueuePairsForDefaultVPort",
+    "RegistryValue":  ["8"],
+    "DisplayValue":  "8",
+    "ValidRegistryValues":  null,
+    "ValidDisplayValues":  null,
+    "NumericParameterMinValue":  1,
+    "NumericParameterMaxValue":  16
+}`,
+		set: func(execClient ExecClient, adapterName string) error {
+			return SetVMMQQueuePairs(execClient, adapterName, 4)
+		},
+		registryValue: "4",
+		setCurrent: func(execClient ExecClient, adapterName string) error {
+			return SetVMMQQueuePairs(execClient, adapterName, 8)
+		},
+		setInvalid: []func(execClient ExecClient, adapterName string) error{
+			func(execClient ExecClient, adapterName string) error {
+				return SetVMMQQueuePairs(execClient, adapterName, 0)
+			},
+			func(execClient ExecClient, adapterName string) error {
+				return SetVMMQQueuePairs(execClient, adapterName, 32)
+			},
+		},
+	},
+}
+
+func TestAdvancedPropertySettings(t *testing.T) {
+	for _, tt := range advancedPropertySettings {
+		tt := tt
+		responder := func(cmd string) (string, error) {
+			if strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty") && strings.Contains(cmd, "'"+tt.keyword+"'") {
+				return tt.fixture, nil
+			}
+			return adapterNamesResponder(cmd)
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.get != nil {
+				mockExecClient := NewMockExecClient(false)
+				mockExecClient.SetPowershellCommandResponder(responder)
+				got, err := tt.get(mockExecClient, "Ethernet 2")
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(responder)
+			require.NoError(t, tt.set(mockExecClient, "Ethernet 2"))
+			assert.Equal(t, []string{
+				fmt.Sprintf("Set-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '%s' -RegistryValue '%s'", tt.keyword, tt.registryValue),
+			}, setCommands(mockExecClient.RecordedPowershellCommands()))
+
+			mockExecClient = NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(responder)
+			require.NoError(t, tt.setCurrent(mockExecClient, "Ethernet 2"))
+			assert.Empty(t, setCommands(mockExecClient.RecordedPowershellCommands()))
+
+			for _, setInvalid := range tt.setInvalid {
+				mockExecClient = NewMockExecClient(false)
+				mockExecClient.SetPowershellCommandResponder(responder)
+				require.Error(t, setInvalid(mockExecClient, "Ethernet 2"))
+				assert.Empty(t, setCommands(mockExecClient.RecordedPowershellCommands()))
+			}
+
+			// an adapter without the advanced property doesn't support the setting
+			mockExecClient = NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+			if tt.get != nil {
+				_, err := tt.get(mockExecClient, "Ethernet 2")
+				require.ErrorIs(t, err, adapter.ErrFeatureUnsupported)
+			}
+			require.ErrorIs(t, tt.set(mockExecClient, "Ethernet 2"), adapter.ErrFeatureUnsupported)
+
+			mockExecClient = NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(responder)
+			if tt.get != nil {
+				_, err := tt.get(mockExecClient, "Ethernet 3")
+				require.ErrorIs(t, err, adapter.ErrAdapterNotFound)
+			}
+			require.ErrorIs(t, tt.set(mockExecClient, "Ethernet 3"), adapter.ErrAdapterNotFound)
+			assert.Empty(t, setCommands(mockExecClient.RecordedPowershellCommands()))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// packetCaptureSessionPrefix prefixes the names of the trace sessions started by StartPacketCapture
+	packetCaptureSessionPrefix = "acn-capture-"
+
+	// cmdUnsafeChars are the characters which cmd interprets even inside the quoted arguments of a command
+	cmdUnsafeChars = "\"&|<>^%\r\n"
+)
+
+// ErrInvalidCaptureID is returned when stopping a capture which was not started by StartPacketCapture
+var ErrInvalidCaptureID = errors.New("invalid packet capture id")
+
+// StartPacketCapture starts capturing the packets of the adapter into the etl file at outputPath
+// and returns the id of the capture to pass to StopPacketCapture.
+// Adapter names and paths with characters cmd would interpret, such as " or &, are rejected.
+func StartPacketCapture(execClient ExecClient, adapterName, outputPath string) (captureID string, err error) {
+	if strings.ContainsAny(adapterName, cmdUnsafeChars) {
+		return "", fmt.Errorf("invalid adapter name %q for packet capture", adapterName)
+	}
+
+	if outputPath, err = filepath.Abs(outputPath); err != nil {
+		return "", fmt.Errorf("failed to get absolute path of packet capture file: %w", err)
+	}
+
+	if strings.ContainsAny(outputPath, cmdUnsafeChars) {
+		return "", fmt.Errorf("invalid packet capture file path %q", outputPath)
+	}
+
+	na := &networkAdapter{execClient: execClient}
+	if err = na.checkAdapterExists(adapterName); err != nil {
+		return "", fmt.Errorf("cannot capture packets of adapter %s: %w", adapterName, err)
+	}
+
+	if err = checkDirWritable(filepath.Dir(outputPath)); err != nil {
+		return "", fmt.Errorf("cannot write packet capture to %s: %w", outputPath, err)
+	}
+
+	captureID = fmt.Sprintf("%s%d", packetCaptureSessionPrefix, time.Now().UnixNano())
+	cmd := fmt.Sprintf("netsh trace start sessionname=%s capture=yes CaptureInterface=\"%s\" tracefile=\"%s\" "+
+		"report=disabled persistent=no overwrite=yes", captureID, adapterName, outputPath)
+	if _, err = execClient.ExecuteCommand(cmd); err != nil {
+		return "", fmt.Errorf("failed to start packet capture on %s: %w", adapterName, err)
+	}
+
+	return captureID, nil
+}
+
+// StopPacketCapture stops the capture started by StartPacketCapture and flushes it to its output file
+func StopPacketCapture(execClient ExecClient, captureID string) error {
+	if !strings.HasPrefix(captureID, packetCaptureSessionPrefix) || strings.ContainsAny(captureID, " \t\"") {
+		return fmt.Errorf("%w: %q", ErrInvalidCaptureID, captureID)
+	}
+
+	cmd := fmt.Sprintf("netsh trace stop sessionname=%s", captureID)
+	if _, err := execClient.ExecuteCommand(cmd); err != nil {
+		return fmt.Errorf("failed to stop packet capture %s: %w", captureID, err)
+	}
+
+	return nil
+}
+
+// checkDirWritable returns an error if a file cannot be created in dir
+func checkDirWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+
+	f.Close()
+	return os.Remove(f.Name())
+}
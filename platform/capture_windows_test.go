@@ -0,0 +1,77 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartStopPacketCapture(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "capture.etl")
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	captureID, err := StartPacketCapture(mockExecClient, "Ethernet 2", outputPath)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(captureID, packetCaptureSessionPrefix))
+
+	require.NoError(t, StopPacketCapture(mockExecClient, captureID))
+	assert.Equal(t, []string{
+		"netsh trace start sessionname=" + captureID + " capture=yes CaptureInterface=\"Ethernet 2\" tracefile=\"" + outputPath + "\" " +
+			"report=disabled persistent=no overwrite=yes",
+		"netsh trace stop sessionname=" + captureID,
+	}, mockExecClient.RecordedCommands())
+}
+
+func TestStartPacketCaptureUnknownAdapter(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	_, err := StartPacketCapture(mockExecClient, "Ethernet 3", filepath.Join(t.TempDir(), "capture.etl"))
+	require.ErrorIs(t, err, adapter.ErrAdapterNotFound)
+	assert.Empty(t, mockExecClient.RecordedCommands())
+
+	_, err = StartPacketCapture(NewMockExecClient(false), "Ethernet", filepath.Join(t.TempDir(), "capture.etl"))
+	require.ErrorIs(t, err, adapter.ErrAdapterNotFound)
+}
+
+func TestStartPacketCaptureInvalidArguments(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "Ethernet\r\nEthernet\" & calc & \"\r\n", nil
+	})
+
+	_, err := StartPacketCapture(mockExecClient, "Ethernet\" & calc & \"", filepath.Join(t.TempDir(), "capture.etl"))
+	require.Error(t, err)
+
+	for _, name := range []string{"capture\" & calc & \".etl", "capture&calc.etl", "capture|calc.etl", "%TEMP%.etl"} {
+		_, err = StartPacketCapture(mockExecClient, "Ethernet", filepath.Join(t.TempDir(), name))
+		require.Error(t, err, name)
+	}
+
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+	assert.Empty(t, mockExecClient.RecordedCommands())
+}
+
+func TestStartPacketCaptureOutputNotWritable(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	_, err := StartPacketCapture(mockExecClient, "Ethernet", filepath.Join(t.TempDir(), "missing", "capture.etl"))
+	require.Error(t, err)
+	assert.Empty(t, mockExecClient.RecordedCommands())
+}
+
+func TestStopPacketCaptureInvalidID(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	require.ErrorIs(t, StopPacketCapture(mockExecClient, ""), ErrInvalidCaptureID)
+	require.ErrorIs(t, StopPacketCapture(mockExecClient, packetCaptureSessionPrefix+"1 & del"), ErrInvalidCaptureID)
+	assert.Empty(t, mockExecClient.RecordedCommands())
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Identifiers of the CNI plugins returned by DetectInstalledCNI
+const (
+	CNIAzure   = "azure"
+	CNICalico  = "calico"
+	CNICilium  = "cilium"
+	CNIUnknown = "unknown"
+)
+
+// cniVersionTimeout bounds the time the CNI binary is given to print its version
+const cniVersionTimeout = 10 * time.Second
+
+var (
+	// ErrCNIBinaryNotFound is returned when there is no CNI binary to get the version of
+	ErrCNIBinaryNotFound = errors.New("CNI binary not found")
+	// ErrCNIVersionNotParsed is returned when the output of the CNI binary has no version
+	ErrCNIVersionNotParsed = errors.New("no version in CNI binary output")
+)
+
+// cniVersionRegex matches the version printed by the CNI binary, e.g. Azure CNI Version v1.4.39
+var cniVersionRegex = regexp.MustCompile(`Version\s+(\S+)`)
+
+// cniPluginTypes maps the plugin types in CNI network configurations to the CNI identifiers
+var cniPluginTypes = map[string]string{
+	"azure-vnet": CNIAzure,
+	"calico":     CNICalico,
+	"cilium-cni": CNICilium,
+}
+
+// cniBinaries maps the names of CNI plugin binaries to the CNI identifiers, in order of precedence
+var cniBinaries = []struct {
+	name string
+	cni  string
+}{
+	{"cilium-cni", CNICilium},
+	{"calico", CNICalico},
+	{"azure-vnet", CNIAzure},
+}
+
+// DetectInstalledCNI returns the identifier of the CNI plugin of the host, or CNIUnknown if it is none of the known plugins.
+// The network configuration in use takes precedence over the installed plugin binaries.
+func DetectInstalledCNI() (string, error) {
+	return detectInstalledCNI(K8SCNIRuntimePath, K8SNetConfigPath)
+}
+
+func detectInstalledCNI(binDir, confDir string) (string, error) {
+	cni, err := detectCNIFromNetConfig(confDir)
+	if err != nil || cni != CNIUnknown {
+		return cni, err
+	}
+
+	for _, binary := range cniBinaries {
+		for _, name := range []string{binary.name, binary.name + ".exe"} {
+			if _, err = os.Stat(filepath.Join(binDir, name)); err == nil {
+				return binary.cni, nil
+			}
+		}
+	}
+
+	return CNIUnknown, nil
+}
+
+// detectCNIFromNetConfig returns the CNI identifier of the plugins of the network configuration in confDir
+// used by the container runtime, i.e. the first one in lexicographic order
+func detectCNIFromNetConfig(confDir string) (string, error) {
+	entries, err := os.ReadDir(confDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return CNIUnknown, nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to read CNI network configuration directory %s: %w", confDir, err)
+	}
+
+	var confFiles []string
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".conf", ".conflist", ".json":
+			if !entry.IsDir() {
+				confFiles = append(confFiles, entry.Name())
+			}
+		}
+	}
+
+	if len(confFiles) == 0 {
+		return CNIUnknown, nil
+	}
+
+	sort.Strings(confFiles)
+	path := filepath.Join(confDir, confFiles[0])
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read CNI network configuration %s: %w", path, err)
+	}
+
+	var conf struct {
+		Type    string `json:"type"`
+		Plugins []struct {
+			Type string `json:"type"`
+		} `json:"plugins"`
+	}
+	if err = json.Unmarshal(data, &conf); err != nil {
+		return "", fmt.Errorf("failed to parse CNI network configuration %s: %w", path, err)
+	}
+
+	types := []string{conf.Type}
+	for _, plugin := range conf.Plugins {
+		types = append(types, plugin.Type)
+	}
+
+	for _, t := range types {
+		if cni, ok := cniPluginTypes[strings.TrimSuffix(t, ".exe")]; ok {
+			return cni, nil
+		}
+	}
+
+	return CNIUnknown, nil
+}
+
+// GetCNIBinaryVersion returns the version of the CNI binary at CNIBinaryPath, e.g. to confirm the binary
+// replaced during an upgrade is the expected one
+func GetCNIBinaryVersion() (string, error) {
+	return getCNIBinaryVersion(CNIBinaryPath)
+}
+
+func getCNIBinaryVersion(path string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("%s: %w", path, ErrCNIBinaryNotFound)
+		}
+		return "", fmt.Errorf("failed to stat CNI binary %s: %w", path, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cniVersionTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to run CNI binary %s: %w", path, err)
+	}
+
+	match := cniVersionRegex.FindStringSubmatch(string(out))
+	if match == nil {
+		return "", fmt.Errorf("failed to parse version of CNI binary %s from %q: %w", path, out, ErrCNIVersionNotParsed)
+	}
+
+	return match[1], nil
+}
@@ -0,0 +1,115 @@
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	azureNetConfigFixture = `{
+  "cniVersion": "0.3.0",
+  "name": "azure",
+  "plugins": [
+    {"type": "azure-vnet", "mode": "transparent", "ipam": {"type": "azure-vnet-ipam"}},
+    {"type": "portmap", "capabilities": {"portMappings": true}}
+  ]
+}`
+	ciliumNetConfigFixture = `{
+  "cniVersion": "0.3.1",
+  "name": "cilium",
+  "plugins": [{"type": "cilium-cni", "enable-debug": false}]
+}`
+	calicoNetConfigFixture = `{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.1",
+  "plugins": [{"type": "calico", "datastore_type": "kubernetes"}, {"type": "bandwidth"}]
+}`
+)
+
+// cniFixture creates CNI binary and network configuration directories with the given files
+func cniFixture(t *testing.T, binaries []string, confs map[string]string) (binDir, confDir string) {
+	binDir, confDir = t.TempDir(), t.TempDir()
+	for _, name := range binaries {
+		require.NoError(t, os.WriteFile(filepath.Join(binDir, name), nil, 0o600))
+	}
+
+	for name, conf := range confs {
+		require.NoError(t, os.WriteFile(filepath.Join(confDir, name), []byte(conf), 0o600))
+	}
+
+	return binDir, confDir
+}
+
+func TestDetectInstalledCNI(t *testing.T) {
+	tests := []struct {
+		name     string
+		binaries []string
+		confs    map[string]string
+		expected string
+	}{
+		{"azure", []string{"azure-vnet", "azure-vnet-ipam"}, map[string]string{"10-azure.conflist": azureNetConfigFixture}, CNIAzure},
+		{"azure windows", []string{"azure-vnet.exe"}, map[string]string{"10-azure.conflist": azureNetConfigFixture}, CNIAzure},
+		{
+			"cilium config sorts first", []string{"azure-vnet", "cilium-cni"},
+			map[string]string{"05-cilium.conflist": ciliumNetConfigFixture, "10-azure.conflist": azureNetConfigFixture}, CNICilium,
+		},
+		{"calico", []string{"calico", "calico-ipam"}, map[string]string{"10-calico.conflist": calicoNetConfigFixture}, CNICalico},
+		{"binaries only", []string{"calico"}, nil, CNICalico},
+		{"other files ignored", []string{"bridge"}, map[string]string{"README.md": "azure-vnet"}, CNIUnknown},
+		{"unknown", []string{"bridge", "host-local"}, map[string]string{"10-bridge.conf": `{"type": "bridge"}`}, CNIUnknown},
+		{"empty", nil, nil, CNIUnknown},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			binDir, confDir := cniFixture(t, tt.binaries, tt.confs)
+			cni, err := detectInstalledCNI(binDir, confDir)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cni)
+		})
+	}
+}
+
+func TestDetectInstalledCNIMissingDirs(t *testing.T) {
+	dir := t.TempDir()
+	cni, err := detectInstalledCNI(filepath.Join(dir, "bin"), filepath.Join(dir, "netconf"))
+	require.NoError(t, err)
+	assert.Equal(t, CNIUnknown, cni)
+}
+
+func TestDetectInstalledCNIInvalidConfig(t *testing.T) {
+	binDir, confDir := cniFixture(t, nil, map[string]string{"10-azure.conflist": "{"})
+	_, err := detectInstalledCNI(binDir, confDir)
+	require.Error(t, err)
+}
+
+// fakeCNIBinary creates an executable script printing output, as the CNI binary does for --version
+func fakeCNIBinary(t *testing.T, output string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake CNI binary is a shell script")
+	}
+
+	path := filepath.Join(t.TempDir(), "azure-vnet")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\necho '"+output+"'\n"), 0o700))
+	return path
+}
+
+func TestGetCNIBinaryVersion(t *testing.T) {
+	version, err := getCNIBinaryVersion(fakeCNIBinary(t, "Azure CNI Version v1.4.39"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.4.39", version)
+}
+
+func TestGetCNIBinaryVersionError(t *testing.T) {
+	_, err := getCNIBinaryVersion(filepath.Join(t.TempDir(), "azure-vnet"))
+	require.ErrorIs(t, err, ErrCNIBinaryNotFound)
+
+	_, err = getCNIBinaryVersion(fakeCNIBinary(t, "usage: azure-vnet"))
+	require.ErrorIs(t, err, ErrCNIVersionNotParsed)
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+)
+
+// Identifiers of the container runtimes returned by DetectContainerRuntime
+const (
+	ContainerRuntimeContainerd = "containerd"
+	ContainerRuntimeDocker     = "docker"
+	ContainerRuntimeUnknown    = "unknown"
+)
+
+// containerRuntimes are the known container runtimes with their service and binary names, in order of precedence
+var containerRuntimes = []struct {
+	runtime string
+	service string
+	binary  string
+}{
+	{ContainerRuntimeContainerd, "containerd", "containerd.exe"},
+	{ContainerRuntimeDocker, "docker", "dockerd.exe"},
+}
+
+// DetectContainerRuntime returns the identifier of the container runtime of the host, i.e. the first one whose
+// service is installed or whose binary is on the path, or ContainerRuntimeUnknown if it is none of the known runtimes
+func DetectContainerRuntime(execClient ExecClient) (string, error) {
+	for _, r := range containerRuntimes {
+		cmd := fmt.Sprintf("($null -ne (Get-Service -Name %s -ErrorAction SilentlyContinue)) -or "+
+			"($null -ne (Get-Command %s -ErrorAction SilentlyContinue))", r.service, r.binary)
+		out, err := execClient.ExecutePowershellCommand(cmd)
+		if err != nil {
+			return "", fmt.Errorf("failed to check for container runtime %s: %w", r.runtime, err)
+		}
+
+		found, err := parsePowershellBool(out)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse output %q of %q: %w", out, cmd, err)
+		}
+
+		if found {
+			return r.runtime, nil
+		}
+	}
+
+	return ContainerRuntimeUnknown, nil
+}
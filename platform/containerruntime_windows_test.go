@@ -0,0 +1,73 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// containerRuntimeResponder answers the checks for container runtimes of a host with the given installed services
+func containerRuntimeResponder(services ...string) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		for _, service := range services {
+			if strings.Contains(cmd, "Get-Service -Name "+service+" ") {
+				return "True", nil
+			}
+		}
+		return "False", nil
+	}
+}
+
+func TestDetectContainerRuntime(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []string
+		expected string
+	}{
+		{name: "containerd", services: []string{"containerd"}, expected: ContainerRuntimeContainerd},
+		{name: "docker", services: []string{"docker"}, expected: ContainerRuntimeDocker},
+		{name: "containerd and docker", services: []string{"docker", "containerd"}, expected: ContainerRuntimeContainerd},
+		{name: "none", expected: ContainerRuntimeUnknown},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(containerRuntimeResponder(tt.services...))
+
+			runtime, err := DetectContainerRuntime(mockExecClient)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, runtime)
+		})
+	}
+}
+
+func TestDetectContainerRuntimeCommand(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(containerRuntimeResponder("containerd"))
+
+	_, err := DetectContainerRuntime(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"($null -ne (Get-Service -Name containerd -ErrorAction SilentlyContinue)) -or " +
+			"($null -ne (Get-Command containerd.exe -ErrorAction SilentlyContinue))",
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestDetectContainerRuntimeError(t *testing.T) {
+	_, err := DetectContainerRuntime(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "unexpected", nil
+	})
+	_, err = DetectContainerRuntime(mockExecClient)
+	require.Error(t, err)
+}
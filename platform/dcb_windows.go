@@ -0,0 +1,56 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+)
+
+// DCBTrafficClass is an Enhanced Transmission Selection traffic class of the host
+type DCBTrafficClass struct {
+	Name string
+	// Algorithm is the transmission selection algorithm of the class, ETS or Strict
+	Algorithm string
+	// Bandwidth is the percentage of the bandwidth reserved for the class
+	Bandwidth int
+	// Priorities are the 802.1p priorities of the class
+	Priorities []int `json:"Priority"`
+}
+
+// DCBConfig is the Data Center Bridging configuration of an adapter, which RDMA over Converged Ethernet depends on
+type DCBConfig struct {
+	// Enabled is whether DCB is enabled on the adapter
+	Enabled bool
+	// PFCEnabledPriorities are the 802.1p priorities Priority Flow Control is enabled for on the host
+	PFCEnabledPriorities []int
+	// TrafficClasses are the ETS traffic classes of the host
+	TrafficClasses []DCBTrafficClass
+}
+
+// GetDCBConfiguration returns the Data Center Bridging configuration of the adapter.
+// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if it doesn't support DCB.
+func GetDCBConfiguration(execClient ExecClient, adapterName string) (DCBConfig, error) {
+	na := &networkAdapter{execClient: execClient}
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return DCBConfig{}, err
+	}
+
+	cmd := fmt.Sprintf("$qos = Get-NetAdapterQos -Name '%s' -ErrorAction Stop; [pscustomobject]@{ "+
+		"Enabled = $qos.Enabled; "+
+		"PFCEnabledPriorities = @(Get-NetQosFlowControl | Where-Object { $_.Enabled } | ForEach-Object { [int]$_.Priority }); "+
+		"TrafficClasses = @(Get-NetQosTrafficClass | Select-Object Name, "+
+		"@{Name='Algorithm'; Expression={$_.Algorithm.ToString()}}, Bandwidth, Priority) }", escapePowershellString(adapterName))
+	config, err := ExecutePowershellJSON[DCBConfig](execClient, cmd)
+	if isNoCimObjectsFoundError(err) {
+		return DCBConfig{}, fmt.Errorf("DCB on %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return DCBConfig{}, fmt.Errorf("failed to get DCB configuration of %s: %w", adapterName, err)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dcbConfigurationFixture = `{
+    "Enabled":  true,
+    "PFCEnabledPriorities":  [3],
+    "TrafficClasses":  [
+        {"Name": "[Default]", "Algorithm": "ETS", "Bandwidth": 50, "Priority": [0, 1, 2, 4, 5, 6, 7]},
+        {"Name": "SMB", "Algorithm": "ETS", "Bandwidth": 50, "Priority": [3]}
+    ]
+}`
+
+// dcbResponder answers the powershell commands of an adapter with the given DCB configuration output
+func dcbResponder(out string, err error) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "$qos = Get-NetAdapterQos") {
+			return out, err
+		}
+		return adapterNamesResponder(cmd)
+	}
+}
+
+func TestGetDCBConfiguration(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(dcbResponder(dcbConfigurationFixture, nil))
+
+	config, err := GetDCBConfiguration(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.Equal(t, DCBConfig{
+		Enabled:              true,
+		PFCEnabledPriorities: []int{3},
+		TrafficClasses: []DCBTrafficClass{
+			{Name: "[Default]", Algorithm: "ETS", Bandwidth: 50, Priorities: []int{0, 1, 2, 4, 5, 6, 7}},
+			{Name: "SMB", Algorithm: "ETS", Bandwidth: 50, Priorities: []int{3}},
+		},
+	}, config)
+	assert.Contains(t, mockExecClient.RecordedPowershellCommands()[1], "Get-NetAdapterQos -Name 'Ethernet 2' -ErrorAction Stop")
+}
+
+func TestGetDCBConfigurationDisabled(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(dcbResponder(`{"Enabled": false, "PFCEnabledPriorities": [], "TrafficClasses": []}`, nil))
+
+	config, err := GetDCBConfiguration(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.False(t, config.Enabled)
+	assert.Empty(t, config.PFCEnabledPriorities)
+	assert.Empty(t, config.TrafficClasses)
+}
+
+func TestGetDCBConfigurationError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(dcbResponder(dcbConfigurationFixture, nil))
+	_, err := GetDCBConfiguration(mockExecClient, "Ethernet 3")
+	require.ErrorIs(t, err, adapter.ErrAdapterNotFound)
+
+	mockExecClient.SetPowershellCommandResponder(dcbResponder("",
+		errors.New("Get-NetAdapterQos : No matching MSFT_NetAdapterQosSettingData objects found")))
+	_, err = GetDCBConfiguration(mockExecClient, "Ethernet 2")
+	require.ErrorIs(t, err, adapter.ErrFeatureUnsupported)
+
+	_, err = GetDCBConfiguration(NewMockExecClient(true), "Ethernet 2")
+	require.ErrorIs(t, err, ErrMockExec)
+}
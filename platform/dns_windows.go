@@ -0,0 +1,54 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"net"
+)
+
+// Command to flush the DNS client cache
+const FlushDNSCacheCommand = "Clear-DnsClientCache"
+
+// FlushDNSCache clears the DNS client cache of the host
+func FlushDNSCache(execClient ExecClient) error {
+	if _, err := execClient.ExecutePowershellCommand(FlushDNSCacheCommand); err != nil {
+		return fmt.Errorf("failed to flush DNS cache: %w", err)
+	}
+
+	return nil
+}
+
+// GetDNSServers returns the DNS servers configured on the adapter, IPv4 ones first
+func GetDNSServers(execClient ExecClient, adapterName string) ([]string, error) {
+	cmd := fmt.Sprintf("Get-DnsClientServerAddress -InterfaceAlias '%s' -ErrorAction Stop | "+
+		"Sort-Object AddressFamily | Select-Object -ExpandProperty ServerAddresses", escapePowershellString(adapterName))
+	out, err := execClient.ExecutePowershellCommand(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DNS servers of %s: %w", adapterName, err)
+	}
+
+	return splitPowershellLines(out), nil
+}
+
+// SetDNSServers replaces the DNS servers configured on the adapter with servers, in order of preference.
+// No servers resets the adapter to the servers it gets from DHCP.
+func SetDNSServers(execClient ExecClient, adapterName string, servers []string) error {
+	for _, server := range servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid DNS server address %q", server)
+		}
+	}
+
+	cmd := fmt.Sprintf("Set-DnsClientServerAddress -InterfaceAlias '%s' -ServerAddresses (%s)", escapePowershellString(adapterName), powershellStringList(servers))
+	if len(servers) == 0 {
+		cmd = fmt.Sprintf("Set-DnsClientServerAddress -InterfaceAlias '%s' -ResetServerAddresses", escapePowershellString(adapterName))
+	}
+
+	if _, err := execClient.ExecutePowershellCommand(cmd); err != nil {
+		return fmt.Errorf("failed to set DNS servers of %s to %v: %w", adapterName, servers, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushDNSCache(t *testing.T) {
+	var commands []string
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		commands = append(commands, cmd)
+		return "", nil
+	})
+
+	// flushing an already empty cache succeeds as well
+	require.NoError(t, FlushDNSCache(mockExecClient))
+	require.NoError(t, FlushDNSCache(mockExecClient))
+	assert.Equal(t, []string{FlushDNSCacheCommand, FlushDNSCacheCommand}, commands)
+}
+
+func TestFlushDNSCacheError(t *testing.T) {
+	require.ErrorIs(t, FlushDNSCache(NewMockExecClient(true)), ErrMockExec)
+}
+
+func TestGetDNSServers(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, "Get-DnsClientServerAddress -InterfaceAlias 'Ethernet 2' -ErrorAction Stop | "+
+			"Sort-Object AddressFamily | Select-Object -ExpandProperty ServerAddresses", cmd)
+		return "168.63.129.16\r\n10.0.0.10\r\nfd00::10\r\n", nil
+	})
+
+	servers, err := GetDNSServers(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"168.63.129.16", "10.0.0.10", "fd00::10"}, servers)
+
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) { return "", nil })
+	servers, err = GetDNSServers(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.Empty(t, servers)
+
+	_, err = GetDNSServers(NewMockExecClient(true), "Ethernet 2")
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestSetDNSServers(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+
+	require.NoError(t, SetDNSServers(mockExecClient, "Ethernet 2", []string{"10.0.0.10", "168.63.129.16"}))
+	require.NoError(t, SetDNSServers(mockExecClient, "Ethernet 2", nil))
+	assert.Equal(t, []string{
+		"Set-DnsClientServerAddress -InterfaceAlias 'Ethernet 2' -ServerAddresses ('10.0.0.10', '168.63.129.16')",
+		"Set-DnsClientServerAddress -InterfaceAlias 'Ethernet 2' -ResetServerAddresses",
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestSetDNSServersError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	require.Error(t, SetDNSServers(mockExecClient, "Ethernet 2", []string{"10.0.0.10", "dns.local"}))
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+
+	require.ErrorIs(t, SetDNSServers(NewMockExecClient(true), "Ethernet 2", []string{"10.0.0.10"}), ErrMockExec)
+}
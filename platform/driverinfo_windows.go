@@ -0,0 +1,45 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+)
+
+// firmwareVersionDisplayNamePattern matches the display name of the advanced property reporting the firmware version,
+// which drivers such as Mellanox's expose
+const firmwareVersionDisplayNamePattern = "*Firmware*"
+
+// DriverInfo is the driver and firmware of an adapter
+type DriverInfo struct {
+	DriverVersion string
+	// FirmwareVersion is empty if the driver does not report it
+	FirmwareVersion string
+	Provider        string
+}
+
+// GetAdapterDriverInfo returns the driver and firmware versions and the driver provider of the adapter
+func GetAdapterDriverInfo(execClient ExecClient, adapterName string) (DriverInfo, error) {
+	cmd := fmt.Sprintf("Get-NetAdapter -Name '%s' | Select-Object DriverVersionString, DriverProvider", escapePowershellString(adapterName))
+	driver, err := ExecutePowershellJSON[struct {
+		DriverVersionString string
+		DriverProvider      string
+	}](execClient, cmd)
+	if err != nil {
+		return DriverInfo{}, fmt.Errorf("failed to get driver of %s: %w", adapterName, err)
+	}
+
+	cmd = fmt.Sprintf("Get-NetAdapterAdvancedProperty -Name '%s' -DisplayName '%s' -ErrorAction SilentlyContinue | "+
+		"Select-Object -First 1 -ExpandProperty DisplayValue", escapePowershellString(adapterName), firmwareVersionDisplayNamePattern)
+	firmwareVersion, err := execClient.ExecutePowershellCommand(cmd)
+	if err != nil {
+		return DriverInfo{}, fmt.Errorf("failed to get firmware version of %s: %w", adapterName, err)
+	}
+
+	return DriverInfo{
+		DriverVersion:   driver.DriverVersionString,
+		FirmwareVersion: firmwareVersion,
+		Provider:        driver.DriverProvider,
+	}, nil
+}
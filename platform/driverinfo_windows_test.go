@@ -0,0 +1,60 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAdapterDriverInfo(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch {
+		case strings.HasPrefix(cmd, "Get-NetAdapter -Name 'Ethernet 3'"):
+			return `{"DriverVersionString": "3.10.51000", "DriverProvider": "Mellanox Technologies Ltd."}`, nil
+		case strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty -Name 'Ethernet 3' -DisplayName '*Firmware*'"):
+			return "14.32.1010", nil
+		}
+		return "", nil
+	})
+
+	info, err := GetAdapterDriverInfo(mockExecClient, "Ethernet 3")
+	require.NoError(t, err)
+	assert.Equal(t, DriverInfo{
+		DriverVersion:   "3.10.51000",
+		FirmwareVersion: "14.32.1010",
+		Provider:        "Mellanox Technologies Ltd.",
+	}, info)
+}
+
+func TestGetAdapterDriverInfoNoFirmware(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence([]func(string) (string, error){
+		func(string) (string, error) {
+			return `{"DriverVersionString": "1.8.0.1", "DriverProvider": "Microsoft"}`, nil
+		},
+		func(string) (string, error) { return "", nil },
+	})
+
+	info, err := GetAdapterDriverInfo(mockExecClient, "Ethernet")
+	require.NoError(t, err)
+	assert.Equal(t, "1.8.0.1", info.DriverVersion)
+	assert.Empty(t, info.FirmwareVersion)
+}
+
+func TestGetAdapterDriverInfoError(t *testing.T) {
+	_, err := GetAdapterDriverInfo(NewMockExecClient(true), "Ethernet")
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "not json", nil
+	})
+	_, err = GetAdapterDriverInfo(mockExecClient, "Ethernet")
+	require.Error(t, err)
+}
@@ -0,0 +1,163 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Command to get the TCP ephemeral port range of the host
+	GetEphemeralPortRangeCommand = "netsh int ipv4 show dynamicport tcp"
+
+	// Command to get the TCP port ranges excluded from the ephemeral ports of the host
+	GetExcludedPortRangesCommand = "netsh int ipv4 show excludedportrange protocol=tcp"
+
+	// Bounds of the TCP ephemeral port range accepted by netsh
+	minEphemeralPortStart = 1025
+	minEphemeralPortCount = 255
+	maxPort               = 65535
+)
+
+// ErrInvalidPortRange is returned when an ephemeral port range is out of bounds or overlaps reserved or excluded ports
+var ErrInvalidPortRange = errors.New("invalid port range")
+
+// reservedPorts are the ports the node components listen on, which must not be handed out as ephemeral ports
+var reservedPorts = map[int]string{
+	10090: "azure-cns",
+	10091: "azure-npm",
+	10250: "kubelet",
+	10256: "kube-proxy",
+}
+
+var (
+	ephemeralPortStartRegex = regexp.MustCompile(`Start Port\s*:\s*(\d+)`)
+	ephemeralPortCountRegex = regexp.MustCompile(`Number of Ports\s*:\s*(\d+)`)
+
+	// excludedPortRangeRegex matches the rows of the excluded port ranges listed by netsh, e.g.
+	//      50000       50059     *
+	excludedPortRangeRegex = regexp.MustCompile(`(?m)^\s*(\d+)\s+(\d+)\s*\*?\s*$`)
+)
+
+// portRange is a range of ports, from start to end inclusive
+type portRange struct {
+	start, end int
+}
+
+// getExcludedPortRanges returns the TCP port ranges excluded from the ephemeral ports of the host
+func getExcludedPortRanges(execClient ExecClient) ([]portRange, error) {
+	out, err := execClient.ExecuteCommand(GetExcludedPortRangesCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get excluded port ranges: %w", err)
+	}
+
+	var ranges []portRange
+	for _, match := range excludedPortRangeRegex.FindAllStringSubmatch(out, -1) {
+		// the submatches are digits only, bounded by netsh
+		start, _ := strconv.Atoi(match[1])
+		end, _ := strconv.Atoi(match[2])
+		ranges = append(ranges, portRange{start: start, end: end})
+	}
+
+	return ranges, nil
+}
+
+// GetEphemeralPortRange returns the first port and the number of ports of the TCP ephemeral port range of the host
+func GetEphemeralPortRange(execClient ExecClient) (start, count int, err error) {
+	out, err := execClient.ExecuteCommand(GetEphemeralPortRangeCommand)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get ephemeral port range: %w", err)
+	}
+
+	startMatch := ephemeralPortStartRegex.FindStringSubmatch(out)
+	countMatch := ephemeralPortCountRegex.FindStringSubmatch(out)
+	if startMatch == nil || countMatch == nil {
+		return 0, 0, fmt.Errorf("failed to parse ephemeral port range from %q", out)
+	}
+
+	// the submatches are digits only, bounded by netsh
+	start, _ = strconv.Atoi(startMatch[1])
+	count, _ = strconv.Atoi(countMatch[1])
+	return start, count, nil
+}
+
+// SetEphemeralPortRange sets the TCP ephemeral port range of the host to the count ports from start,
+// e.g. to widen it on nodes running out of ports.
+// Returns ErrInvalidPortRange if netsh would reject the range or if it includes the ports of the node components
+// or ports excluded from the ephemeral ports of the host.
+func SetEphemeralPortRange(execClient ExecClient, start, count int) error {
+	if err := validateEphemeralPortRange(execClient, start, count); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("netsh int ipv4 set dynamicport tcp start=%d num=%d", start, count)
+	if _, err := execClient.ExecuteCommand(cmd); err != nil {
+		return fmt.Errorf("failed to set ephemeral port range: %w", err)
+	}
+
+	return nil
+}
+
+// validateEphemeralPortRange returns ErrInvalidPortRange if the range of count ports from start is out of the bounds
+// accepted by netsh, includes reserved ports or overlaps the excluded port ranges of the host
+func validateEphemeralPortRange(execClient ExecClient, start, count int) error {
+	if start < minEphemeralPortStart || count < minEphemeralPortCount || start+count-1 > maxPort {
+		return fmt.Errorf("%d ports from %d, expected at least %d ports from %d up to %d: %w",
+			count, start, minEphemeralPortCount, minEphemeralPortStart, maxPort, ErrInvalidPortRange)
+	}
+
+	for port, owner := range reservedPorts {
+		if port >= start && port < start+count {
+			return fmt.Errorf("%d ports from %d include port %d of %s: %w", count, start, port, owner, ErrInvalidPortRange)
+		}
+	}
+
+	excluded, err := getExcludedPortRanges(execClient)
+	if err != nil {
+		return err
+	}
+
+	end := start + count - 1
+	for _, r := range excluded {
+		if r.start <= end && start <= r.end {
+			return fmt.Errorf("%d ports from %d overlap excluded ports %d-%d: %w", count, start, r.start, r.end, ErrInvalidPortRange)
+		}
+	}
+
+	return nil
+}
+
+// ReservePortRange excludes the count TCP ports from start from the ephemeral ports of the host, so that fixed ports
+// such as the CNS one are not handed out to other processes. Ports already excluded are left as they are.
+// Returns ErrInvalidPortRange if the range is out of bounds.
+func ReservePortRange(execClient ExecClient, start, count int) error {
+	if start < 1 || count < 1 || start+count-1 > maxPort {
+		return fmt.Errorf("%d ports from %d: %w", count, start, ErrInvalidPortRange)
+	}
+
+	excluded, err := getExcludedPortRanges(execClient)
+	if err != nil {
+		return err
+	}
+
+	end := start + count - 1
+	for _, r := range excluded {
+		if r.start <= start && end <= r.end {
+			log.Printf("Ports %d-%d are already reserved by excluded range %d-%d", start, end, r.start, r.end)
+			return nil
+		}
+	}
+
+	cmd := fmt.Sprintf("netsh int ipv4 add excludedportrange protocol=tcp startport=%d numberofports=%d", start, count)
+	if _, err = execClient.ExecuteCommand(cmd); err != nil {
+		return fmt.Errorf("failed to reserve ports %d-%d: %w", start, end, err)
+	}
+
+	return nil
+}
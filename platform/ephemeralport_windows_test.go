@@ -0,0 +1,141 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ephemeralPortRangeFixture = `
+Protocol tcp Dynamic Port Range
+---------------------------------
+Start Port      : 49152
+Number of Ports : 16384
+`
+
+const excludedPortRangesFixture = `
+Protocol tcp Port Exclusion Ranges
+
+Start Port    End Port
+----------    --------
+      5357        5357
+     10090       10091     *
+     50000       50059     *
+
+* - Administered port exclusions.
+`
+
+func TestGetEphemeralPortRange(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, GetEphemeralPortRangeCommand, cmd)
+		return ephemeralPortRangeFixture, nil
+	})
+
+	start, count, err := GetEphemeralPortRange(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, 49152, start)
+	assert.Equal(t, 16384, count)
+}
+
+func TestGetEphemeralPortRangeError(t *testing.T) {
+	_, _, err := GetEphemeralPortRange(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(func(string) (string, error) {
+		return "The requested operation requires elevation (Run as administrator).", nil
+	})
+	_, _, err = GetEphemeralPortRange(mockExecClient)
+	require.Error(t, err)
+}
+
+// excludedPortRangesResponder answers the query of the excluded port ranges with excludedPortRangesFixture
+func excludedPortRangesResponder(cmd string) (string, error) {
+	if cmd == GetExcludedPortRangesCommand {
+		return excludedPortRangesFixture, nil
+	}
+	return "Ok.", nil
+}
+
+func TestSetEphemeralPortRange(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(excludedPortRangesResponder)
+	require.NoError(t, SetEphemeralPortRange(mockExecClient, 20000, 16384))
+	assert.Equal(t, []string{
+		GetExcludedPortRangesCommand,
+		"netsh int ipv4 set dynamicport tcp start=20000 num=16384",
+	}, mockExecClient.RecordedCommands())
+
+	require.ErrorIs(t, SetEphemeralPortRange(NewMockExecClient(true), 20000, 16384), ErrMockExec)
+}
+
+func TestSetEphemeralPortRangeOverlapsExcludedPorts(t *testing.T) {
+	// 50000-50059 are excluded
+	for _, r := range []struct{ start, count int }{{49152, 16384}, {50059, 1000}, {40000, 10001}} {
+		mockExecClient := NewMockExecClient(false)
+		mockExecClient.SetCommandResponder(excludedPortRangesResponder)
+		require.ErrorIs(t, SetEphemeralPortRange(mockExecClient, r.start, r.count), ErrInvalidPortRange)
+		assert.Equal(t, []string{GetExcludedPortRangesCommand}, mockExecClient.RecordedCommands())
+	}
+}
+
+func TestSetEphemeralPortRangeInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		start int
+		count int
+	}{
+		{name: "well-known ports", start: 1000, count: 1000},
+		{name: "too few ports", start: 49152, count: 100},
+		{name: "beyond the last port", start: 60000, count: 10000},
+		{name: "overlapping cns", start: 10000, count: 1000},
+		{name: "overlapping kubelet", start: 10250, count: 55286},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			require.ErrorIs(t, SetEphemeralPortRange(mockExecClient, tt.start, tt.count), ErrInvalidPortRange)
+			assert.Empty(t, mockExecClient.RecordedCommands())
+		})
+	}
+}
+
+func TestReservePortRange(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(excludedPortRangesResponder)
+
+	require.NoError(t, ReservePortRange(mockExecClient, 10250, 7))
+	assert.Equal(t, []string{
+		GetExcludedPortRangesCommand,
+		"netsh int ipv4 add excludedportrange protocol=tcp startport=10250 numberofports=7",
+	}, mockExecClient.RecordedCommands())
+}
+
+func TestReservePortRangeAlreadyReserved(t *testing.T) {
+	for _, r := range []struct{ start, count int }{{10090, 1}, {10090, 2}, {50010, 10}, {5357, 1}} {
+		mockExecClient := NewMockExecClient(false)
+		mockExecClient.SetCommandResponder(func(string) (string, error) {
+			return excludedPortRangesFixture, nil
+		})
+
+		require.NoError(t, ReservePortRange(mockExecClient, r.start, r.count))
+		assert.Equal(t, []string{GetExcludedPortRangesCommand}, mockExecClient.RecordedCommands())
+	}
+}
+
+func TestReservePortRangeError(t *testing.T) {
+	for _, r := range []struct{ start, count int }{{0, 1}, {10090, 0}, {65535, 2}} {
+		mockExecClient := NewMockExecClient(false)
+		require.ErrorIs(t, ReservePortRange(mockExecClient, r.start, r.count), ErrInvalidPortRange)
+		assert.Empty(t, mockExecClient.RecordedCommands())
+	}
+
+	require.ErrorIs(t, ReservePortRange(NewMockExecClient(true), 10090, 1), ErrMockExec)
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// EventLevel is the level of an entry written to the Windows event log
+type EventLevel int
+
+const (
+	EventLevelInformation EventLevel = iota
+	EventLevelWarning
+	EventLevelError
+)
+
+const (
+	// PlatformEventLogSource is the event source of the entries written for critical platform failures
+	PlatformEventLogSource = "AzureContainerNetworking"
+
+	// eventLogApplicationKey is the registry key under which the event sources of the Application log are registered
+	eventLogApplicationKey = `SYSTEM\CurrentControlSet\Services\EventLog\Application`
+
+	// eventLogEventID is the id of the written entries. EventCreate.exe, the message file of the
+	// registered sources, only supports ids from 1 to 1000.
+	eventLogEventID = 1
+)
+
+// eventLogWriter writes entries of an event source to the Windows event log
+type eventLogWriter interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+	Close() error
+}
+
+// openEventLog is openRegisteredEventLog, replaced in tests
+var openEventLog = openRegisteredEventLog
+
+// openRegisteredEventLog registers the event source if needed and opens it for writing
+func openRegisteredEventLog(source string) (eventLogWriter, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, eventLogApplicationKey+`\`+source, registry.QUERY_VALUE)
+	if err == nil {
+		k.Close()
+	} else if err = eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		return nil, fmt.Errorf("failed to register event source %s: %w", source, err)
+	}
+
+	return eventlog.Open(source)
+}
+
+// WriteEventLog writes the message to the Windows event log as an entry of the event source with the given level.
+// The source is registered in the Application log if it is not already.
+func WriteEventLog(source, message string, level EventLevel) error {
+	w, err := openEventLog(source)
+	if err != nil {
+		return fmt.Errorf("failed to open event log of %s: %w", source, err)
+	}
+	defer w.Close()
+
+	switch level {
+	case EventLevelInformation:
+		err = w.Info(eventLogEventID, message)
+	case EventLevelWarning:
+		err = w.Warning(eventLogEventID, message)
+	case EventLevelError:
+		err = w.Error(eventLogEventID, message)
+	default:
+		return fmt.Errorf("invalid event level %d", level)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write event log entry of %s: %w", source, err)
+	}
+
+	return nil
+}
+
+// maxNetworkEvents bounds the number of event log entries returned by GetRecentNetworkEvents
+const maxNetworkEvents = 200
+
+// networkEventLogNames are the event logs of HNS and the networking stack
+var networkEventLogNames = []string{
+	"Microsoft-Windows-Host-Network-Service-Admin",
+	"Microsoft-Windows-Host-Network-Service-Operational",
+	"Microsoft-Windows-TCPIP/Operational",
+}
+
+// EventLogEntry is an entry of a Windows event log.
+type EventLogEntry struct {
+	Time     time.Time
+	Level    string
+	Provider string
+	Message  string
+}
+
+// GetRecentNetworkEvents returns the HNS and networking event log entries of the last since duration,
+// newest first and bounded to the most recent maxNetworkEvents entries.
+func GetRecentNetworkEvents(execClient ExecClient, since time.Duration) ([]EventLogEntry, error) {
+	cmd := fmt.Sprintf("Get-WinEvent -FilterHashtable @{LogName='%s'; StartTime=(Get-Date).AddSeconds(-%d)} "+
+		"-MaxEvents %d -ErrorAction SilentlyContinue | Select-Object "+
+		"@{Name='TimeCreated'; Expression={$_.TimeCreated.ToUniversalTime().ToString('o')}}, "+
+		"LevelDisplayName, ProviderName, Message",
+		strings.Join(networkEventLogNames, "','"), int64(since.Seconds()), maxNetworkEvents)
+	rawEntries, err := ExecutePowershellJSON[[]struct {
+		TimeCreated      string
+		LevelDisplayName string
+		ProviderName     string
+		Message          string
+	}](execClient, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network events: %w", err)
+	}
+
+	entries := make([]EventLogEntry, 0, len(rawEntries))
+	for _, raw := range rawEntries {
+		entryTime, err := time.Parse(time.RFC3339Nano, raw.TimeCreated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse time %q of network event: %w", raw.TimeCreated, err)
+		}
+
+		entries = append(entries, EventLogEntry{
+			Time:     entryTime,
+			Level:    raw.LevelDisplayName,
+			Provider: raw.ProviderName,
+			Message:  strings.TrimSpace(raw.Message),
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const networkEventsFixture = `[
+    {
+        "TimeCreated":  "2023-05-16T10:04:12.5432109Z",
+        "LevelDisplayName":  "Error",
+        "ProviderName":  "Microsoft-Windows-Host-Network-Service",
+        "Message":  "HNS failed to create the endpoint.\r\n"
+    },
+    {
+        "TimeCreated":  "2023-05-16T10:01:00.0000000Z",
+        "LevelDisplayName":  "Information",
+        "ProviderName":  "Microsoft-Windows-TCPIP",
+        "Message":  "Interface 12 connected."
+    }
+]`
+
+func TestGetRecentNetworkEvents(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.True(t, strings.HasPrefix(cmd, "Get-WinEvent"))
+		assert.Contains(t, cmd, "StartTime=(Get-Date).AddSeconds(-900)")
+		assert.Contains(t, cmd, "-MaxEvents 200")
+		return networkEventsFixture, nil
+	})
+
+	entries, err := GetRecentNetworkEvents(mockExecClient, 15*time.Minute)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, time.Date(2023, 5, 16, 10, 4, 12, 543210900, time.UTC), entries[0].Time)
+	assert.Equal(t, "Error", entries[0].Level)
+	assert.Equal(t, "Microsoft-Windows-Host-Network-Service", entries[0].Provider)
+	assert.Equal(t, "HNS failed to create the endpoint.", entries[0].Message)
+
+	assert.Equal(t, "Information", entries[1].Level)
+	assert.Equal(t, "Interface 12 connected.", entries[1].Message)
+}
+
+func TestGetRecentNetworkEventsEmpty(t *testing.T) {
+	entries, err := GetRecentNetworkEvents(NewMockExecClient(false), time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestGetRecentNetworkEventsError(t *testing.T) {
+	_, err := GetRecentNetworkEvents(NewMockExecClient(true), time.Hour)
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `{"TimeCreated": "yesterday", "LevelDisplayName": "Error", "ProviderName": "HNS", "Message": ""}`, nil
+	})
+	_, err = GetRecentNetworkEvents(mockExecClient, time.Hour)
+	require.Error(t, err)
+}
+
+// fakeEventLogWriter records the entries written to it
+type fakeEventLogWriter struct {
+	entries []string
+	closed  bool
+	err     error
+}
+
+func (w *fakeEventLogWriter) Info(eid uint32, msg string) error {
+	return w.write("Information", eid, msg)
+}
+
+func (w *fakeEventLogWriter) Warning(eid uint32, msg string) error {
+	return w.write("Warning", eid, msg)
+}
+
+func (w *fakeEventLogWriter) Error(eid uint32, msg string) error {
+	return w.write("Error", eid, msg)
+}
+
+func (w *fakeEventLogWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func (w *fakeEventLogWriter) write(level string, eid uint32, msg string) error {
+	w.entries = append(w.entries, fmt.Sprintf("%s %d %s", level, eid, msg))
+	return w.err
+}
+
+// useFakeEventLog makes the event log entries be written to the returned writer for the duration of the test
+func useFakeEventLog(t *testing.T) (*fakeEventLogWriter, *[]string) {
+	w := &fakeEventLogWriter{}
+	var sources []string
+	openEventLog = func(source string) (eventLogWriter, error) {
+		sources = append(sources, source)
+		return w, nil
+	}
+	t.Cleanup(func() { openEventLog = openRegisteredEventLog })
+	return w, &sources
+}
+
+func TestWriteEventLog(t *testing.T) {
+	w, sources := useFakeEventLog(t)
+
+	require.NoError(t, WriteEventLog("azure-cns", "started", EventLevelInformation))
+	require.NoError(t, WriteEventLog("azure-cns", "hns is slow", EventLevelWarning))
+	require.NoError(t, WriteEventLog("azure-cns", "hns restart failed", EventLevelError))
+	assert.Equal(t, []string{"Information 1 started", "Warning 1 hns is slow", "Error 1 hns restart failed"}, w.entries)
+	assert.Equal(t, []string{"azure-cns", "azure-cns", "azure-cns"}, *sources)
+	assert.True(t, w.closed)
+}
+
+func TestWriteEventLogError(t *testing.T) {
+	w, _ := useFakeEventLog(t)
+	require.Error(t, WriteEventLog("azure-cns", "unknown", EventLevel(7)))
+	assert.Empty(t, w.entries)
+
+	w.err = ErrMockExec
+	require.ErrorIs(t, WriteEventLog("azure-cns", "hns restart failed", EventLevelError), ErrMockExec)
+
+	openEventLog = func(string) (eventLogWriter, error) { return nil, ErrMockExec }
+	require.ErrorIs(t, WriteEventLog("azure-cns", "hns restart failed", EventLevelError), ErrMockExec)
+}
@@ -0,0 +1,36 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+// flowControlKeyword is the registry keyword of the flow control setting of an adapter
+const flowControlKeyword = "*FlowControl"
+
+// Flow control modes of an adapter
+const (
+	FlowControlDisabled    = "Disabled"
+	FlowControlTxEnabled   = "TxEnabled"
+	FlowControlRxEnabled   = "RxEnabled"
+	FlowControlRxTxEnabled = "RxTxEnabled"
+)
+
+// flowControlRegistryValues maps the flow control modes to their standardized registry values
+var flowControlRegistryValues = map[string]string{
+	FlowControlDisabled:    "0",
+	FlowControlTxEnabled:   "1",
+	FlowControlRxEnabled:   "2",
+	FlowControlRxTxEnabled: "3",
+}
+
+// GetFlowControl returns the flow control mode of the adapter.
+// Returns ErrFeatureUnsupported if the adapter has no flow control setting.
+func GetFlowControl(execClient ExecClient, adapterName string) (string, error) {
+	return getEnumAdvancedProperty(execClient, adapterName, flowControlKeyword, flowControlRegistryValues)
+}
+
+// SetFlowControl sets the flow control mode of the adapter to one of
+// FlowControlDisabled, FlowControlTxEnabled, FlowControlRxEnabled or FlowControlRxTxEnabled.
+// Returns ErrFeatureUnsupported if the adapter has no flow control setting.
+func SetFlowControl(execClient ExecClient, adapterName, mode string) error {
+	return setEnumAdvancedProperty(execClient, adapterName, flowControlKeyword, flowControlRegistryValues, mode)
+}
@@ -0,0 +1,37 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+)
+
+// unknownNumaNode is the NUMA node reported for adapters whose NUMA node is unknown, e.g. on hosts without NUMA
+const unknownNumaNode = 0xFFFF
+
+// ErrNumaNodeUnknown is returned when the NUMA node an adapter is attached to is unknown
+var ErrNumaNodeUnknown = errors.New("NUMA node unknown")
+
+// GetAdapterNumaNode returns the NUMA node the adapter is attached to.
+// Returns -1 and ErrNumaNodeUnknown if it is unknown, e.g. for virtual adapters or hosts without NUMA.
+func GetAdapterNumaNode(execClient ExecClient, adapterName string) (int, error) {
+	cmd := fmt.Sprintf("Get-NetAdapterHardwareInfo -Name '%s' | Select-Object NumaNode", escapePowershellString(adapterName))
+	info, err := ExecutePowershellJSON[struct {
+		NumaNode int
+	}](execClient, cmd)
+	if isNoCimObjectsFoundError(err) {
+		return -1, fmt.Errorf("%s has no hardware info: %w", adapterName, ErrNumaNodeUnknown)
+	}
+
+	if err != nil {
+		return -1, fmt.Errorf("failed to get hardware info of %s: %w", adapterName, err)
+	}
+
+	if info.NumaNode == unknownNumaNode {
+		return -1, fmt.Errorf("%s: %w", adapterName, ErrNumaNodeUnknown)
+	}
+
+	return info.NumaNode, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAdapterNumaNode(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, "Get-NetAdapterHardwareInfo -Name 'Ethernet 2' | Select-Object NumaNode | ConvertTo-Json -Depth 10", cmd)
+		return `{"NumaNode": 1}`, nil
+	})
+
+	node, err := GetAdapterNumaNode(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, node)
+}
+
+func TestGetAdapterNumaNodeUnknown(t *testing.T) {
+	for _, response := range []struct {
+		out string
+		err error
+	}{
+		{out: `{"NumaNode": 65535}`},
+		{err: errors.New("Get-NetAdapterHardwareInfo : No matching MSFT_NetAdapterHardwareInfoSettingData objects found")},
+	} {
+		response := response
+		mockExecClient := NewMockExecClient(false)
+		mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+			return response.out, response.err
+		})
+
+		node, err := GetAdapterNumaNode(mockExecClient, "vEthernet (Ethernet 2)")
+		require.ErrorIs(t, err, ErrNumaNodeUnknown)
+		assert.Equal(t, -1, node)
+	}
+}
+
+func TestGetAdapterNumaNodeError(t *testing.T) {
+	node, err := GetAdapterNumaNode(NewMockExecClient(true), "Ethernet 2")
+	require.ErrorIs(t, err, ErrMockExec)
+	assert.Equal(t, -1, node)
+}
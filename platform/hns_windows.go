@@ -0,0 +1,456 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Command to check if the hns service is installed
+	CheckIfHNSServiceExistsCommand = "$null -ne (Get-Service -Name hns -ErrorAction SilentlyContinue)"
+
+	// Command to check if the hns state registry key exists
+	CheckIfHNSStatePathExistsCommand = "Test-Path -Path " + hnsStateRegistryPath
+
+	// Command to get the state of the Hyper-V optional feature, e.g. Enabled, Disabled or EnablePending
+	GetHyperVFeatureStateCommand = "(Get-WindowsOptionalFeature -Online -FeatureName Microsoft-Hyper-V).State.ToString()"
+
+	// Command to check if the Hyper-V Host Compute Service, which hns depends on, is installed
+	CheckIfHostComputeServiceExistsCommand = "$null -ne (Get-Service -Name vmcompute -ErrorAction SilentlyContinue)"
+
+	// hyperVFeatureEnabledState is the state of the Hyper-V optional feature once enabled
+	hyperVFeatureEnabledState = "Enabled"
+
+	// hnsStateRegistryPath is the registry key holding the hns policy flags
+	hnsStateRegistryPath = "HKLM:\\SYSTEM\\CurrentControlSet\\Services\\hns\\State"
+
+	// Command to get the hns networks of the host
+	GetHNSNetworksCommand = "Get-HnsNetwork | ConvertTo-Json -Depth 10"
+
+	// Command to get the hns endpoints of the host
+	GetHNSEndpointsCommand = "Get-HnsEndpoint | ConvertTo-Json -Depth 10"
+
+	// Command to get the hns policy lists of the host, load balancers among them
+	GetHNSPolicyListsCommand = "Get-HnsPolicyList"
+
+	// Command to get the hns namespaces of the host
+	GetHNSNamespacesCommand = "Get-HnsNamespace"
+
+	// hnsEndpointResourceType is the type of the endpoint resources of hns namespaces
+	hnsEndpointResourceType = "Endpoint"
+
+	// hnsLoadBalancerPolicyType is the type of the policy of hns load balancer policy lists
+	hnsLoadBalancerPolicyType = "ELB"
+
+	// hnsEndpointReferencePrefix prefixes the ids of the endpoints referenced by hns policy lists
+	hnsEndpointReferencePrefix = "/endpoints/"
+)
+
+// hnsReadOnlyFields are the fields of the hns networks and endpoints set by hns, which it rejects on creation
+var hnsReadOnlyFields = []string{"ID", "State", "Resources", "SharedContainers"}
+
+// hnsStateValueNameRegex matches the valid names of hns state registry values
+var hnsStateValueNameRegex = regexp.MustCompile(`^\w+$`)
+
+// hnsMutex serializes the operations changing the hns state, e.g. so that restarting hns does not race
+// the creation of an endpoint. Operations only reading the hns state don't take it.
+var hnsMutex sync.Mutex
+
+// withHNSLock runs fn holding hnsMutex
+func withHNSLock(fn func() error) error {
+	hnsMutex.Lock()
+	defer hnsMutex.Unlock()
+	return fn()
+}
+
+// HNSConfiguration is the set of hns networks and endpoints of a host, as reported by hns
+type HNSConfiguration struct {
+	Networks  []json.RawMessage `json:"networks"`
+	Endpoints []json.RawMessage `json:"endpoints"`
+}
+
+// HNSLoadBalancerSummary is a load balancer of hns, as configured by kube-proxy for a service
+type HNSLoadBalancerSummary struct {
+	ID           string   `json:"id"`
+	VIPs         []string `json:"vips"`
+	Protocol     int      `json:"protocol"`
+	InternalPort int      `json:"internalPort"`
+	ExternalPort int      `json:"externalPort"`
+	Endpoints    []string `json:"endpoints"`
+}
+
+// HNSNamespaceSummary is a network namespace of hns, i.e. the compartment of the endpoints of a pod
+type HNSNamespaceSummary struct {
+	ID            string   `json:"id"`
+	CompartmentID int      `json:"compartmentId"`
+	IsDefault     bool     `json:"isDefault"`
+	Endpoints     []string `json:"endpoints"`
+}
+
+// IsHNSEnabled returns whether the host has the hns service and its state registry key.
+// A host without HNS is not an error.
+func IsHNSEnabled(execClient ExecClient) (bool, error) {
+	for _, cmd := range []string{CheckIfHNSServiceExistsCommand, CheckIfHNSStatePathExistsCommand} {
+		out, err := execClient.ExecutePowershellCommand(cmd)
+		if err != nil {
+			return false, fmt.Errorf("failed to check if hns is enabled: %w", err)
+		}
+
+		exists, err := parsePowershellBool(out)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse output %q of %q: %w", out, cmd, err)
+		}
+
+		if !exists {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// IsHyperVEnabled returns whether the Hyper-V Host Compute Service, which hns operations require, is installed,
+// or else whether the Hyper-V optional feature is enabled. The service is checked first as it is also installed by
+// the Containers feature, with the Hyper-V feature itself disabled. A feature pending a reboot to be enabled is not.
+func IsHyperVEnabled(execClient ExecClient) (bool, error) {
+	out, serviceErr := execClient.ExecutePowershellCommand(CheckIfHostComputeServiceExistsCommand)
+	if serviceErr == nil {
+		exists, err := parsePowershellBool(out)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse output %q of %q: %w", out, CheckIfHostComputeServiceExistsCommand, err)
+		}
+
+		if exists {
+			return true, nil
+		}
+	}
+
+	state, err := execClient.ExecutePowershellCommand(GetHyperVFeatureStateCommand)
+	if err != nil {
+		if serviceErr != nil {
+			return false, fmt.Errorf("failed to check if Hyper-V is enabled: %w", serviceErr)
+		}
+
+		// e.g. hosts without the DISM cmdlets
+		log.Printf("Failed to get Hyper-V feature state, the host compute service is not installed: %v", err)
+		return false, nil
+	}
+
+	return strings.TrimSpace(state) == hyperVFeatureEnabledState, nil
+}
+
+// ExportHNSConfiguration returns the hns networks and endpoints of the host serialized as an HNSConfiguration,
+// e.g. to back them up or to reproduce the networking of a node offline
+func ExportHNSConfiguration(execClient ExecClient) ([]byte, error) {
+	var config HNSConfiguration
+
+	out, err := execClient.ExecutePowershellCommand(GetHNSNetworksCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hns networks: %w", err)
+	}
+
+	if err = unmarshalPowershellJSONList(out, &config.Networks); err != nil {
+		return nil, fmt.Errorf("failed to parse hns networks: %w", err)
+	}
+
+	out, err = execClient.ExecutePowershellCommand(GetHNSEndpointsCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hns endpoints: %w", err)
+	}
+
+	if err = unmarshalPowershellJSONList(out, &config.Endpoints); err != nil {
+		return nil, fmt.Errorf("failed to parse hns endpoints: %w", err)
+	}
+
+	return json.Marshal(config)
+}
+
+// ImportHNSConfiguration recreates the hns networks and then the hns endpoints of an HNSConfiguration
+// exported by ExportHNSConfiguration. hns gives the recreated networks new ids, the endpoints are attached to
+// the recreated networks of the ones they were exported with.
+func ImportHNSConfiguration(execClient ExecClient, data []byte) error {
+	var config HNSConfiguration
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse hns configuration: %w", err)
+	}
+
+	return withHNSLock(func() error {
+		// ids of the recreated networks by the lowercase id they were exported with
+		networkIDs := make(map[string]string, len(config.Networks))
+		for _, network := range config.Networks {
+			exportedID, fields, err := parseExportedHNSObject(network)
+			if err != nil {
+				return fmt.Errorf("failed to parse hns network %s: %w", network, err)
+			}
+
+			jsonString, err := json.Marshal(fields)
+			if err != nil {
+				return fmt.Errorf("failed to serialize hns network %s: %w", network, err)
+			}
+
+			cmd := fmt.Sprintf("(New-HnsNetwork -JsonString '%s').ID", escapePowershellString(string(jsonString)))
+			out, err := execClient.ExecutePowershellCommand(cmd)
+			if err != nil {
+				return fmt.Errorf("failed to create hns network %s: %w", network, err)
+			}
+
+			networkIDs[strings.ToLower(exportedID)] = strings.TrimSpace(out)
+		}
+
+		for _, endpoint := range config.Endpoints {
+			_, fields, err := parseExportedHNSObject(endpoint)
+			if err != nil {
+				return fmt.Errorf("failed to parse hns endpoint %s: %w", endpoint, err)
+			}
+
+			var exportedNetworkID string
+			if raw, ok := fields["VirtualNetwork"]; ok {
+				if err = json.Unmarshal(raw, &exportedNetworkID); err != nil {
+					return fmt.Errorf("failed to parse network of hns endpoint %s: %w", endpoint, err)
+				}
+			}
+
+			networkID, ok := networkIDs[strings.ToLower(exportedNetworkID)]
+			if !ok {
+				return fmt.Errorf("hns endpoint %s is on network %q which is not in the configuration", endpoint, exportedNetworkID)
+			}
+
+			if fields["VirtualNetwork"], err = json.Marshal(networkID); err != nil {
+				return fmt.Errorf("failed to serialize network of hns endpoint %s: %w", endpoint, err)
+			}
+
+			jsonString, err := json.Marshal(fields)
+			if err != nil {
+				return fmt.Errorf("failed to serialize hns endpoint %s: %w", endpoint, err)
+			}
+
+			cmd := fmt.Sprintf("New-HnsEndpoint -JsonString '%s'", escapePowershellString(string(jsonString)))
+			if _, err = execClient.ExecutePowershellCommand(cmd); err != nil {
+				return fmt.Errorf("failed to create hns endpoint %s: %w", endpoint, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// parseExportedHNSObject returns the id of the exported hns object and its fields without those set by hns
+func parseExportedHNSObject(object json.RawMessage) (string, map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(object, &fields); err != nil {
+		return "", nil, fmt.Errorf("invalid hns object: %w", err)
+	}
+
+	var id string
+	if raw, ok := fields["ID"]; ok {
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return "", nil, fmt.Errorf("invalid hns object id %s: %w", raw, err)
+		}
+	}
+
+	for _, field := range hnsReadOnlyFields {
+		delete(fields, field)
+	}
+
+	return id, fields, nil
+}
+
+// GetHNSStateValue returns the named value of the hns state registry key
+func GetHNSStateValue(execClient ExecClient, name string) (string, error) {
+	if !hnsStateValueNameRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid hns state value name %q", name)
+	}
+
+	cmd := fmt.Sprintf("(Get-ItemProperty -Path %s -Name %s).%s", hnsStateRegistryPath, name, name)
+	out, err := execClient.ExecutePowershellCommand(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get hns state value %s: %w", name, err)
+	}
+
+	return out, nil
+}
+
+// SetHNSStateValue sets the named value of the hns state registry key. HNS only reads the values at startup,
+// so restartHNS restarts it to apply the value.
+func SetHNSStateValue(execClient ExecClient, name, value string, restartHNS bool) error {
+	if !hnsStateValueNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid hns state value name %q", name)
+	}
+
+	cmd := fmt.Sprintf("Set-ItemProperty -Path %s -Name %s -Value '%s'", hnsStateRegistryPath, name, escapePowershellString(value))
+	return withHNSLock(func() error {
+		err := retryRegistryWrite(func() error {
+			_, err := execClient.ExecutePowershellCommand(cmd)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set hns state value %s: %w", name, err)
+		}
+
+		if !restartHNS {
+			return nil
+		}
+
+		if _, err := execClient.ExecutePowershellCommand(RestartHnsServiceCommand); err != nil {
+			return fmt.Errorf("failed to restart hns after setting %s: %w", name, err)
+		}
+
+		return nil
+	})
+}
+
+// ListHNSLoadBalancers returns the load balancers of hns, i.e. its policy lists with a load balancer policy,
+// with the ids of their backend endpoints
+func ListHNSLoadBalancers(execClient ExecClient) ([]HNSLoadBalancerSummary, error) {
+	policyLists, err := ExecutePowershellJSON[[]struct {
+		ID       string
+		Policies []struct {
+			Type         string
+			Protocol     int
+			InternalPort int
+			ExternalPort int
+			VIPs         []string
+		}
+		References []string
+	}](execClient, GetHNSPolicyListsCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hns policy lists: %w", err)
+	}
+
+	loadBalancers := []HNSLoadBalancerSummary{}
+	for _, policyList := range policyLists {
+		for _, policy := range policyList.Policies {
+			if policy.Type != hnsLoadBalancerPolicyType {
+				continue
+			}
+
+			endpoints := make([]string, 0, len(policyList.References))
+			for _, ref := range policyList.References {
+				endpoints = append(endpoints, strings.TrimPrefix(ref, hnsEndpointReferencePrefix))
+			}
+
+			loadBalancers = append(loadBalancers, HNSLoadBalancerSummary{
+				ID:           policyList.ID,
+				VIPs:         policy.VIPs,
+				Protocol:     policy.Protocol,
+				InternalPort: policy.InternalPort,
+				ExternalPort: policy.ExternalPort,
+				Endpoints:    endpoints,
+			})
+		}
+	}
+
+	return loadBalancers, nil
+}
+
+// ListHNSNamespaces returns the network namespaces of hns with the ids of their endpoints
+func ListHNSNamespaces(execClient ExecClient) ([]HNSNamespaceSummary, error) {
+	namespaces, err := ExecutePowershellJSON[[]struct {
+		ID            string
+		CompartmentID int `json:"CompartmentId"`
+		IsDefault     bool
+		Resources     []struct {
+			Type string
+			Data struct {
+				ID string `json:"Id"`
+			}
+		}
+	}](execClient, GetHNSNamespacesCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hns namespaces: %w", err)
+	}
+
+	summaries := make([]HNSNamespaceSummary, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		endpoints := []string{}
+		for _, resource := range namespace.Resources {
+			if resource.Type == hnsEndpointResourceType {
+				endpoints = append(endpoints, resource.Data.ID)
+			}
+		}
+
+		summaries = append(summaries, HNSNamespaceSummary{
+			ID:            namespace.ID,
+			CompartmentID: namespace.CompartmentID,
+			IsDefault:     namespace.IsDefault,
+			Endpoints:     endpoints,
+		})
+	}
+
+	return summaries, nil
+}
+
+// RemoveStaleHNSNamespaces removes the hns namespaces whose id is not one of activeIDs, e.g. the namespaces
+// of the running pods, and returns the number of namespaces removed. The default namespace of the host and the
+// namespaces with endpoints still attached are kept, and namespaces already removed meanwhile are not counted.
+// A namespace failing to be removed does not prevent the following ones from being removed.
+func RemoveStaleHNSNamespaces(execClient ExecClient, activeIDs []string) (int, error) {
+	active := make(map[string]bool, len(activeIDs))
+	for _, id := range activeIDs {
+		active[strings.ToLower(id)] = true
+	}
+
+	removed := 0
+	var failed []string
+	var firstErr error
+	err := withHNSLock(func() error {
+		// listing under the lock so that no endpoint is attached to a namespace between the listing and its removal
+		namespaces, err := ListHNSNamespaces(execClient)
+		if err != nil {
+			return err
+		}
+
+		for _, namespace := range namespaces {
+			if namespace.IsDefault || active[strings.ToLower(namespace.ID)] {
+				continue
+			}
+
+			if len(namespace.Endpoints) > 0 {
+				log.Printf("Keeping stale hns namespace %s, endpoints %v are still attached", namespace.ID, namespace.Endpoints)
+				continue
+			}
+
+			cmd := fmt.Sprintf("$namespace = %s | Where-Object { $_.ID -eq '%s' }; "+
+				"if ($namespace) { $namespace | Remove-HnsNamespace | Out-Null; $true } else { $false }",
+				GetHNSNamespacesCommand, escapePowershellString(namespace.ID))
+			out, err := execClient.ExecutePowershellCommand(cmd)
+			var found bool
+			if err == nil {
+				found, err = parsePowershellBool(out)
+			}
+
+			if err != nil {
+				log.Errorf("Failed to remove stale hns namespace %s, continuing: %v", namespace.ID, err)
+				failed = append(failed, namespace.ID)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+
+			if !found {
+				log.Printf("Stale hns namespace %s was already removed", namespace.ID)
+				continue
+			}
+
+			log.Printf("Removed stale hns namespace %s", namespace.ID)
+			removed++
+		}
+
+		if firstErr != nil {
+			return fmt.Errorf("failed to remove stale hns namespaces %v: %w", failed, firstErr)
+		}
+
+		return nil
+	})
+
+	return removed, err
+}
@@ -0,0 +1,525 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	hnsNetworkFixture = `{
+    "ID":  "8e6ec3a5-8c3b-4d7e-9c1a-0f1e2d3c4b5a",
+    "Name":  "azure",
+    "Type":  "L2Bridge",
+    "State":  1,
+    "Resources":  {"AllocationOrder": 2},
+    "Subnets":  [
+                    {
+                        "AddressPrefix":  "10.240.0.0/16",
+                        "GatewayAddress":  "10.240.0.1"
+                    }
+                ]
+}`
+
+	hnsEndpointsFixture = `[
+    {
+        "ID":  "1f2e3d4c-0000-4a1b-8c2d-111111111111",
+        "Name":  "pod-a",
+        "VirtualNetwork":  "8e6ec3a5-8c3b-4d7e-9c1a-0f1e2d3c4b5a",
+        "State":  1,
+        "SharedContainers":  ["2b3c4d5e"],
+        "IPAddress":  "10.240.0.4"
+    },
+    {
+        "ID":  "1f2e3d4c-0000-4a1b-8c2d-222222222222",
+        "Name":  "pod-b's",
+        "VirtualNetwork":  "8e6ec3a5-8c3b-4d7e-9c1a-0f1e2d3c4b5a",
+        "IPAddress":  "10.240.0.5"
+    }
+]`
+)
+
+func TestIsHNSEnabled(t *testing.T) {
+	tests := []struct {
+		name          string
+		serviceExists string
+		pathExists    string
+		expected      bool
+	}{
+		{"hns present", "True", "True", true},
+		{"no hns service", "False", "True", false},
+		{"no hns state key", "True", "False", false},
+		{"no hns", "False", "False", false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				if cmd == CheckIfHNSServiceExistsCommand {
+					return tt.serviceExists, nil
+				}
+				return tt.pathExists, nil
+			})
+
+			enabled, err := IsHNSEnabled(mockExecClient)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, enabled)
+		})
+	}
+}
+
+func TestIsHyperVEnabled(t *testing.T) {
+	tests := []struct {
+		name          string
+		featureState  string
+		featureErr    error
+		serviceExists string
+		serviceErr    error
+		expected      bool
+	}{
+		{name: "enabled", featureState: "Enabled", serviceExists: "False", expected: true},
+		{name: "disabled", featureState: "Disabled", serviceExists: "False", expected: false},
+		{name: "enable pending reboot", featureState: "EnablePending\r\n", serviceExists: "False", expected: false},
+		{name: "feature disabled, service present", featureState: "Disabled", serviceExists: "True", expected: true},
+		{name: "no feature cmdlet, service present", featureErr: ErrMockExec, serviceExists: "True", expected: true},
+		{name: "no feature cmdlet, no service", featureErr: ErrMockExec, serviceExists: "False", expected: false},
+		{name: "service check failed, feature enabled", featureState: "Enabled", serviceErr: ErrMockExec, expected: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				if cmd == GetHyperVFeatureStateCommand {
+					return tt.featureState, tt.featureErr
+				}
+				return tt.serviceExists, tt.serviceErr
+			})
+
+			enabled, err := IsHyperVEnabled(mockExecClient)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, enabled)
+		})
+	}
+}
+
+func TestIsHyperVEnabledError(t *testing.T) {
+	_, err := IsHyperVEnabled(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestIsHNSEnabledError(t *testing.T) {
+	_, err := IsHNSEnabled(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "unexpected", nil
+	})
+	_, err = IsHNSEnabled(mockExecClient)
+	require.Error(t, err)
+}
+
+func TestExportImportHNSConfiguration(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch cmd {
+		case GetHNSNetworksCommand:
+			return hnsNetworkFixture, nil
+		case GetHNSEndpointsCommand:
+			return hnsEndpointsFixture, nil
+		}
+		return "", nil
+	})
+
+	data, err := ExportHNSConfiguration(mockExecClient)
+	require.NoError(t, err)
+
+	var config HNSConfiguration
+	require.NoError(t, json.Unmarshal(data, &config))
+	require.Len(t, config.Networks, 1)
+	require.Len(t, config.Endpoints, 2)
+	assert.JSONEq(t, hnsNetworkFixture, string(config.Networks[0]))
+
+	// hns gives the recreated network a new id
+	mockExecClient = NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "(New-HnsNetwork") {
+			return "d4c3b2a1-0000-4e5f-8a9b-333333333333\r\n", nil
+		}
+		return "", nil
+	})
+	require.NoError(t, ImportHNSConfiguration(mockExecClient, data))
+
+	commands := mockExecClient.RecordedPowershellCommands()
+	require.Len(t, commands, 3)
+	assert.True(t, strings.HasPrefix(commands[0], "(New-HnsNetwork -JsonString '"))
+	assert.True(t, strings.HasSuffix(commands[0], "').ID"))
+	assert.True(t, strings.HasPrefix(commands[1], "New-HnsEndpoint -JsonString '"))
+	assert.True(t, strings.HasPrefix(commands[2], "New-HnsEndpoint -JsonString '"))
+	assert.Contains(t, commands[2], `"Name":"pod-b''s"`)
+
+	// the recreated objects are the exported ones without the fields set by hns,
+	// the endpoints being on the recreated network
+	jsonStrings := make([]string, len(commands))
+	for i, cmd := range commands {
+		jsonString := cmd[strings.Index(cmd, "'")+1 : strings.LastIndex(cmd, "'")]
+		jsonStrings[i] = strings.ReplaceAll(jsonString, "''", "'")
+	}
+	assert.JSONEq(t, `{
+    "Name":  "azure",
+    "Type":  "L2Bridge",
+    "Subnets":  [{"AddressPrefix":  "10.240.0.0/16", "GatewayAddress":  "10.240.0.1"}]
+}`, jsonStrings[0])
+	assert.JSONEq(t, `{
+    "Name":  "pod-a",
+    "VirtualNetwork":  "d4c3b2a1-0000-4e5f-8a9b-333333333333",
+    "IPAddress":  "10.240.0.4"
+}`, jsonStrings[1])
+	assert.JSONEq(t, `{
+    "Name":  "pod-b's",
+    "VirtualNetwork":  "d4c3b2a1-0000-4e5f-8a9b-333333333333",
+    "IPAddress":  "10.240.0.5"
+}`, jsonStrings[2])
+}
+
+func TestImportHNSConfigurationUnknownNetwork(t *testing.T) {
+	data := []byte(`{"networks": [], "endpoints": [{"ID": "1f2e3d4c", "VirtualNetwork": "8e6ec3a5"}]}`)
+
+	mockExecClient := NewMockExecClient(false)
+	require.Error(t, ImportHNSConfiguration(mockExecClient, data))
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestExportHNSConfigurationEmpty(t *testing.T) {
+	data, err := ExportHNSConfiguration(NewMockExecClient(false))
+	require.NoError(t, err)
+
+	var config HNSConfiguration
+	require.NoError(t, json.Unmarshal(data, &config))
+	assert.Empty(t, config.Networks)
+	assert.Empty(t, config.Endpoints)
+
+	mockExecClient := NewMockExecClient(false)
+	require.NoError(t, ImportHNSConfiguration(mockExecClient, data))
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestExportImportHNSConfigurationError(t *testing.T) {
+	_, err := ExportHNSConfiguration(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+
+	require.Error(t, ImportHNSConfiguration(NewMockExecClient(false), []byte("not json")))
+	require.ErrorIs(t, ImportHNSConfiguration(NewMockExecClient(true), []byte(`{"networks": [{"Name": "azure"}]}`)), ErrMockExec)
+}
+
+func TestGetHNSStateValue(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, "(Get-ItemProperty -Path HKLM:\\SYSTEM\\CurrentControlSet\\Services\\hns\\State -Name EnableCompartmentNamespace).EnableCompartmentNamespace", cmd)
+		return "1", nil
+	})
+
+	value, err := GetHNSStateValue(mockExecClient, "EnableCompartmentNamespace")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	_, err = GetHNSStateValue(mockExecClient, "Name; Restart-Computer")
+	require.Error(t, err)
+	assert.Len(t, mockExecClient.RecordedPowershellCommands(), 1)
+}
+
+func TestSetHNSStateValue(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	require.NoError(t, SetHNSStateValue(mockExecClient, "EnableCompartmentNamespace", "1", false))
+	require.NoError(t, SetHNSStateValue(mockExecClient, "SDNRemoteArpMacAddress", SDNRemoteArpMacAddress, true))
+	assert.Equal(t, []string{
+		"Set-ItemProperty -Path HKLM:\\SYSTEM\\CurrentControlSet\\Services\\hns\\State -Name EnableCompartmentNamespace -Value '1'",
+		SetSdnRemoteArpMacAddressCommand,
+		RestartHnsServiceCommand,
+	}, mockExecClient.RecordedPowershellCommands())
+
+	require.Error(t, SetHNSStateValue(mockExecClient, "", "1", false))
+	require.ErrorIs(t, SetHNSStateValue(NewMockExecClient(true), "EnableCompartmentNamespace", "1", true), ErrMockExec)
+}
+
+func TestHNSMutatingOperationsSerialize(t *testing.T) {
+	var inFlight, maxInFlight int32
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			highest := atomic.LoadInt32(&maxInFlight)
+			if n <= highest || atomic.CompareAndSwapInt32(&maxInFlight, highest, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return "", nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, SetHNSStateValue(mockExecClient, "SDNRemoteArpMacAddress", SDNRemoteArpMacAddress, true))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, ImportHNSConfiguration(mockExecClient, []byte(`{"networks": [{"Name": "azure"}], "endpoints": []}`)))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxInFlight)
+
+	// hns is restarted right after its state is set, without another operation in between
+	commands := mockExecClient.RecordedPowershellCommands()
+	require.Len(t, commands, 30)
+	for i, cmd := range commands {
+		if cmd == SetSdnRemoteArpMacAddressCommand {
+			assert.Equal(t, RestartHnsServiceCommand, commands[i+1])
+		}
+	}
+}
+
+func TestSetHNSStateValueRetriesAccessDenied(t *testing.T) {
+	shortenRegistryWriteRetryDelay(t)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence([]func(string) (string, error){
+		func(string) (string, error) { return "", errRegistryAccessDenied },
+		func(string) (string, error) { return "", nil },
+		func(string) (string, error) { return "", nil },
+	})
+
+	require.NoError(t, SetHNSStateValue(mockExecClient, "SDNRemoteArpMacAddress", SDNRemoteArpMacAddress, true))
+	assert.Equal(t, []string{
+		SetSdnRemoteArpMacAddressCommand,
+		SetSdnRemoteArpMacAddressCommand,
+		RestartHnsServiceCommand,
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+const (
+	hnsLoadBalancerFixture = `{
+    "ID":  "2f6f0b3e-1d5c-4a3b-9e2f-6a7b8c9d0e1f",
+    "Policies":  [
+                     {
+                         "Type":  "ELB",
+                         "Protocol":  6,
+                         "InternalPort":  8080,
+                         "ExternalPort":  80,
+                         "VIPs":  [
+                                      "10.0.0.10"
+                                  ],
+                         "ILB":  true
+                     }
+                 ],
+    "References":  [
+                       "/endpoints/9a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d",
+                       "/endpoints/1b2c3d4e-5f6a-7b8c-9d0e-1f2a3b4c5d6e"
+                   ]
+}`
+	hnsRoutePolicyListFixture = `{
+    "ID":  "7c8d9e0f-1a2b-3c4d-5e6f-7a8b9c0d1e2f",
+    "Policies":  [
+                     {
+                         "Type":  "ROUTE",
+                         "DestinationPrefix":  "10.240.0.0/16"
+                     }
+                 ],
+    "References":  [
+                       "/endpoints/9a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d"
+                   ]
+}`
+	hnsUDPLoadBalancerFixture = `{
+    "ID":  "3a4b5c6d-7e8f-9a0b-1c2d-3e4f5a6b7c8d",
+    "Policies":  [
+                     {
+                         "Type":  "ELB",
+                         "Protocol":  17,
+                         "InternalPort":  53,
+                         "ExternalPort":  53,
+                         "VIPs":  [
+                                      "10.0.0.10",
+                                      "fd00::a"
+                                  ]
+                     }
+                 ],
+    "References":  [
+                       "/endpoints/5e6f7a8b-9c0d-1e2f-3a4b-5c6d7e8f9a0b"
+                   ]
+}`
+)
+
+func TestListHNSLoadBalancers(t *testing.T) {
+	tcpLoadBalancer := HNSLoadBalancerSummary{
+		ID:           "2f6f0b3e-1d5c-4a3b-9e2f-6a7b8c9d0e1f",
+		VIPs:         []string{"10.0.0.10"},
+		Protocol:     6,
+		InternalPort: 8080,
+		ExternalPort: 80,
+		Endpoints:    []string{"9a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d", "1b2c3d4e-5f6a-7b8c-9d0e-1f2a3b4c5d6e"},
+	}
+	udpLoadBalancer := HNSLoadBalancerSummary{
+		ID:           "3a4b5c6d-7e8f-9a0b-1c2d-3e4f5a6b7c8d",
+		VIPs:         []string{"10.0.0.10", "fd00::a"},
+		Protocol:     17,
+		InternalPort: 53,
+		ExternalPort: 53,
+		Endpoints:    []string{"5e6f7a8b-9c0d-1e2f-3a4b-5c6d7e8f9a0b"},
+	}
+
+	tests := []struct {
+		name     string
+		output   string
+		expected []HNSLoadBalancerSummary
+	}{
+		{name: "no policy lists", output: "", expected: []HNSLoadBalancerSummary{}},
+		{name: "no load balancer", output: hnsRoutePolicyListFixture, expected: []HNSLoadBalancerSummary{}},
+		{name: "one load balancer", output: hnsLoadBalancerFixture, expected: []HNSLoadBalancerSummary{tcpLoadBalancer}},
+		{
+			name:     "multiple load balancers",
+			output:   "[" + hnsLoadBalancerFixture + "," + hnsRoutePolicyListFixture + "," + hnsUDPLoadBalancerFixture + "]",
+			expected: []HNSLoadBalancerSummary{tcpLoadBalancer, udpLoadBalancer},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				assert.Equal(t, GetHNSPolicyListsCommand+" | ConvertTo-Json -Depth 10", cmd)
+				return tt.output, nil
+			})
+
+			loadBalancers, err := ListHNSLoadBalancers(mockExecClient)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, loadBalancers)
+		})
+	}
+}
+
+func TestListHNSLoadBalancersError(t *testing.T) {
+	_, err := ListHNSLoadBalancers(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+const hnsNamespacesFixture = `[
+  {"ID": "3E5B8A5C-5F2C-4E0B-9C1B-0D7C2D4E6F10", "CompartmentId": 1, "IsDefault": true, "Resources": []},
+  {
+    "ID": "8D2A1C3B-7E4F-4A6B-8C9D-1E2F3A4B5C6D",
+    "CompartmentId": 2,
+    "IsDefault": false,
+    "Resources": [{"Type": "Endpoint", "Data": {"Id": "f2c9d3a1-2b4e-4c8d-9e7f-0a1b2c3d4e5f"}}]
+  },
+  {"ID": "A1B2C3D4-E5F6-4789-9ABC-DEF012345678", "CompartmentId": 3, "IsDefault": false, "Resources": []},
+  {"ID": "B7E6D5C4-A3B2-4C1D-8E9F-0A1B2C3D4E5F", "CompartmentId": 4, "IsDefault": false, "Resources": []}
+]`
+
+func TestListHNSNamespaces(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, GetHNSNamespacesCommand+" | ConvertTo-Json -Depth 10", cmd)
+		return hnsNamespacesFixture, nil
+	})
+
+	namespaces, err := ListHNSNamespaces(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, []HNSNamespaceSummary{
+		{ID: "3E5B8A5C-5F2C-4E0B-9C1B-0D7C2D4E6F10", CompartmentID: 1, IsDefault: true, Endpoints: []string{}},
+		{ID: "8D2A1C3B-7E4F-4A6B-8C9D-1E2F3A4B5C6D", CompartmentID: 2, Endpoints: []string{"f2c9d3a1-2b4e-4c8d-9e7f-0a1b2c3d4e5f"}},
+		{ID: "A1B2C3D4-E5F6-4789-9ABC-DEF012345678", CompartmentID: 3, Endpoints: []string{}},
+		{ID: "B7E6D5C4-A3B2-4C1D-8E9F-0A1B2C3D4E5F", CompartmentID: 4, Endpoints: []string{}},
+	}, namespaces)
+}
+
+func TestListHNSNamespacesSingle(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `{"ID": "3E5B8A5C-5F2C-4E0B-9C1B-0D7C2D4E6F10", "CompartmentId": 1, "IsDefault": true}`, nil
+	})
+
+	namespaces, err := ListHNSNamespaces(mockExecClient)
+	require.NoError(t, err)
+	require.Len(t, namespaces, 1)
+	assert.True(t, namespaces[0].IsDefault)
+}
+
+// hnsNamespacesResponder answers the powershell commands of a host with the namespaces of hnsNamespacesFixture,
+// failing the removal of the namespaces with the given ids
+func hnsNamespacesResponder(failedIDs ...string) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		if cmd == GetHNSNamespacesCommand+" | ConvertTo-Json -Depth 10" {
+			return hnsNamespacesFixture, nil
+		}
+
+		for _, id := range failedIDs {
+			if strings.Contains(cmd, id) {
+				return "", ErrMockExec
+			}
+		}
+		return "True", nil
+	}
+}
+
+func TestRemoveStaleHNSNamespaces(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(hnsNamespacesResponder())
+
+	// 8D2A1C3B has an endpoint attached
+	removed, err := RemoveStaleHNSNamespaces(mockExecClient, []string{"b7e6d5c4-a3b2-4c1d-8e9f-0a1b2c3d4e5f"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, []string{
+		GetHNSNamespacesCommand + " | ConvertTo-Json -Depth 10",
+		"$namespace = Get-HnsNamespace | Where-Object { $_.ID -eq 'A1B2C3D4-E5F6-4789-9ABC-DEF012345678' }; " +
+			"if ($namespace) { $namespace | Remove-HnsNamespace | Out-Null; $true } else { $false }",
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestRemoveStaleHNSNamespacesAlreadyRemoved(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	responder := hnsNamespacesResponder()
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if strings.Contains(cmd, "B7E6D5C4-A3B2-4C1D-8E9F-0A1B2C3D4E5F") {
+			return "False", nil
+		}
+		return responder(cmd)
+	})
+
+	removed, err := RemoveStaleHNSNamespaces(mockExecClient, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Len(t, mockExecClient.RecordedPowershellCommands(), 3)
+}
+
+func TestRemoveStaleHNSNamespacesContinuesOnFailure(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(hnsNamespacesResponder("A1B2C3D4-E5F6-4789-9ABC-DEF012345678"))
+
+	removed, err := RemoveStaleHNSNamespaces(mockExecClient, nil)
+	require.ErrorIs(t, err, ErrMockExec)
+	assert.Contains(t, err.Error(), "A1B2C3D4-E5F6-4789-9ABC-DEF012345678")
+	assert.Equal(t, 1, removed)
+	assert.Len(t, mockExecClient.RecordedPowershellCommands(), 3)
+
+	_, err = RemoveStaleHNSNamespaces(NewMockExecClient(true), nil)
+	require.ErrorIs(t, err, ErrMockExec)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// maxHNSEvents bounds the number of events of each hns event log collected by CollectHNSLogs
+const maxHNSEvents = 1000
+
+// hnsDiagnostics are the files written by CollectHNSLogs and the powershell commands whose output they hold
+var hnsDiagnostics = []struct {
+	file string
+	cmd  string
+}{
+	{"hns-networks.json", GetHNSNetworksCommand},
+	{"hns-endpoints.json", GetHNSEndpointsCommand},
+	{"hns-policylists.json", GetHNSPolicyListsCommand + " | ConvertTo-Json -Depth 10"},
+	{"hns-admin-events.txt", hnsEventsCommand("Microsoft-Windows-Host-Network-Service-Admin")},
+	{"hns-operational-events.txt", hnsEventsCommand("Microsoft-Windows-Host-Network-Service-Operational")},
+}
+
+// hnsEventsCommand returns the command formatting the latest events of the hns event log
+func hnsEventsCommand(logName string) string {
+	return fmt.Sprintf("Get-WinEvent -LogName %s -MaxEvents %d | "+
+		"Format-List TimeCreated, Id, LevelDisplayName, Message | Out-String -Width 4096", logName, maxHNSEvents)
+}
+
+// CollectHNSLogs writes the hns networks, endpoints and policy lists of the host and the latest hns events
+// to files in outputDir, created if needed, e.g. to attach them to an incident.
+// A diagnostic failing to be collected does not prevent the following ones from being collected,
+// the error names all of those which failed. Returns the context error if it is done before all are collected.
+func CollectHNSLogs(ctx context.Context, execClient ExecClient, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create hns logs directory %s: %w", outputDir, err)
+	}
+
+	var failed []string
+	var firstErr error
+	for _, diagnostic := range hnsDiagnostics {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("hns logs collection cancelled: %w", err)
+		}
+
+		path := filepath.Join(outputDir, diagnostic.file)
+		out, err := execClient.ExecutePowershellCommand(diagnostic.cmd)
+		if err == nil {
+			err = os.WriteFile(path, []byte(out), 0o644)
+		}
+
+		if err != nil {
+			log.Errorf("Failed to collect %s, continuing: %v", path, err)
+			failed = append(failed, diagnostic.file)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return fmt.Errorf("failed to collect hns logs %v: %w", failed, firstErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectHNSLogs(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "hns")
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		return "output of " + cmd, nil
+	})
+
+	require.NoError(t, CollectHNSLogs(context.Background(), mockExecClient, outputDir))
+	assert.Equal(t, []string{
+		"Get-HnsNetwork | ConvertTo-Json -Depth 10",
+		"Get-HnsEndpoint | ConvertTo-Json -Depth 10",
+		"Get-HnsPolicyList | ConvertTo-Json -Depth 10",
+		"Get-WinEvent -LogName Microsoft-Windows-Host-Network-Service-Admin -MaxEvents 1000 | " +
+			"Format-List TimeCreated, Id, LevelDisplayName, Message | Out-String -Width 4096",
+		"Get-WinEvent -LogName Microsoft-Windows-Host-Network-Service-Operational -MaxEvents 1000 | " +
+			"Format-List TimeCreated, Id, LevelDisplayName, Message | Out-String -Width 4096",
+	}, mockExecClient.RecordedPowershellCommands())
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "hns-endpoints.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "output of Get-HnsEndpoint | ConvertTo-Json -Depth 10", string(data))
+}
+
+func TestCollectHNSLogsContinuesOnFailure(t *testing.T) {
+	outputDir := t.TempDir()
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == GetHNSEndpointsCommand {
+			return "", ErrMockExec
+		}
+		return "[]", nil
+	})
+
+	err := CollectHNSLogs(context.Background(), mockExecClient, outputDir)
+	require.ErrorIs(t, err, ErrMockExec)
+	assert.Contains(t, err.Error(), "hns-endpoints.json")
+	assert.Len(t, mockExecClient.RecordedPowershellCommands(), len(hnsDiagnostics))
+
+	entries, err := os.ReadDir(outputDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, len(hnsDiagnostics)-1)
+}
+
+func TestCollectHNSLogsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mockExecClient := NewMockExecClient(false)
+	require.ErrorIs(t, CollectHNSLogs(ctx, mockExecClient, t.TempDir()), context.Canceled)
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// vmSizeURL is the IMDS endpoint returning the size of the VM
+	vmSizeURL = "http://169.254.169.254/metadata/instance/compute/vmSize?api-version=2021-02-01&format=text"
+
+	// imdsTimeout bounds the IMDS queries, IMDS is link local and answers quickly when reachable
+	imdsTimeout = 2 * time.Second
+)
+
+// vmSize caches the VM size for the lifetime of the process, it doesn't change while the VM runs
+var vmSize struct {
+	sync.Mutex
+	value string
+}
+
+// GetVMSize returns the size (SKU) of the VM as reported by the Azure Instance Metadata Service
+func GetVMSize(ctx context.Context) (string, error) {
+	return getVMSize(ctx, vmSizeURL)
+}
+
+func getVMSize(ctx context.Context, url string) (string, error) {
+	vmSize.Lock()
+	defer vmSize.Unlock()
+
+	if vmSize.value != "" {
+		return vmSize.value, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, imdsTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create IMDS request: %w", err)
+	}
+
+	req.Header.Set("Metadata", "true")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query IMDS for the VM size: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("IMDS returned unexpected status %s for the VM size", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read IMDS VM size response: %w", err)
+	}
+
+	value := strings.TrimSpace(string(body))
+	if value == "" {
+		return "", fmt.Errorf("IMDS returned an empty VM size")
+	}
+
+	vmSize.value = value
+	return value, nil
+}
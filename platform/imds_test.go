@@ -0,0 +1,81 @@
+package platform
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetVMSize() {
+	vmSize.Lock()
+	vmSize.value = ""
+	vmSize.Unlock()
+}
+
+func TestGetVMSize(t *testing.T) {
+	resetVMSize()
+	defer resetVMSize()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte("Standard_D8s_v3\n"))
+	}))
+	defer server.Close()
+
+	size, err := getVMSize(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "Standard_D8s_v3", size)
+
+	// the VM size is cached after the first query
+	size, err = getVMSize(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "Standard_D8s_v3", size)
+	assert.Equal(t, 1, requests)
+}
+
+func TestGetVMSizeError(t *testing.T) {
+	resetVMSize()
+	defer resetVMSize()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := getVMSize(context.Background(), server.URL)
+	require.Error(t, err)
+
+	// failures are not cached
+	vmSize.Lock()
+	assert.Empty(t, vmSize.value)
+	vmSize.Unlock()
+}
+
+func TestGetVMSizeTimeout(t *testing.T) {
+	resetVMSize()
+	defer resetVMSize()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := getVMSize(ctx, server.URL)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
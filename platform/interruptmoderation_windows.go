@@ -0,0 +1,32 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+// interruptModerationKeyword is the registry keyword of the interrupt moderation setting of an adapter
+const interruptModerationKeyword = "*InterruptModeration"
+
+// Interrupt moderation modes of an adapter
+const (
+	InterruptModerationDisabled = "Disabled"
+	InterruptModerationEnabled  = "Enabled"
+)
+
+// interruptModerationRegistryValues maps the interrupt moderation modes to their standardized registry values
+var interruptModerationRegistryValues = map[string]string{
+	InterruptModerationDisabled: "0",
+	InterruptModerationEnabled:  "1",
+}
+
+// GetInterruptModeration returns the interrupt moderation mode of the adapter.
+// Returns ErrFeatureUnsupported if the adapter has no interrupt moderation setting.
+func GetInterruptModeration(execClient ExecClient, adapterName string) (string, error) {
+	return getEnumAdvancedProperty(execClient, adapterName, interruptModerationKeyword, interruptModerationRegistryValues)
+}
+
+// SetInterruptModeration sets the interrupt moderation mode of the adapter to InterruptModerationDisabled,
+// trading CPU for latency, or InterruptModerationEnabled.
+// Returns ErrFeatureUnsupported if the adapter has no interrupt moderation setting.
+func SetInterruptModeration(execClient ExecClient, adapterName, mode string) error {
+	return setEnumAdvancedProperty(execClient, adapterName, interruptModerationKeyword, interruptModerationRegistryValues, mode)
+}
@@ -0,0 +1,293 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Command to get the IP addresses of the host and the aliases of the adapters they are assigned to
+const GetIPAddressAdaptersCommand = "Get-NetIPAddress | Select-Object IPAddress, InterfaceAlias"
+
+// Command to get the IP addresses of the host with their prefix length and the aliases of the adapters they are assigned to
+const GetIPAddressPrefixesCommand = "Get-NetIPAddress | Select-Object IPAddress, PrefixLength, InterfaceAlias"
+
+const (
+	// tcpip6ParametersRegistryPath is the registry key of the IPv6 stack parameters of the host
+	tcpip6ParametersRegistryPath = "HKLM:\\SYSTEM\\CurrentControlSet\\Services\\Tcpip6\\Parameters"
+
+	// disabledComponentsValueName is the name of the registry value disabling IPv6 components of the host
+	disabledComponentsValueName = "DisabledComponents"
+)
+
+// Bits of the DisabledComponents registry value
+const (
+	ipv6TunnelInterfacesDisabled    = 0x01
+	ipv6NonTunnelInterfacesDisabled = 0x10
+	ipv6PreferIPv4                  = 0x20
+	// ipv6AllDisabled disables all the IPv6 components but the loopback interface
+	ipv6AllDisabled = 0xFF
+)
+
+// ErrIPNotAssigned is returned when no adapter of the host has an IP address
+var ErrIPNotAssigned = errors.New("IP address not assigned to any adapter")
+
+// powershellAddressFamily returns the name of the address family in the NetTCPIP cmdlets
+func powershellAddressFamily(family AddressFamily) (string, error) {
+	switch family {
+	case AfINET:
+		return "IPv4", nil
+	case AfINET6:
+		return "IPv6", nil
+	default:
+		return "", fmt.Errorf("unsupported address family %d", family)
+	}
+}
+
+// GetInterfaceMetric returns the metric of the adapter's IP interface of the given address family
+func GetInterfaceMetric(execClient ExecClient, adapterName string, family AddressFamily) (int, error) {
+	af, err := powershellAddressFamily(family)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := fmt.Sprintf("Get-NetIPInterface -InterfaceAlias '%s' -AddressFamily %s | Select-Object -ExpandProperty InterfaceMetric",
+		escapePowershellString(adapterName), af)
+	out, err := execClient.ExecutePowershellCommand(cmd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s interface metric of %s: %w", af, adapterName, err)
+	}
+
+	metric, err := strconv.Atoi(out)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s interface metric %q of %s: %w", af, out, adapterName, err)
+	}
+
+	return metric, nil
+}
+
+// SetInterfaceMetric sets the metric of the adapter's IP interface of the given address family.
+// Routes through interfaces with lower metrics are preferred.
+// Returns ErrAdapterNotFound if there is no such adapter.
+func SetInterfaceMetric(execClient ExecClient, adapterName string, family AddressFamily, metric int) error {
+	if metric < 0 {
+		return fmt.Errorf("invalid interface metric %d", metric)
+	}
+
+	af, err := powershellAddressFamily(family)
+	if err != nil {
+		return err
+	}
+
+	na := &networkAdapter{execClient: execClient}
+	if err = na.checkAdapterExists(adapterName); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("Set-NetIPInterface -InterfaceAlias '%s' -AddressFamily %s -InterfaceMetric %d", escapePowershellString(adapterName), af, metric)
+	if _, err = execClient.ExecutePowershellCommand(cmd); err != nil {
+		return fmt.Errorf("failed to set %s interface metric of %s to %d: %w", af, adapterName, metric, err)
+	}
+
+	return nil
+}
+
+// Address states of IP addresses failing or not done with duplicate address detection
+const (
+	ipAddressStateTentative = "Tentative"
+	ipAddressStateDuplicate = "Duplicate"
+)
+
+// HasDuplicateIP returns whether the adapter has IP addresses which duplicate address detection found in use
+// by another host, or has not validated yet, and these addresses
+func HasDuplicateIP(execClient ExecClient, adapterName string) (bool, []string, error) {
+	cmd := fmt.Sprintf("Get-NetIPAddress -InterfaceAlias '%s' | Select-Object IPAddress, "+
+		"@{Name='AddressState'; Expression={$_.AddressState.ToString()}}", escapePowershellString(adapterName))
+	addresses, err := ExecutePowershellJSON[[]struct {
+		IPAddress    string
+		AddressState string
+	}](execClient, cmd)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to get IP addresses of %s: %w", adapterName, err)
+	}
+
+	var duplicates []string
+	for _, address := range addresses {
+		if address.AddressState == ipAddressStateDuplicate || address.AddressState == ipAddressStateTentative {
+			duplicates = append(duplicates, address.IPAddress)
+		}
+	}
+
+	return len(duplicates) > 0, duplicates, nil
+}
+
+// GetAdapterForIP returns the alias of the adapter the IP address is assigned to.
+// Returns ErrIPNotAssigned if no adapter has it.
+func GetAdapterForIP(execClient ExecClient, ip string) (string, error) {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return "", fmt.Errorf("invalid IP address %q", ip)
+	}
+
+	addresses, err := ExecutePowershellJSON[[]struct {
+		IPAddress      string
+		InterfaceAlias string
+	}](execClient, GetIPAddressAdaptersCommand)
+	if err != nil {
+		return "", fmt.Errorf("failed to get IP addresses: %w", err)
+	}
+
+	for _, address := range addresses {
+		// link-local IPv6 addresses may carry a zone, e.g. fe80::1%5
+		addr, _, _ := strings.Cut(address.IPAddress, "%")
+		if target.Equal(net.ParseIP(addr)) {
+			return address.InterfaceAlias, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s: %w", ip, ErrIPNotAssigned)
+}
+
+// SubnetConflict is a pair of overlapping subnets assigned to different adapters
+type SubnetConflict struct {
+	Adapter      string
+	Subnet       netip.Prefix
+	OtherAdapter string
+	OtherSubnet  netip.Prefix
+}
+
+// DetectOverlappingSubnets returns the overlapping subnets of the IP addresses assigned to different adapters,
+// which break routing between them. Loopback and link-local addresses, found on every adapter, are ignored.
+func DetectOverlappingSubnets(execClient ExecClient) ([]SubnetConflict, error) {
+	addresses, err := ExecutePowershellJSON[[]struct {
+		IPAddress      string
+		PrefixLength   int
+		InterfaceAlias string
+	}](execClient, GetIPAddressPrefixesCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get IP addresses: %w", err)
+	}
+
+	type assignment struct {
+		adapter string
+		subnet  netip.Prefix
+	}
+
+	var assignments []assignment
+	seen := make(map[assignment]bool)
+	for _, address := range addresses {
+		addr, err := netip.ParseAddr(address.IPAddress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse IP address %q of %s: %w", address.IPAddress, address.InterfaceAlias, err)
+		}
+
+		if addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+			continue
+		}
+
+		subnet, err := addr.WithZone("").Prefix(address.PrefixLength)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix length %d of %s on %s: %w", address.PrefixLength, addr, address.InterfaceAlias, err)
+		}
+
+		// addresses of the same subnet on one adapter are a single assignment
+		a := assignment{adapter: address.InterfaceAlias, subnet: subnet}
+		if !seen[a] {
+			seen[a] = true
+			assignments = append(assignments, a)
+		}
+	}
+
+	var conflicts []SubnetConflict
+	for i, a := range assignments {
+		for _, other := range assignments[i+1:] {
+			if a.adapter != other.adapter && a.subnet.Overlaps(other.subnet) {
+				conflicts = append(conflicts, SubnetConflict{
+					Adapter:      a.adapter,
+					Subnet:       a.subnet,
+					OtherAdapter: other.adapter,
+					OtherSubnet:  other.subnet,
+				})
+			}
+		}
+	}
+
+	return conflicts, nil
+}
+
+// IPv6Status is the state of the IPv6 stack of the host, as set by the DisabledComponents registry value
+type IPv6Status struct {
+	DisabledComponents       uint32
+	TunnelInterfacesDisabled bool
+	// NonTunnelInterfacesDisabled is whether IPv6 is disabled on the adapters, which is what breaks
+	// the components requiring IPv6
+	NonTunnelInterfacesDisabled bool
+	PreferIPv4                  bool
+	// Status is a human readable summary, e.g. disabled on non-tunnel interfaces, IPv4 preferred
+	Status string
+}
+
+// GetIPv6GlobalStatus returns the state of the IPv6 stack of the host, which is enabled unless the
+// DisabledComponents registry value is set. A warning is logged if IPv6 is disabled on the adapters.
+func GetIPv6GlobalStatus(execClient ExecClient) (IPv6Status, error) {
+	values, err := GetRegistryValues(execClient, tcpip6ParametersRegistryPath, []string{disabledComponentsValueName})
+	if errors.Is(err, ErrRegistryValueNotFound) {
+		return parseIPv6DisabledComponents(0), nil
+	}
+
+	if err != nil {
+		return IPv6Status{}, fmt.Errorf("failed to get IPv6 disabled components: %w", err)
+	}
+
+	// DWORD values are serialized as signed integers, e.g. 0xFFFFFFFF as -1
+	value, err := strconv.ParseInt(values[disabledComponentsValueName], 10, 64)
+	if err != nil {
+		return IPv6Status{}, fmt.Errorf("failed to parse IPv6 disabled components %q: %w", values[disabledComponentsValueName], err)
+	}
+
+	status := parseIPv6DisabledComponents(uint32(value))
+	if status.NonTunnelInterfacesDisabled {
+		log.Printf("IPv6 is %s (DisabledComponents 0x%X), components requiring IPv6 will fail", status.Status, status.DisabledComponents)
+	}
+
+	return status, nil
+}
+
+// parseIPv6DisabledComponents interprets the bits of the DisabledComponents registry value
+func parseIPv6DisabledComponents(disabledComponents uint32) IPv6Status {
+	status := IPv6Status{
+		DisabledComponents:          disabledComponents,
+		TunnelInterfacesDisabled:    disabledComponents&ipv6TunnelInterfacesDisabled != 0,
+		NonTunnelInterfacesDisabled: disabledComponents&ipv6NonTunnelInterfacesDisabled != 0,
+		PreferIPv4:                  disabledComponents&ipv6PreferIPv4 != 0,
+	}
+
+	var parts []string
+	switch {
+	case disabledComponents&ipv6AllDisabled == ipv6AllDisabled:
+		parts = append(parts, "disabled")
+	case status.TunnelInterfacesDisabled && status.NonTunnelInterfacesDisabled:
+		parts = append(parts, "disabled on all interfaces except loopback")
+	case status.NonTunnelInterfacesDisabled:
+		parts = append(parts, "disabled on non-tunnel interfaces")
+	case status.TunnelInterfacesDisabled:
+		parts = append(parts, "enabled", "disabled on tunnel interfaces")
+	default:
+		parts = append(parts, "enabled")
+	}
+
+	if status.PreferIPv4 && disabledComponents&ipv6AllDisabled != ipv6AllDisabled {
+		parts = append(parts, "IPv4 preferred")
+	}
+
+	status.Status = strings.Join(parts, ", ")
+	return status
+}
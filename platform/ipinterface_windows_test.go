@@ -0,0 +1,335 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetInterfaceMetric(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch cmd {
+		case "Get-NetIPInterface -InterfaceAlias 'Ethernet 2' -AddressFamily IPv4 | Select-Object -ExpandProperty InterfaceMetric":
+			return "15", nil
+		case "Get-NetIPInterface -InterfaceAlias 'Ethernet 2' -AddressFamily IPv6 | Select-Object -ExpandProperty InterfaceMetric":
+			return "25", nil
+		}
+		return "", nil
+	})
+
+	metric, err := GetInterfaceMetric(mockExecClient, "Ethernet 2", AfINET)
+	require.NoError(t, err)
+	assert.Equal(t, 15, metric)
+
+	metric, err = GetInterfaceMetric(mockExecClient, "Ethernet 2", AfINET6)
+	require.NoError(t, err)
+	assert.Equal(t, 25, metric)
+
+	_, err = GetInterfaceMetric(mockExecClient, "Ethernet 2", AfUnspec)
+	require.Error(t, err)
+
+	_, err = GetInterfaceMetric(NewMockExecClient(true), "Ethernet 2", AfINET)
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestSetInterfaceMetric(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	require.NoError(t, SetInterfaceMetric(mockExecClient, "Ethernet 2", AfINET, 5))
+	require.NoError(t, SetInterfaceMetric(mockExecClient, "Ethernet 2", AfINET6, 0))
+	assert.Equal(t, []string{
+		"Set-NetIPInterface -InterfaceAlias 'Ethernet 2' -AddressFamily IPv4 -InterfaceMetric 5",
+		"Set-NetIPInterface -InterfaceAlias 'Ethernet 2' -AddressFamily IPv6 -InterfaceMetric 0",
+	}, setCommands(mockExecClient.RecordedPowershellCommands()))
+}
+
+func TestSetInterfaceMetricUnknownAdapter(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	require.ErrorIs(t, SetInterfaceMetric(mockExecClient, "Ethernet 3", AfINET, 5), adapter.ErrAdapterNotFound)
+	assert.Equal(t, []string{GetAdapterNamesCommand}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestSetInterfaceMetricInvalid(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	require.Error(t, SetInterfaceMetric(mockExecClient, "Ethernet 2", AfINET, -1))
+	require.Error(t, SetInterfaceMetric(mockExecClient, "Ethernet 2", AfUnspec, 5))
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+}
+
+const ipAddressesFixture = `[
+    {
+        "IPAddress":  "fe80::1c2d:3e4f:5a6b:7c8d%12",
+        "AddressState":  "Preferred"
+    },
+    {
+        "IPAddress":  "10.224.0.4",
+        "AddressState":  "Preferred"
+    },
+    {
+        "IPAddress":  "10.224.0.5",
+        "AddressState":  "Duplicate"
+    },
+    {
+        "IPAddress":  "10.224.0.6",
+        "AddressState":  "Tentative"
+    }
+]`
+
+func TestHasDuplicateIP(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.True(t, strings.HasPrefix(cmd, "Get-NetIPAddress -InterfaceAlias 'Ethernet 2' |"), cmd)
+		return ipAddressesFixture, nil
+	})
+
+	duplicate, addresses, err := HasDuplicateIP(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.True(t, duplicate)
+	assert.Equal(t, []string{"10.224.0.5", "10.224.0.6"}, addresses)
+}
+
+func TestHasDuplicateIPNone(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `{"IPAddress": "10.224.0.4", "AddressState": "Preferred"}`, nil
+	})
+
+	duplicate, addresses, err := HasDuplicateIP(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+	assert.Empty(t, addresses)
+
+	_, _, err = HasDuplicateIP(NewMockExecClient(true), "Ethernet 2")
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+const ipAddressAdaptersFixture = `[
+  {"IPAddress": "fe80::7c1e:52ff:fe43:2b1a%6", "InterfaceAlias": "vEthernet (Ethernet 2)"},
+  {"IPAddress": "10.240.0.4", "InterfaceAlias": "vEthernet (Ethernet 2)"},
+  {"IPAddress": "172.27.64.1", "InterfaceAlias": "vEthernet (nat)"},
+  {"IPAddress": "2001:db8::4", "InterfaceAlias": "Ethernet 2"},
+  {"IPAddress": "127.0.0.1", "InterfaceAlias": "Loopback Pseudo-Interface 1"}
+]`
+
+func TestGetAdapterForIP(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{ip: "10.240.0.4", expected: "vEthernet (Ethernet 2)"},
+		{ip: "172.27.64.1", expected: "vEthernet (nat)"},
+		{ip: "2001:0db8:0000::0004", expected: "Ethernet 2"},
+		{ip: "fe80::7c1e:52ff:fe43:2b1a", expected: "vEthernet (Ethernet 2)"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.ip, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				assert.Equal(t, GetIPAddressAdaptersCommand+" | ConvertTo-Json -Depth 10", cmd)
+				return ipAddressAdaptersFixture, nil
+			})
+
+			adapterName, err := GetAdapterForIP(mockExecClient, tt.ip)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, adapterName)
+		})
+	}
+}
+
+func TestGetAdapterForIPNotAssigned(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return ipAddressAdaptersFixture, nil
+	})
+
+	_, err := GetAdapterForIP(mockExecClient, "10.240.0.5")
+	require.ErrorIs(t, err, ErrIPNotAssigned)
+}
+
+func TestGetAdapterForIPError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	_, err := GetAdapterForIP(mockExecClient, "10.240.0")
+	require.Error(t, err)
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+
+	_, err = GetAdapterForIP(NewMockExecClient(true), "10.240.0.4")
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestDetectOverlappingSubnets(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses string
+		expected  []SubnetConflict
+	}{
+		{
+			name: "disjoint",
+			addresses: `[
+  {"IPAddress": "fe80::7c1e:52ff:fe43:2b1a%6", "PrefixLength": 64, "InterfaceAlias": "vEthernet (Ethernet 2)"},
+  {"IPAddress": "fe80::1c2d:3eff:fe4f:5a6b%9", "PrefixLength": 64, "InterfaceAlias": "Ethernet 3"},
+  {"IPAddress": "10.240.0.4", "PrefixLength": 16, "InterfaceAlias": "vEthernet (Ethernet 2)"},
+  {"IPAddress": "10.240.0.5", "PrefixLength": 16, "InterfaceAlias": "vEthernet (Ethernet 2)"},
+  {"IPAddress": "172.27.64.1", "PrefixLength": 20, "InterfaceAlias": "vEthernet (nat)"},
+  {"IPAddress": "127.0.0.1", "PrefixLength": 8, "InterfaceAlias": "Loopback Pseudo-Interface 1"}
+]`,
+		},
+		{
+			name:      "single address",
+			addresses: `{"IPAddress": "10.240.0.4", "PrefixLength": 16, "InterfaceAlias": "vEthernet (Ethernet 2)"}`,
+		},
+		{
+			name: "overlapping",
+			addresses: `[
+  {"IPAddress": "10.240.0.4", "PrefixLength": 16, "InterfaceAlias": "vEthernet (Ethernet 2)"},
+  {"IPAddress": "10.240.8.4", "PrefixLength": 24, "InterfaceAlias": "Ethernet 3"},
+  {"IPAddress": "172.27.64.1", "PrefixLength": 20, "InterfaceAlias": "vEthernet (nat)"},
+  {"IPAddress": "2001:db8::4", "PrefixLength": 64, "InterfaceAlias": "vEthernet (Ethernet 2)"},
+  {"IPAddress": "2001:db8::5", "PrefixLength": 64, "InterfaceAlias": "Ethernet 3"}
+]`,
+			expected: []SubnetConflict{
+				{
+					Adapter:      "vEthernet (Ethernet 2)",
+					Subnet:       netip.MustParsePrefix("10.240.0.0/16"),
+					OtherAdapter: "Ethernet 3",
+					OtherSubnet:  netip.MustParsePrefix("10.240.8.0/24"),
+				},
+				{
+					Adapter:      "vEthernet (Ethernet 2)",
+					Subnet:       netip.MustParsePrefix("2001:db8::/64"),
+					OtherAdapter: "Ethernet 3",
+					OtherSubnet:  netip.MustParsePrefix("2001:db8::/64"),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				assert.Equal(t, GetIPAddressPrefixesCommand+" | ConvertTo-Json -Depth 10", cmd)
+				return tt.addresses, nil
+			})
+
+			conflicts, err := DetectOverlappingSubnets(mockExecClient)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, conflicts)
+		})
+	}
+}
+
+func TestDetectOverlappingSubnetsError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `[{"IPAddress": "10.240.0.4", "PrefixLength": 33, "InterfaceAlias": "Ethernet 2"}]`, nil
+	})
+	_, err := DetectOverlappingSubnets(mockExecClient)
+	require.Error(t, err)
+
+	_, err = DetectOverlappingSubnets(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestGetIPv6GlobalStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		out      string
+		expected IPv6Status
+	}{
+		{
+			name:     "no value",
+			out:      "",
+			expected: IPv6Status{Status: "enabled"},
+		},
+		{
+			name:     "enabled",
+			out:      `{"DisabledComponents": 0}`,
+			expected: IPv6Status{Status: "enabled"},
+		},
+		{
+			name:     "prefer IPv4",
+			out:      `{"DisabledComponents": 32}`,
+			expected: IPv6Status{DisabledComponents: 0x20, PreferIPv4: true, Status: "enabled, IPv4 preferred"},
+		},
+		{
+			name: "tunnel interfaces disabled",
+			out:  `{"DisabledComponents": 1}`,
+			expected: IPv6Status{
+				DisabledComponents: 0x01, TunnelInterfacesDisabled: true,
+				Status: "enabled, disabled on tunnel interfaces",
+			},
+		},
+		{
+			name: "non-tunnel interfaces disabled",
+			out:  `{"DisabledComponents": 16}`,
+			expected: IPv6Status{
+				DisabledComponents: 0x10, NonTunnelInterfacesDisabled: true,
+				Status: "disabled on non-tunnel interfaces",
+			},
+		},
+		{
+			name: "all interfaces disabled",
+			out:  `{"DisabledComponents": 17}`,
+			expected: IPv6Status{
+				DisabledComponents: 0x11, TunnelInterfacesDisabled: true, NonTunnelInterfacesDisabled: true,
+				Status: "disabled on all interfaces except loopback",
+			},
+		},
+		{
+			name: "disabled",
+			out:  `{"DisabledComponents": 255}`,
+			expected: IPv6Status{
+				DisabledComponents: 0xFF, TunnelInterfacesDisabled: true, NonTunnelInterfacesDisabled: true, PreferIPv4: true,
+				Status: "disabled",
+			},
+		},
+		{
+			name: "all bits set",
+			out:  `{"DisabledComponents": -1}`,
+			expected: IPv6Status{
+				DisabledComponents: 0xFFFFFFFF, TunnelInterfacesDisabled: true, NonTunnelInterfacesDisabled: true, PreferIPv4: true,
+				Status: "disabled",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				assert.Equal(t, "Get-ItemProperty -Path 'HKLM:\\SYSTEM\\CurrentControlSet\\Services\\Tcpip6\\Parameters' "+
+					"-Name 'DisabledComponents' -ErrorAction SilentlyContinue | Select-Object 'DisabledComponents' | ConvertTo-Json", cmd)
+				return tt.out, nil
+			})
+
+			status, err := GetIPv6GlobalStatus(mockExecClient)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, status)
+		})
+	}
+}
+
+func TestGetIPv6GlobalStatusError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `{"DisabledComponents": "0xff"}`, nil
+	})
+	_, err := GetIPv6GlobalStatus(mockExecClient)
+	require.Error(t, err)
+
+	_, err = GetIPv6GlobalStatus(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tailChunkSize is the size of the chunks read backwards from the end of a file by TailLogFile
+const tailChunkSize = 4096
+
+// TailLogFile returns the last lines of the log file at path, or all of its lines if it has fewer.
+// The file is read backwards from its end, so only the returned lines are read from large files.
+func TailLogFile(path string, lines int) ([]string, error) {
+	if lines <= 0 {
+		return nil, fmt.Errorf("invalid number of lines %d", lines)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat log file %s: %w", path, err)
+	}
+
+	// read chunks until the tail holds a newline before the first returned line
+	var tail []byte
+	newlines := 0
+	for offset := info.Size(); offset > 0 && newlines <= lines; {
+		chunkSize := int64(tailChunkSize)
+		if offset < chunkSize {
+			chunkSize = offset
+		}
+		offset -= chunkSize
+
+		chunk := make([]byte, chunkSize)
+		if _, err = f.ReadAt(chunk, offset); err != nil {
+			return nil, fmt.Errorf("failed to read log file %s: %w", path, err)
+		}
+
+		newlines += bytes.Count(chunk, []byte("\n"))
+		tail = append(chunk, tail...)
+	}
+
+	text := strings.TrimSuffix(string(tail), "\n")
+	if text == "" {
+		return []string{}, nil
+	}
+
+	result := strings.Split(text, "\n")
+	if len(result) > lines {
+		result = result[len(result)-lines:]
+	}
+
+	for i := range result {
+		result[i] = strings.TrimSuffix(result[i], "\r")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package platform
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLogFile writes a log file of n numbered lines to a temp directory and returns its path
+func writeLogFile(t *testing.T, n int, lineEnding string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "2023/01/02 15:04:05 [cni-net] line %d%s", i, lineEnding)
+	}
+
+	path := filepath.Join(t.TempDir(), "azure-vnet.log")
+	require.NoError(t, os.WriteFile(path, []byte(b.String()), 0o600))
+	return path
+}
+
+func TestTailLogFile(t *testing.T) {
+	// spans several chunks
+	path := writeLogFile(t, 1000, "\n")
+	lines, err := TailLogFile(path, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"2023/01/02 15:04:05 [cni-net] line 998",
+		"2023/01/02 15:04:05 [cni-net] line 999",
+		"2023/01/02 15:04:05 [cni-net] line 1000",
+	}, lines)
+
+	lines, err = TailLogFile(path, 500)
+	require.NoError(t, err)
+	require.Len(t, lines, 500)
+	assert.Equal(t, "2023/01/02 15:04:05 [cni-net] line 501", lines[0])
+}
+
+func TestTailLogFileFewerLines(t *testing.T) {
+	lines, err := TailLogFile(writeLogFile(t, 2, "\r\n"), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"2023/01/02 15:04:05 [cni-net] line 1",
+		"2023/01/02 15:04:05 [cni-net] line 2",
+	}, lines)
+
+	lines, err = TailLogFile(writeLogFile(t, 0, "\n"), 10)
+	require.NoError(t, err)
+	assert.Empty(t, lines)
+}
+
+func TestTailLogFileError(t *testing.T) {
+	_, err := TailLogFile(filepath.Join(t.TempDir(), "missing.log"), 10)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = TailLogFile(writeLogFile(t, 2, "\n"), 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,32 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// macAddressLength is the length in bytes of an IEEE 802 MAC-48 address
+const macAddressLength = 6
+
+// NormalizeMAC parses a dash, colon or dot separated MAC address in any case
+// and returns it in the canonical aa:bb:cc:dd:ee:ff form.
+func NormalizeMAC(s string) (string, error) {
+	if !strings.ContainsAny(s, "-:.") {
+		return "", fmt.Errorf("invalid MAC address %q: missing separators", s)
+	}
+
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid MAC address %q: %w", s, err)
+	}
+
+	if len(mac) != macAddressLength {
+		return "", fmt.Errorf("invalid MAC address %q: expected %d bytes but got %d", s, macAddressLength, len(mac))
+	}
+
+	return mac.String(), nil
+}
@@ -0,0 +1,45 @@
+package platform
+
+import (
+	"testing"
+)
+
+func TestNormalizeMAC(t *testing.T) {
+	tests := []string{
+		"12-34-56-78-9a-bc",
+		"12-34-56-78-9A-BC",
+		"12:34:56:78:9a:bc",
+		"12:34:56:78:9A:BC",
+		"1234.5678.9abc",
+		"1234.5678.9ABC",
+	}
+
+	for _, mac := range tests {
+		normalized, err := NormalizeMAC(mac)
+		if err != nil {
+			t.Errorf("NormalizeMAC(%q) failed: %v", mac, err)
+		}
+
+		if normalized != "12:34:56:78:9a:bc" {
+			t.Errorf("NormalizeMAC(%q) returned %q", mac, normalized)
+		}
+	}
+}
+
+func TestNormalizeMACInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"12-34-56-78-9a",
+		"12-34-56-78-9a-bc-de",
+		"12-34-56-78-9a-bg",
+		"12-34:56-78:9a-bc",
+		"123456789abc",
+		"00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01",
+	}
+
+	for _, mac := range tests {
+		if normalized, err := NormalizeMAC(mac); err == nil {
+			t.Errorf("NormalizeMAC(%q) should have failed but returned %q", mac, normalized)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// mellanoxSearchString matches the interface description of Mellanox adapters
+	mellanoxSearchString = "*Mellanox*"
+
+	// connectXSearchString matches the interface description of Mellanox ConnectX adapters,
+	// whose description may not contain Mellanox
+	connectXSearchString = "*ConnectX*"
+
+	// priorityVLANTagIdentifier is the registry keyword of the adapter's PriorityVLANTag
+	priorityVLANTagIdentifier = "*PriorityVLANTag"
+
+	// registryKeyPrefix is the registry path under which adapter driver keys are stored
+	registryKeyPrefix = "HKLM:\\System\\CurrentControlSet\\Control\\Class\\"
+
+	// DesiredMellanoxPriorityVLANTag is the PriorityVLANTag value required on Mellanox adapters.
+	// 3 means packet priority and VLAN are both enabled.
+	DesiredMellanoxPriorityVLANTag = 3
+
+	// Layouts of the Mellanox driver settings: advanced properties for driver version 4 and up,
+	// the driver registry key for driver version 3 and below
+	MellanoxRegistryLayoutV3 = "v3"
+	MellanoxRegistryLayoutV4 = "v4"
+
+	// Interval between successive checks of the Mellanox PriorityVLANTag
+	defaultMellanoxMonitorInterval = 30 * time.Second
+
+	// Number of consecutive failed checks of the Mellanox PriorityVLANTag after which the failure is reported
+	// to the Windows event log
+	mellanoxMonitorFailureThreshold = 5
+)
+
+// ErrMellanoxAdapterNotFound is returned when the host has no Mellanox adapter
+var ErrMellanoxAdapterNotFound = errors.New("no network adapter found with Mellanox or ConnectX in description")
+
+// mellanoxDescriptionPatterns match the interface description of Mellanox adapters, including ConnectX ones
+var mellanoxDescriptionPatterns = []string{mellanoxSearchString, connectXSearchString}
+
+// getMellanoxAdapterName returns the name of the Mellanox adapter of the host
+func getMellanoxAdapterName(execClient ExecClient) (string, error) {
+	adapterName, err := getAdapterNameByDescription(execClient, mellanoxDescriptionPatterns...)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Mellanox adapter name: %w", err)
+	}
+
+	if adapterName == "" {
+		return "", ErrMellanoxAdapterNotFound
+	}
+
+	return adapterName, nil
+}
+
+// MellanoxWorkaroundRequired returns whether the host has a Mellanox or ConnectX adapter, and the name of the first one.
+// Such an adapter is the virtual function exposed to VMs with accelerated networking, which is the only case
+// needing the PriorityVLANTag workaround, so the monitor need not run when there is none.
+func MellanoxWorkaroundRequired(execClient ExecClient) (bool, string, error) {
+	adapterName, err := getAdapterNameByDescription(execClient, mellanoxDescriptionPatterns...)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to get accelerated networking adapters: %w", err)
+	}
+
+	return adapterName != "", adapterName, nil
+}
+
+// MellanoxAdapterStatus is the PriorityVLANTag configuration of a Mellanox adapter
+type MellanoxAdapterStatus struct {
+	Name            string `json:"name"`
+	DriverVersion   string `json:"driverVersion"`
+	RegistryLayout  string `json:"registryLayout"`
+	PriorityVLANTag int    `json:"priorityVLANTag"`
+	Compliant       bool   `json:"compliant"`
+}
+
+// GetMellanoxAdaptersStatus returns the PriorityVLANTag configuration of each Mellanox adapter of the host,
+// compared to DesiredMellanoxPriorityVLANTag. Nothing is changed.
+func GetMellanoxAdaptersStatus(execClient ExecClient) ([]MellanoxAdapterStatus, error) {
+	adapterNames, err := getAdapterNamesByDescription(execClient, mellanoxDescriptionPatterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Mellanox adapter names: %w", err)
+	}
+
+	statuses := make([]MellanoxAdapterStatus, 0, len(adapterNames))
+	for _, adapterName := range adapterNames {
+		driverInfo, err := GetAdapterDriverInfo(execClient, adapterName)
+		if err != nil {
+			return nil, err
+		}
+
+		value, registryPath, err := getMellanoxPriorityVLANTag(execClient, adapterName)
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, MellanoxAdapterStatus{
+			Name:            adapterName,
+			DriverVersion:   driverInfo.DriverVersion,
+			RegistryLayout:  mellanoxRegistryLayout(registryPath),
+			PriorityVLANTag: value,
+			Compliant:       value == DesiredMellanoxPriorityVLANTag,
+		})
+	}
+
+	return statuses, nil
+}
+
+// SaveMellanoxConfigSnapshot writes the PriorityVLANTag configuration of the Mellanox adapters of the host
+// to the JSON file at path, to later detect external changes with CompareMellanoxConfigSnapshot
+func SaveMellanoxConfigSnapshot(execClient ExecClient, path string) error {
+	snapshot, err := getMellanoxConfigSnapshot(execClient)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize Mellanox configuration snapshot: %w", err)
+	}
+
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write Mellanox configuration snapshot %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// CompareMellanoxConfigSnapshot compares the PriorityVLANTag configuration of the Mellanox adapters of the host
+// to the snapshot written to path by SaveMellanoxConfigSnapshot, e.g. to find whether a driver update reset it.
+// Returns whether it changed and the sorted names of the adapters whose configuration changed, appeared or disappeared.
+func CompareMellanoxConfigSnapshot(execClient ExecClient, path string) (bool, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to read Mellanox configuration snapshot %s: %w", path, err)
+	}
+
+	var saved map[string]MellanoxAdapterStatus
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return false, nil, fmt.Errorf("failed to parse Mellanox configuration snapshot %s: %w", path, err)
+	}
+
+	current, err := getMellanoxConfigSnapshot(execClient)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var changed []string
+	for name, status := range current {
+		if savedStatus, ok := saved[name]; !ok || savedStatus != status {
+			changed = append(changed, name)
+		}
+	}
+
+	for name := range saved {
+		if _, ok := current[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+
+	sort.Strings(changed)
+	return len(changed) > 0, changed, nil
+}
+
+// getMellanoxConfigSnapshot returns the PriorityVLANTag configuration of the Mellanox adapters of the host by name
+func getMellanoxConfigSnapshot(execClient ExecClient) (map[string]MellanoxAdapterStatus, error) {
+	statuses, err := GetMellanoxAdaptersStatus(execClient)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]MellanoxAdapterStatus, len(statuses))
+	for _, status := range statuses {
+		snapshot[status.Name] = status
+	}
+
+	return snapshot, nil
+}
+
+// getMellanoxRegistryKeyPath returns the driver registry key of the named Mellanox adapter.
+// Drivers older than version 4 keep PriorityVLANTag only under this key.
+func getMellanoxRegistryKeyPath(execClient ExecClient, adapterName string) (string, error) {
+	cmd := fmt.Sprintf("Get-NetAdapter -Name '%s' | Select-Object -ExpandProperty PnPDeviceID", escapePowershellString(adapterName))
+	deviceID, err := execClient.ExecutePowershellCommand(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get device id of %s: %w", adapterName, err)
+	}
+
+	if deviceID == "" {
+		return "", ErrMellanoxAdapterNotFound
+	}
+
+	cmd = fmt.Sprintf("Get-PnpDeviceProperty -InstanceId '%s' | Where-Object KeyName -EQ 'DEVPKEY_Device_Driver' | "+
+		"Select-Object -ExpandProperty Data", escapePowershellString(deviceID))
+	driverKey, err := execClient.ExecutePowershellCommand(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Mellanox driver key: %w", err)
+	}
+
+	return registryKeyPrefix + driverKey, nil
+}
+
+// getMellanoxPriorityVLANTag returns the PriorityVLANTag value of the named adapter.
+// registryPath is empty when the value is exposed as an advanced property (driver version 4 and up),
+// otherwise it is the driver registry key holding the value (driver version 3 and below).
+func getMellanoxPriorityVLANTag(execClient ExecClient, adapterName string) (value int, registryPath string, err error) {
+	cmd := fmt.Sprintf("Get-NetAdapterAdvancedProperty -Name '%s' -RegistryKeyword '%s' -ErrorAction SilentlyContinue | "+
+		"Select-Object -ExpandProperty RegistryValue", escapePowershellString(adapterName), priorityVLANTagIdentifier)
+	out, err := execClient.ExecutePowershellCommand(cmd)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get PriorityVLANTag advanced property of %s: %w", adapterName, err)
+	}
+
+	if out == "" {
+		if registryPath, err = getMellanoxRegistryKeyPath(execClient, adapterName); err != nil {
+			return 0, "", err
+		}
+
+		cmd = fmt.Sprintf("Get-ItemProperty -Path '%s' -Name '%s' | Select-Object -ExpandProperty '%s'",
+			escapePowershellString(registryPath), priorityVLANTagIdentifier, priorityVLANTagIdentifier)
+		if out, err = execClient.ExecutePowershellCommand(cmd); err != nil {
+			return 0, "", fmt.Errorf("failed to get PriorityVLANTag registry value of %s: %w", adapterName, err)
+		}
+	}
+
+	if value, err = strconv.Atoi(strings.TrimSpace(out)); err != nil {
+		return 0, "", fmt.Errorf("failed to parse PriorityVLANTag value %q of %s: %w", out, adapterName, err)
+	}
+
+	return value, registryPath, nil
+}
+
+// GetMellanoxPriorityVLANTag returns the PriorityVLANTag value of the named Mellanox adapter
+func GetMellanoxPriorityVLANTag(execClient ExecClient, adapterName string) (int, error) {
+	value, _, err := getMellanoxPriorityVLANTag(execClient, adapterName)
+	return value, err
+}
+
+// VLANTagChangeResult is the outcome of setting the PriorityVLANTag of a Mellanox adapter
+type VLANTagChangeResult struct {
+	AdapterName string
+	// RegistryLayout is MellanoxRegistryLayoutV3 or MellanoxRegistryLayoutV4, depending on the driver version
+	RegistryLayout string
+	PreviousValue  int
+	NewValue       int
+	// Changed is whether the value had to be set, i.e. it was not already NewValue
+	Changed bool
+	// RequiresRestart is whether the adapter has to be restarted to apply the new value, which is only
+	// the case for the registry value of version 3 drivers. Advanced properties apply live.
+	RequiresRestart bool
+	// Restarted is whether the adapter was restarted, which it is not if the caller left the restart to itself
+	Restarted bool
+}
+
+// SetMellanoxPriorityVLANTag sets the PriorityVLANTag of the host's Mellanox adapter to desiredValue
+// if it is not already set. Driver versions 3 and below need an adapter restart to apply the value, which is done.
+func SetMellanoxPriorityVLANTag(execClient ExecClient, desiredValue int) error {
+	_, err := SetMellanoxPriorityVLANTagWithResult(execClient, desiredValue, true)
+	return err
+}
+
+// SetMellanoxPriorityVLANTagWithResult is SetMellanoxPriorityVLANTag, also returning whether and how the value changed.
+// The adapter is restarted when it is required only if restartAdapter is set. Callers not restarting it, e.g. to schedule
+// the brief outage, restart the adapter themselves when the change result RequiresRestart.
+func SetMellanoxPriorityVLANTagWithResult(execClient ExecClient, desiredValue int, restartAdapter bool) (VLANTagChangeResult, error) {
+	adapterName, err := getMellanoxAdapterName(execClient)
+	if err != nil {
+		return VLANTagChangeResult{}, err
+	}
+
+	return setMellanoxPriorityVLANTagOn(execClient, adapterName, desiredValue, restartAdapter)
+}
+
+// mellanoxRegistryLayout returns the registry layout of a Mellanox adapter whose PriorityVLANTag is held by
+// the driver registry key at registryPath, empty if it is an advanced property
+func mellanoxRegistryLayout(registryPath string) string {
+	if registryPath != "" {
+		return MellanoxRegistryLayoutV3
+	}
+
+	return MellanoxRegistryLayoutV4
+}
+
+// setMellanoxPriorityVLANTagOn sets the PriorityVLANTag of the named Mellanox adapter to desiredValue
+// if it is not already set, restarting the adapter when it is required if restartAdapter is set
+func setMellanoxPriorityVLANTagOn(execClient ExecClient, adapterName string, desiredValue int, restartAdapter bool) (VLANTagChangeResult, error) {
+	value, registryPath, err := getMellanoxPriorityVLANTag(execClient, adapterName)
+	if err != nil {
+		return VLANTagChangeResult{}, err
+	}
+
+	result := VLANTagChangeResult{
+		AdapterName:    adapterName,
+		RegistryLayout: mellanoxRegistryLayout(registryPath),
+		PreviousValue:  value,
+		NewValue:       desiredValue,
+		Changed:        value != desiredValue,
+	}
+
+	if !result.Changed {
+		return result, nil
+	}
+
+	log.Printf("Setting PriorityVLANTag of %s from %d to %d", adapterName, value, desiredValue)
+
+	if registryPath == "" {
+		cmd := fmt.Sprintf("Set-NetAdapterAdvancedProperty -Name '%s' -RegistryKeyword '%s' -RegistryValue %d",
+			escapePowershellString(adapterName), priorityVLANTagIdentifier, desiredValue)
+		if _, err = execClient.ExecutePowershellCommand(cmd); err != nil {
+			return VLANTagChangeResult{}, fmt.Errorf("failed to set PriorityVLANTag advanced property of %s: %w", adapterName, err)
+		}
+
+		return result, nil
+	}
+
+	cmd := fmt.Sprintf("New-ItemProperty -Path '%s' -Name '%s' -Value %d -PropertyType String -Force",
+		escapePowershellString(registryPath), priorityVLANTagIdentifier, desiredValue)
+	err = retryRegistryWrite(func() error {
+		_, err := execClient.ExecutePowershellCommand(cmd)
+		return err
+	})
+	if err != nil {
+		return VLANTagChangeResult{}, fmt.Errorf("failed to set PriorityVLANTag registry value of %s: %w", adapterName, err)
+	}
+
+	result.RequiresRestart = true
+	if !restartAdapter {
+		log.Printf("Not restarting adapter %s, PriorityVLANTag %d applies at its next restart", adapterName, desiredValue)
+		return result, nil
+	}
+
+	cmd = fmt.Sprintf("Restart-NetAdapter -Name '%s'", escapePowershellString(adapterName))
+	if _, err = execClient.ExecutePowershellCommand(cmd); err != nil {
+		return VLANTagChangeResult{}, fmt.Errorf("failed to restart adapter %s: %w", adapterName, err)
+	}
+
+	result.Restarted = true
+	return result, nil
+}
+
+// MonitorAndSetMellanoxRegKeyPriorityVLANTag checks the PriorityVLANTag of the host's Mellanox adapter every interval
+// and sets it to DesiredMellanoxPriorityVLANTag if it was changed, e.g. by a driver update. Returns when ctx is done.
+// The first check happens after a full interval, see MonitorAndSetMellanoxRegKeyPriorityVLANTagImmediately.
+func MonitorAndSetMellanoxRegKeyPriorityVLANTag(ctx context.Context, interval time.Duration, execClient ExecClient) {
+	monitorMellanoxPriorityVLANTag(ctx, interval, execClient, false)
+}
+
+// MonitorAndSetMellanoxRegKeyPriorityVLANTagImmediately is MonitorAndSetMellanoxRegKeyPriorityVLANTag checking the
+// PriorityVLANTag once right away, so that the adapter isn't left misconfigured for an interval after start.
+func MonitorAndSetMellanoxRegKeyPriorityVLANTagImmediately(ctx context.Context, interval time.Duration, execClient ExecClient) {
+	monitorMellanoxPriorityVLANTag(ctx, interval, execClient, true)
+}
+
+// monitorMellanoxPriorityVLANTag sets the PriorityVLANTag of the host's Mellanox adapter every interval,
+// and once before the first interval if immediate is set, until ctx is done
+func monitorMellanoxPriorityVLANTag(ctx context.Context, interval time.Duration, execClient ExecClient, immediate bool) {
+	if interval <= 0 {
+		interval = defaultMellanoxMonitorInterval
+	}
+
+	failures := 0
+	check := func() {
+		err := setMellanoxPriorityVLANTagIfPresent(execClient)
+		if err == nil {
+			failures = 0
+			return
+		}
+
+		log.Errorf("Failed to set Mellanox PriorityVLANTag, continuing: %v", err)
+		if failures++; failures == mellanoxMonitorFailureThreshold {
+			msg := fmt.Sprintf("Failed to set Mellanox PriorityVLANTag %d consecutive times: %v", failures, err)
+			if err = WriteEventLog(PlatformEventLogSource, msg, EventLevelError); err != nil {
+				log.Errorf("Failed to report Mellanox PriorityVLANTag failure to the event log: %v", err)
+			}
+		}
+	}
+
+	if immediate && ctx.Err() == nil {
+		check()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("context cancelled, stopping Mellanox PriorityVLANTag monitoring: %v", ctx.Err())
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
+
+// MonitorAndSetMellanoxRegKeyPriorityVLANTagFor runs MonitorAndSetMellanoxRegKeyPriorityVLANTag for at most maxDuration,
+// e.g. for diagnostic runs
+func MonitorAndSetMellanoxRegKeyPriorityVLANTagFor(ctx context.Context, interval, maxDuration time.Duration, execClient ExecClient) {
+	ctx, cancel := context.WithTimeout(ctx, maxDuration)
+	defer cancel()
+
+	MonitorAndSetMellanoxRegKeyPriorityVLANTag(ctx, interval, execClient)
+}
+
+// setMellanoxPriorityVLANTagIfPresent sets the PriorityVLANTag of the host's Mellanox adapter
+// if the host has one with its link up
+func setMellanoxPriorityVLANTagIfPresent(execClient ExecClient) error {
+	found, err := hasNetworkAdapter(NewNetworkAdapter(execClient))
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return nil
+	}
+
+	adapterName, err := getMellanoxAdapterName(execClient)
+	if errors.Is(err, ErrMellanoxAdapterNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	linkUp, err := IsAdapterLinkUp(execClient, adapterName)
+	if err != nil {
+		return err
+	}
+
+	if !linkUp {
+		log.Printf("Skipping PriorityVLANTag of %s, its media is disconnected", adapterName)
+		return nil
+	}
+
+	result, err := setMellanoxPriorityVLANTagOn(execClient, adapterName, DesiredMellanoxPriorityVLANTag, true)
+	if err != nil {
+		return err
+	}
+
+	if result.Restarted {
+		log.Printf("Restarted adapter %s to apply its PriorityVLANTag %d", adapterName, result.NewValue)
+	}
+
+	return nil
+}
@@ -0,0 +1,488 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMellanoxPriorityVLANTagSequence(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence([]func(string) (string, error){
+		func(cmd string) (string, error) {
+			assert.True(t, strings.HasPrefix(cmd, "Get-NetAdapter |"))
+			return "Ethernet 3", nil
+		},
+		func(cmd string) (string, error) {
+			assert.True(t, strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty -Name 'Ethernet 3'"))
+			return "0", nil
+		},
+		func(cmd string) (string, error) {
+			assert.Equal(t, "Set-NetAdapterAdvancedProperty -Name 'Ethernet 3' -RegistryKeyword '*PriorityVLANTag' -RegistryValue 3", cmd)
+			return "", nil
+		},
+	})
+
+	require.NoError(t, SetMellanoxPriorityVLANTag(mockExecClient, DesiredMellanoxPriorityVLANTag))
+
+	// the whole sequence was consumed
+	_, err := mockExecClient.ExecutePowershellCommand("")
+	require.ErrorIs(t, err, ErrMockExecSequenceExhausted)
+}
+
+func TestSetMellanoxPriorityVLANTagAlreadySet(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence([]func(string) (string, error){
+		func(string) (string, error) { return "Ethernet 3", nil },
+		func(string) (string, error) { return "3", nil },
+	})
+
+	require.NoError(t, SetMellanoxPriorityVLANTag(mockExecClient, DesiredMellanoxPriorityVLANTag))
+}
+
+// mellanoxV3Sequence answers the commands setting the PriorityVLANTag of a Mellanox adapter with a version 3 driver,
+// followed by the restart of the adapter if restart is set
+func mellanoxV3Sequence(t *testing.T, restart bool) []func(string) (string, error) {
+	registryPath := registryKeyPrefix + "{4d36e972-e325-11ce-bfc1-08002be10318}\\0001"
+	sequence := []func(string) (string, error){
+		func(string) (string, error) { return "Ethernet 3", nil },
+		// no advanced property on version 3 drivers
+		func(string) (string, error) { return "", nil },
+		func(cmd string) (string, error) {
+			assert.Equal(t, "Get-NetAdapter -Name 'Ethernet 3' | Select-Object -ExpandProperty PnPDeviceID", cmd)
+			return "PCI\\VEN_15B3&DEV_1016", nil
+		},
+		func(string) (string, error) { return "{4d36e972-e325-11ce-bfc1-08002be10318}\\0001", nil },
+		func(cmd string) (string, error) {
+			assert.Equal(t, "Get-ItemProperty -Path '"+registryPath+"' -Name '*PriorityVLANTag' | Select-Object -ExpandProperty '*PriorityVLANTag'", cmd)
+			return "0", nil
+		},
+		// the registry write is retried after failing transiently
+		func(string) (string, error) { return "", errRegistryAccessDenied },
+		func(cmd string) (string, error) {
+			assert.Equal(t, "New-ItemProperty -Path '"+registryPath+"' -Name '*PriorityVLANTag' -Value 3 -PropertyType String -Force", cmd)
+			return "", nil
+		},
+	}
+
+	if restart {
+		sequence = append(sequence, func(cmd string) (string, error) {
+			assert.Equal(t, "Restart-NetAdapter -Name 'Ethernet 3'", cmd)
+			return "", nil
+		})
+	}
+
+	return sequence
+}
+
+func TestSetMellanoxPriorityVLANTagV3(t *testing.T) {
+	shortenRegistryWriteRetryDelay(t)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence(mellanoxV3Sequence(t, true))
+
+	result, err := SetMellanoxPriorityVLANTagWithResult(mockExecClient, DesiredMellanoxPriorityVLANTag, true)
+	require.NoError(t, err)
+	assert.Equal(t, VLANTagChangeResult{
+		AdapterName:     "Ethernet 3",
+		RegistryLayout:  MellanoxRegistryLayoutV3,
+		PreviousValue:   0,
+		NewValue:        DesiredMellanoxPriorityVLANTag,
+		Changed:         true,
+		RequiresRestart: true,
+		Restarted:       true,
+	}, result)
+	assert.Contains(t, mockExecClient.RecordedPowershellCommands(), "Restart-NetAdapter -Name 'Ethernet 3'")
+}
+
+func TestSetMellanoxPriorityVLANTagV3RestartDisabled(t *testing.T) {
+	shortenRegistryWriteRetryDelay(t)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence(mellanoxV3Sequence(t, false))
+
+	result, err := SetMellanoxPriorityVLANTagWithResult(mockExecClient, DesiredMellanoxPriorityVLANTag, false)
+	require.NoError(t, err)
+	assert.True(t, result.RequiresRestart)
+	assert.False(t, result.Restarted)
+	assert.NotContains(t, mockExecClient.RecordedPowershellCommands(), "Restart-NetAdapter -Name 'Ethernet 3'")
+}
+
+func TestSetMellanoxPriorityVLANTagV4NoRestart(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(mellanoxPriorityVLANTagResponder(map[string]string{"Ethernet 3": "0"}))
+
+	result, err := SetMellanoxPriorityVLANTagWithResult(mockExecClient, DesiredMellanoxPriorityVLANTag, true)
+	require.NoError(t, err)
+	assert.True(t, result.Changed)
+	assert.False(t, result.RequiresRestart)
+	assert.False(t, result.Restarted)
+	for _, cmd := range mockExecClient.RecordedPowershellCommands() {
+		assert.False(t, strings.HasPrefix(cmd, "Restart-NetAdapter"), "adapter with a version 4 driver was restarted")
+	}
+}
+
+func TestSetMellanoxPriorityVLANTagWithResult(t *testing.T) {
+	tests := []struct {
+		name           string
+		value          string
+		expectedResult VLANTagChangeResult
+	}{
+		{
+			name:  "changed",
+			value: "0",
+			expectedResult: VLANTagChangeResult{
+				AdapterName:    "Ethernet 3",
+				RegistryLayout: MellanoxRegistryLayoutV4,
+				PreviousValue:  0,
+				NewValue:       DesiredMellanoxPriorityVLANTag,
+				Changed:        true,
+			},
+		},
+		{
+			name:  "already set",
+			value: "3",
+			expectedResult: VLANTagChangeResult{
+				AdapterName:    "Ethernet 3",
+				RegistryLayout: MellanoxRegistryLayoutV4,
+				PreviousValue:  DesiredMellanoxPriorityVLANTag,
+				NewValue:       DesiredMellanoxPriorityVLANTag,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(mellanoxPriorityVLANTagResponder(map[string]string{"Ethernet 3": tt.value}))
+
+			result, err := SetMellanoxPriorityVLANTagWithResult(mockExecClient, DesiredMellanoxPriorityVLANTag, true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedResult, result)
+		})
+	}
+}
+
+func TestSetMellanoxPriorityVLANTagNoAdapter(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence([]func(string) (string, error){
+		func(string) (string, error) { return "", nil },
+	})
+
+	require.ErrorIs(t, SetMellanoxPriorityVLANTag(mockExecClient, DesiredMellanoxPriorityVLANTag), ErrMellanoxAdapterNotFound)
+}
+
+func TestMonitorAndSetMellanoxRegKeyPriorityVLANTag(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch {
+		case cmd == GetAdapterNamesCommand:
+			return "Ethernet\r\nEthernet 3", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter |"):
+			return "Ethernet 3", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter -Name 'Ethernet 3' | Select-Object Status"):
+			return `{"Status": "Up", "MediaConnectionState": 1}`, nil
+		case strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty"):
+			return "0", nil
+		case strings.HasPrefix(cmd, "Set-NetAdapterAdvancedProperty"):
+			cancel()
+		}
+		return "", nil
+	})
+
+	MonitorAndSetMellanoxRegKeyPriorityVLANTag(ctx, time.Millisecond, mockExecClient)
+	assert.Contains(t, mockExecClient.RecordedPowershellCommands(),
+		"Set-NetAdapterAdvancedProperty -Name 'Ethernet 3' -RegistryKeyword '*PriorityVLANTag' -RegistryValue 3")
+}
+
+func TestMonitorAndSetMellanoxRegKeyPriorityVLANTagImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch {
+		case cmd == GetAdapterNamesCommand:
+			return "Ethernet\r\nEthernet 3", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter |"):
+			return "Ethernet 3", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter -Name 'Ethernet 3' | Select-Object Status"):
+			return `{"Status": "Up", "MediaConnectionState": 1}`, nil
+		case strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty"):
+			return "0", nil
+		case strings.HasPrefix(cmd, "Set-NetAdapterAdvancedProperty"):
+			cancel()
+		}
+		return "", nil
+	})
+
+	// the first tick would only come after an hour
+	start := time.Now()
+	MonitorAndSetMellanoxRegKeyPriorityVLANTagImmediately(ctx, time.Hour, mockExecClient)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Contains(t, mockExecClient.RecordedPowershellCommands(),
+		"Set-NetAdapterAdvancedProperty -Name 'Ethernet 3' -RegistryKeyword '*PriorityVLANTag' -RegistryValue 3")
+}
+
+func TestMonitorAndSetMellanoxRegKeyPriorityVLANTagV3Restart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the monitor restarts adapters with a version 3 driver, else the value set in the registry would never apply
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch {
+		case cmd == GetAdapterNamesCommand:
+			return "Ethernet\r\nEthernet 3", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter |"):
+			return "Ethernet 3", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter -Name 'Ethernet 3' | Select-Object Status"):
+			return `{"Status": "Up", "MediaConnectionState": 1}`, nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter -Name 'Ethernet 3' | Select-Object -ExpandProperty PnPDeviceID"):
+			return "PCI\\VEN_15B3&DEV_1016", nil
+		case strings.HasPrefix(cmd, "Get-PnpDeviceProperty"):
+			return "{4d36e972-e325-11ce-bfc1-08002be10318}\\0001", nil
+		case strings.HasPrefix(cmd, "Get-ItemProperty"):
+			return "0", nil
+		case strings.HasPrefix(cmd, "Restart-NetAdapter"):
+			cancel()
+		}
+		return "", nil
+	})
+
+	MonitorAndSetMellanoxRegKeyPriorityVLANTag(ctx, time.Millisecond, mockExecClient)
+	assert.Contains(t, mockExecClient.RecordedPowershellCommands(), "Restart-NetAdapter -Name 'Ethernet 3'")
+}
+
+func TestMonitorAndSetMellanoxRegKeyPriorityVLANTagWaitsFirstInterval(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	mockExecClient := NewMockExecClient(false)
+	MonitorAndSetMellanoxRegKeyPriorityVLANTag(ctx, time.Hour, mockExecClient)
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestMonitorAndSetMellanoxRegKeyPriorityVLANTagLinkDown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch {
+		case cmd == GetAdapterNamesCommand:
+			return "Ethernet\r\nEthernet 3", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter |"):
+			return "Ethernet 3", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter -Name 'Ethernet 3' | Select-Object Status"):
+			cancel()
+			return `{"Status": "Disconnected", "MediaConnectionState": 2}`, nil
+		}
+		return "", nil
+	})
+
+	MonitorAndSetMellanoxRegKeyPriorityVLANTag(ctx, time.Millisecond, mockExecClient)
+	for _, cmd := range mockExecClient.RecordedPowershellCommands() {
+		assert.False(t, strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty"), "PriorityVLANTag of a disconnected adapter was checked")
+	}
+}
+
+func TestMonitorAndSetMellanoxRegKeyPriorityVLANTagFor(t *testing.T) {
+	// a host without Mellanox adapter
+	checks := 0
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == GetAdapterNamesCommand {
+			checks++
+			return "Ethernet", nil
+		}
+		return "", nil
+	})
+
+	start := time.Now()
+	MonitorAndSetMellanoxRegKeyPriorityVLANTagFor(context.Background(), time.Millisecond, 50*time.Millisecond, mockExecClient)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Positive(t, checks)
+	assert.NotContains(t, strings.Join(mockExecClient.RecordedPowershellCommands(), "\n"), "Set-NetAdapterAdvancedProperty")
+}
+
+func TestGetMellanoxAdaptersStatus(t *testing.T) {
+	registryPath := registryKeyPrefix + "{4d36e972-e325-11ce-bfc1-08002be10318}\\0002"
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch {
+		case strings.HasPrefix(cmd, "Get-NetAdapter | Where-Object { $_.InterfaceDescription -like '*Mellanox*' -or $_.InterfaceDescription -like '*ConnectX*' }"):
+			return "Ethernet 3\r\nEthernet 4\r\n", nil
+		// Ethernet 3 has a version 4 driver with PriorityVLANTag set
+		case strings.HasPrefix(cmd, "Get-NetAdapter -Name 'Ethernet 3' | Select-Object DriverVersionString"):
+			return `{"DriverVersionString": "4.1.2", "DriverProvider": "Mellanox Technologies Ltd."}`, nil
+		case strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty -Name 'Ethernet 3' -RegistryKeyword '*PriorityVLANTag'"):
+			return "3", nil
+		// Ethernet 4 has a version 3 driver with PriorityVLANTag not set
+		case strings.HasPrefix(cmd, "Get-NetAdapter -Name 'Ethernet 4' | Select-Object DriverVersionString"):
+			return `{"DriverVersionString": "3.10.51000", "DriverProvider": "Mellanox Technologies Ltd."}`, nil
+		case cmd == "Get-NetAdapter -Name 'Ethernet 4' | Select-Object -ExpandProperty PnPDeviceID":
+			return "PCI\\VEN_15B3&DEV_1016&0002", nil
+		case strings.HasPrefix(cmd, "Get-PnpDeviceProperty -InstanceId 'PCI\\VEN_15B3&DEV_1016&0002'"):
+			return "{4d36e972-e325-11ce-bfc1-08002be10318}\\0002", nil
+		case strings.HasPrefix(cmd, "Get-ItemProperty -Path '"+registryPath+"'"):
+			return "0", nil
+		}
+		return "", nil
+	})
+
+	statuses, err := GetMellanoxAdaptersStatus(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, []MellanoxAdapterStatus{
+		{Name: "Ethernet 3", DriverVersion: "4.1.2", RegistryLayout: MellanoxRegistryLayoutV4, PriorityVLANTag: 3, Compliant: true},
+		{Name: "Ethernet 4", DriverVersion: "3.10.51000", RegistryLayout: MellanoxRegistryLayoutV3, PriorityVLANTag: 0, Compliant: false},
+	}, statuses)
+
+	// the status is read only
+	for _, cmd := range mockExecClient.RecordedPowershellCommands() {
+		assert.False(t, strings.HasPrefix(cmd, "Set-") || strings.HasPrefix(cmd, "New-") || strings.HasPrefix(cmd, "Restart-"), cmd)
+	}
+}
+
+func TestGetMellanoxAdaptersStatusNoAdapter(t *testing.T) {
+	statuses, err := GetMellanoxAdaptersStatus(NewMockExecClient(false))
+	require.NoError(t, err)
+	assert.Empty(t, statuses)
+
+	_, err = GetMellanoxAdaptersStatus(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestMellanoxWorkaroundRequired(t *testing.T) {
+	tests := []struct {
+		name         string
+		adapters     string
+		required     bool
+		expectedName string
+	}{
+		{"mellanox adapter", "Ethernet 3\r\n", true, "Ethernet 3"},
+		{"several adapters", "Ethernet 3\r\nEthernet 4\r\n", true, "Ethernet 3"},
+		{"no mellanox adapter", "", false, ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				assert.Contains(t, cmd, "-like '*Mellanox*' -or $_.InterfaceDescription -like '*ConnectX*'")
+				return tt.adapters, nil
+			})
+
+			required, adapterName, err := MellanoxWorkaroundRequired(mockExecClient)
+			require.NoError(t, err)
+			assert.Equal(t, tt.required, required)
+			assert.Equal(t, tt.expectedName, adapterName)
+		})
+	}
+}
+
+func TestMellanoxWorkaroundRequiredError(t *testing.T) {
+	required, _, err := MellanoxWorkaroundRequired(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+	assert.False(t, required)
+}
+
+// mellanoxPriorityVLANTagResponder answers powershell commands of a host whose Mellanox adapters have a version 4 driver
+// and the PriorityVLANTag values of priorityVLANTags, by adapter name
+func mellanoxPriorityVLANTagResponder(priorityVLANTags map[string]string) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "Get-NetAdapter | Where-Object") {
+			names := make([]string, 0, len(priorityVLANTags))
+			for name := range priorityVLANTags {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return strings.Join(names, "\r\n"), nil
+		}
+
+		for name, value := range priorityVLANTags {
+			switch {
+			case strings.HasPrefix(cmd, "Get-NetAdapter -Name '"+name+"' | Select-Object DriverVersionString"):
+				return `{"DriverVersionString": "4.1.2"}`, nil
+			case strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty -Name '"+name+"'"):
+				return value, nil
+			}
+		}
+		return "", nil
+	}
+}
+
+func TestMellanoxConfigSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mellanox.json")
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(mellanoxPriorityVLANTagResponder(map[string]string{"Ethernet 3": "3", "Ethernet 4": "3"}))
+	require.NoError(t, SaveMellanoxConfigSnapshot(mockExecClient, path))
+
+	changed, keys, err := CompareMellanoxConfigSnapshot(mockExecClient, path)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, keys)
+
+	// a driver update reset the value of Ethernet 4 and Ethernet 5 appeared
+	mockExecClient.SetPowershellCommandResponder(mellanoxPriorityVLANTagResponder(map[string]string{"Ethernet 3": "3", "Ethernet 4": "0", "Ethernet 5": "3"}))
+	changed, keys, err = CompareMellanoxConfigSnapshot(mockExecClient, path)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"Ethernet 4", "Ethernet 5"}, keys)
+
+	// Ethernet 3 disappeared
+	mockExecClient.SetPowershellCommandResponder(mellanoxPriorityVLANTagResponder(map[string]string{"Ethernet 4": "3"}))
+	changed, keys, err = CompareMellanoxConfigSnapshot(mockExecClient, path)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"Ethernet 3"}, keys)
+}
+
+func TestMellanoxConfigSnapshotError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mellanox.json")
+	require.ErrorIs(t, SaveMellanoxConfigSnapshot(NewMockExecClient(true), path), ErrMockExec)
+
+	_, _, err := CompareMellanoxConfigSnapshot(NewMockExecClient(false), path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+	_, _, err = CompareMellanoxConfigSnapshot(NewMockExecClient(false), path)
+	require.Error(t, err)
+}
+
+func TestMonitorAndSetMellanoxRegKeyPriorityVLANTagReportsPersistentFailure(t *testing.T) {
+	w, sources := useFakeEventLog(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// every check fails listing the adapters, stop after twice the failures reported
+	checks := 0
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		if checks++; checks >= 2*mellanoxMonitorFailureThreshold {
+			cancel()
+		}
+		return "", ErrMockExec
+	})
+
+	MonitorAndSetMellanoxRegKeyPriorityVLANTag(ctx, time.Millisecond, mockExecClient)
+	require.Len(t, w.entries, 1)
+	assert.True(t, strings.HasPrefix(w.entries[0], "Error 1 Failed to set Mellanox PriorityVLANTag 5 consecutive times"), w.entries[0])
+	assert.Equal(t, []string{PlatformEventLogSource}, *sources)
+}
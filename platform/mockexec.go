@@ -2,20 +2,84 @@ package platform
 
 import "errors"
 
-type mockExecClient struct {
-	returnError bool
+type MockExecClient struct {
+	returnError                bool
+	commandResponder           func(string) (string, error)
+	powershellCommandResponder func(string) (string, error)
+	powershellCommandSequence  []func(string) (string, error)
+	commands                   []string
+	powershellCommands         []string
 }
 
-// ErrMockExec - mock exec error
-var ErrMockExec = errors.New("mock exec error")
+var (
+	// ErrMockExec - mock exec error
+	ErrMockExec = errors.New("mock exec error")
+	// ErrMockExecSequenceExhausted - more commands were executed than responders were sequenced
+	ErrMockExecSequenceExhausted = errors.New("mock exec powershell command sequence exhausted")
+)
 
-func NewMockExecClient(returnErr bool) ExecClient {
-	return &mockExecClient{
+func NewMockExecClient(returnErr bool) *MockExecClient {
+	return &MockExecClient{
 		returnError: returnErr,
 	}
 }
 
-func (e *mockExecClient) ExecuteCommand(string) (string, error) {
+func (e *MockExecClient) ExecuteCommand(cmd string) (string, error) {
+	e.commands = append(e.commands, cmd)
+
+	if e.commandResponder != nil {
+		return e.commandResponder(cmd)
+	}
+
+	return e.defaultResponse()
+}
+
+// SetCommandResponder sets the function used to answer commands passed to ExecuteCommand
+func (e *MockExecClient) SetCommandResponder(fn func(string) (string, error)) {
+	e.commandResponder = fn
+}
+
+// SetPowershellCommandResponder sets the function used to answer powershell commands
+func (e *MockExecClient) SetPowershellCommandResponder(fn func(string) (string, error)) {
+	e.powershellCommandResponder = fn
+}
+
+// SetPowershellCommandSequence sets the functions used to answer powershell commands, one per command in order.
+// Commands executed after the sequence is exhausted return ErrMockExecSequenceExhausted.
+func (e *MockExecClient) SetPowershellCommandSequence(fns []func(string) (string, error)) {
+	e.powershellCommandSequence = fns
+	e.powershellCommandResponder = func(string) (string, error) {
+		return "", ErrMockExecSequenceExhausted
+	}
+}
+
+func (e *MockExecClient) ExecutePowershellCommand(cmd string) (string, error) {
+	e.powershellCommands = append(e.powershellCommands, cmd)
+
+	if len(e.powershellCommandSequence) > 0 {
+		fn := e.powershellCommandSequence[0]
+		e.powershellCommandSequence = e.powershellCommandSequence[1:]
+		return fn(cmd)
+	}
+
+	if e.powershellCommandResponder != nil {
+		return e.powershellCommandResponder(cmd)
+	}
+
+	return e.defaultResponse()
+}
+
+// RecordedCommands returns the commands passed to ExecuteCommand, in order
+func (e *MockExecClient) RecordedCommands() []string {
+	return append([]string(nil), e.commands...)
+}
+
+// RecordedPowershellCommands returns the commands passed to ExecutePowershellCommand, in order
+func (e *MockExecClient) RecordedPowershellCommands() []string {
+	return append([]string(nil), e.powershellCommands...)
+}
+
+func (e *MockExecClient) defaultResponse() (string, error) {
 	if e.returnError {
 		return "", ErrMockExec
 	}
@@ -0,0 +1,45 @@
+package platform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockExecClientPowershellCommandSequence(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence([]func(string) (string, error){
+		func(cmd string) (string, error) {
+			assert.Equal(t, "first", cmd)
+			return "1", nil
+		},
+		func(cmd string) (string, error) {
+			assert.Equal(t, "second", cmd)
+			return "", ErrMockExec
+		},
+	})
+
+	out, err := mockExecClient.ExecutePowershellCommand("first")
+	require.NoError(t, err)
+	assert.Equal(t, "1", out)
+
+	_, err = mockExecClient.ExecutePowershellCommand("second")
+	require.ErrorIs(t, err, ErrMockExec)
+
+	_, err = mockExecClient.ExecutePowershellCommand("third")
+	require.ErrorIs(t, err, ErrMockExecSequenceExhausted)
+}
+
+func TestMockExecClientRecordedCommands(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	assert.Empty(t, mockExecClient.RecordedCommands())
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+
+	_, _ = mockExecClient.ExecuteCommand("ip link show")
+	_, _ = mockExecClient.ExecutePowershellCommand("Get-NetAdapter")
+	_, _ = mockExecClient.ExecuteCommand("ip addr show")
+
+	assert.Equal(t, []string{"ip link show", "ip addr show"}, mockExecClient.RecordedCommands())
+	assert.Equal(t, []string{"Get-NetAdapter"}, mockExecClient.RecordedPowershellCommands())
+}
@@ -0,0 +1,6 @@
+package platform
+
+// ExecuteCommandAsUser records the command like ExecuteCommand and answers it the same way, regardless of the user
+func (e *MockExecClient) ExecuteCommandAsUser(_ LogonType, _, _, cmd string) (string, error) {
+	return e.ExecuteCommand(cmd)
+}
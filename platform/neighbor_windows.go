@@ -0,0 +1,70 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"net"
+)
+
+// NeighborEntry is an entry of the neighbor (ARP/NDP) table of an adapter.
+type NeighborEntry struct {
+	IP    net.IP
+	MAC   net.HardwareAddr
+	State string
+}
+
+// GetNeighborTable returns the neighbor table entries of the named adapter.
+// An empty table is not an error.
+func GetNeighborTable(execClient ExecClient, adapterName string) ([]NeighborEntry, error) {
+	cmd := fmt.Sprintf("Get-NetNeighbor -InterfaceAlias '%s' -ErrorAction SilentlyContinue | "+
+		"Select-Object IPAddress, LinkLayerAddress, @{Name='State'; Expression={$_.State.ToString()}}", escapePowershellString(adapterName))
+	rawEntries, err := ExecutePowershellJSON[[]struct {
+		IPAddress        string
+		LinkLayerAddress string
+		State            string
+	}](execClient, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get neighbor table of %s: %w", adapterName, err)
+	}
+
+	entries := make([]NeighborEntry, 0, len(rawEntries))
+	for _, raw := range rawEntries {
+		entry := NeighborEntry{
+			IP:    net.ParseIP(raw.IPAddress),
+			State: raw.State,
+		}
+
+		if entry.IP == nil {
+			return nil, fmt.Errorf("invalid IP address %q in neighbor table of %s", raw.IPAddress, adapterName)
+		}
+
+		// unresolved entries have no link layer address
+		if raw.LinkLayerAddress != "" {
+			if entry.MAC, err = net.ParseMAC(raw.LinkLayerAddress); err != nil {
+				return nil, fmt.Errorf("invalid MAC address %q in neighbor table of %s: %w", raw.LinkLayerAddress, adapterName, err)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// FlushNeighborCache removes the neighbor table entries of the named adapter.
+// Flushing an already empty table is not an error.
+func FlushNeighborCache(execClient ExecClient, adapterName string) error {
+	cmd := fmt.Sprintf("Remove-NetNeighbor -InterfaceAlias '%s' -Confirm:$false", escapePowershellString(adapterName))
+	_, err := execClient.ExecutePowershellCommand(cmd)
+	if isNoCimObjectsFoundError(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to flush neighbor cache of %s: %w", adapterName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const neighborTableFixture = `[
+    {
+        "IPAddress":  "10.240.0.1",
+        "LinkLayerAddress":  "12-34-56-78-9A-BC",
+        "State":  "Reachable"
+    },
+    {
+        "IPAddress":  "10.240.0.5",
+        "LinkLayerAddress":  "00-0D-3A-F1-22-04",
+        "State":  "Stale"
+    },
+    {
+        "IPAddress":  "fe80::1",
+        "LinkLayerAddress":  "",
+        "State":  "Unreachable"
+    }
+]`
+
+func TestGetNeighborTable(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		require.True(t, strings.HasPrefix(cmd, "Get-NetNeighbor -InterfaceAlias 'Ethernet 2'"))
+		return neighborTableFixture, nil
+	})
+
+	entries, err := GetNeighborTable(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "10.240.0.1", entries[0].IP.String())
+	assert.Equal(t, "12:34:56:78:9a:bc", entries[0].MAC.String())
+	assert.Equal(t, "Reachable", entries[0].State)
+
+	assert.Equal(t, "10.240.0.5", entries[1].IP.String())
+	assert.Equal(t, "00:0d:3a:f1:22:04", entries[1].MAC.String())
+	assert.Equal(t, "Stale", entries[1].State)
+
+	assert.Equal(t, "fe80::1", entries[2].IP.String())
+	assert.Nil(t, entries[2].MAC)
+}
+
+func TestGetNeighborTableSingleEntry(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `{"IPAddress": "10.240.0.1", "LinkLayerAddress": "12-34-56-78-9A-BC", "State": "Permanent"}`, nil
+	})
+
+	entries, err := GetNeighborTable(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Permanent", entries[0].State)
+}
+
+func TestGetNeighborTableEmpty(t *testing.T) {
+	entries, err := GetNeighborTable(NewMockExecClient(false), "Ethernet 2")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestGetNeighborTableError(t *testing.T) {
+	_, err := GetNeighborTable(NewMockExecClient(true), "Ethernet 2")
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `{"IPAddress": "not-an-ip", "LinkLayerAddress": "", "State": "Stale"}`, nil
+	})
+	_, err = GetNeighborTable(mockExecClient, "Ethernet 2")
+	require.Error(t, err)
+}
+
+func TestFlushNeighborCache(t *testing.T) {
+	var commands []string
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		commands = append(commands, cmd)
+		return "", nil
+	})
+
+	require.NoError(t, FlushNeighborCache(mockExecClient, "Ethernet 2"))
+	assert.Equal(t, []string{"Remove-NetNeighbor -InterfaceAlias 'Ethernet 2' -Confirm:$false"}, commands)
+}
+
+func TestFlushNeighborCacheEmpty(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "", errors.New("exit status 1:Remove-NetNeighbor : No matching MSFT_NetNeighbor objects found")
+	})
+
+	require.NoError(t, FlushNeighborCache(mockExecClient, "Ethernet 2"))
+}
+
+func TestFlushNeighborCacheError(t *testing.T) {
+	require.ErrorIs(t, FlushNeighborCache(NewMockExecClient(true), "Ethernet 2"), ErrMockExec)
+}
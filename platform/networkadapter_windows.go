@@ -0,0 +1,278 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+)
+
+// Command to get the names of the network adapters of the host
+const GetAdapterNamesCommand = "Get-NetAdapter | Select-Object -ExpandProperty Name"
+
+// hnsHostAdapterNameRegex matches the names of the host vNICs created by HNS
+var hnsHostAdapterNameRegex = regexp.MustCompile(`^vEthernet \(.*\)$`)
+
+// noCimObjectsFoundRegex matches the error reported by CIM based cmdlets when there is no object to act on,
+// e.g. when querying a setting the adapter does not support
+var noCimObjectsFoundRegex = regexp.MustCompile(`No (matching )?MSFT_\w+ objects found`)
+
+// isNoCimObjectsFoundError returns whether err is the error of a CIM based cmdlet which found no object to act on
+func isNoCimObjectsFoundError(err error) bool {
+	return err != nil && noCimObjectsFoundRegex.MatchString(err.Error())
+}
+
+// GetHNSHostAdapterNames returns the names of the host vNICs created by HNS, i.e. the vEthernet (*) adapters
+func GetHNSHostAdapterNames(execClient ExecClient) ([]string, error) {
+	out, err := execClient.ExecutePowershellCommand(GetAdapterNamesCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adapter names: %w", err)
+	}
+
+	var names []string
+	for _, name := range splitPowershellLines(out) {
+		if hnsHostAdapterNameRegex.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// hasNetworkAdapter returns whether the host has any network adapter.
+// A host without adapters is not an error, only failing to list them is.
+func hasNetworkAdapter(na adapter.NetworkAdapter) (bool, error) {
+	names, err := na.GetAdapterNames()
+	if errors.Is(err, adapter.ErrNoAdaptersFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	log.Printf("Found network adapters %v", names)
+	return true, nil
+}
+
+// getAdapterNameByDescription returns the name of the first adapter whose interface description matches
+// any of the powershell wildcard patterns, or an empty string if there is none
+func getAdapterNameByDescription(execClient ExecClient, patterns ...string) (string, error) {
+	names, err := getAdapterNamesByDescription(execClient, patterns...)
+	if err != nil || len(names) == 0 {
+		return "", err
+	}
+
+	return names[0], nil
+}
+
+// getAdapterNamesByDescription returns the names of the adapters whose interface description matches
+// any of the powershell wildcard patterns
+func getAdapterNamesByDescription(execClient ExecClient, patterns ...string) ([]string, error) {
+	conditions := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		conditions = append(conditions, fmt.Sprintf("$_.InterfaceDescription -like '%s'", escapePowershellString(pattern)))
+	}
+
+	cmd := fmt.Sprintf("Get-NetAdapter | Where-Object { %s } | Select-Object -ExpandProperty Name", strings.Join(conditions, " -or "))
+	out, err := execClient.ExecutePowershellCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitPowershellLines(out), nil
+}
+
+// WaitForAdapter polls every pollInterval until the host has an adapter whose interface description matches
+// the powershell wildcard pattern, e.g. *Mellanox*, and returns its name. Adapters may be enumerated
+// some time after boot. Returns the context error if it is done before the adapter appears.
+func WaitForAdapter(ctx context.Context, execClient ExecClient, pattern string, pollInterval time.Duration) (string, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		adapterName, err := getAdapterNameByDescription(execClient, pattern)
+		if err != nil {
+			log.Errorf("Failed to get adapter matching %s: %v", pattern, err)
+		} else if adapterName != "" {
+			return adapterName, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("no adapter matching %s found: %w", pattern, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// mediaConnectionStateConnected is the MediaConnectionState of an adapter whose media is connected
+const mediaConnectionStateConnected = 1
+
+// IsAdapterLinkUp returns whether the adapter is up with its media connected
+func IsAdapterLinkUp(execClient ExecClient, adapterName string) (bool, error) {
+	cmd := fmt.Sprintf("Get-NetAdapter -Name '%s' | Select-Object Status, MediaConnectionState", escapePowershellString(adapterName))
+	state, err := ExecutePowershellJSON[struct {
+		Status               string
+		MediaConnectionState int
+	}](execClient, cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to get link state of %s: %w", adapterName, err)
+	}
+
+	return state.Status == "Up" && state.MediaConnectionState == mediaConnectionStateConnected, nil
+}
+
+type networkAdapter struct {
+	execClient ExecClient
+}
+
+// NewNetworkAdapter returns a NetworkAdapter operating on the adapters of the host through powershell
+func NewNetworkAdapter(execClient ExecClient) adapter.NetworkAdapter {
+	return &networkAdapter{execClient: execClient}
+}
+
+// GetAdapterNames returns the names of the network adapters of the host
+func (na *networkAdapter) GetAdapterNames() ([]string, error) {
+	out, err := na.execClient.ExecutePowershellCommand(GetAdapterNamesCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adapter names: %w", err)
+	}
+
+	names := splitPowershellLines(out)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("failed to get adapter names: %w", adapter.ErrNoAdaptersFound)
+	}
+
+	return names, nil
+}
+
+// AdapterExists returns whether the host has an adapter with the given name
+func (na *networkAdapter) AdapterExists(adapterName string) (bool, error) {
+	names, err := na.GetAdapterNames()
+	if errors.Is(err, adapter.ErrNoAdaptersFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range names {
+		if name == adapterName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// GetRSCSettings returns whether Receive Segment Coalescing is enabled on the adapter for IPv4 and for IPv6
+func (na *networkAdapter) GetRSCSettings(adapterName string) (adapter.RSCSettings, error) {
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return adapter.RSCSettings{}, err
+	}
+
+	cmd := fmt.Sprintf("Get-NetAdapterRsc -Name '%s' | Select-Object IPv4Enabled, IPv6Enabled", escapePowershellString(adapterName))
+	settings, err := ExecutePowershellJSON[adapter.RSCSettings](na.execClient, cmd)
+	if isNoCimObjectsFoundError(err) {
+		return adapter.RSCSettings{}, fmt.Errorf("RSC on %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return adapter.RSCSettings{}, fmt.Errorf("failed to get RSC settings of %s: %w", adapterName, err)
+	}
+
+	return settings, nil
+}
+
+// SetRSCSettings enables or disables Receive Segment Coalescing on the adapter for IPv4 and for IPv6
+func (na *networkAdapter) SetRSCSettings(adapterName string, settings adapter.RSCSettings) error {
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("Set-NetAdapterRsc -Name '%s' -IPv4Enabled $%t -IPv6Enabled $%t",
+		escapePowershellString(adapterName), settings.IPv4Enabled, settings.IPv6Enabled)
+	_, err := na.execClient.ExecutePowershellCommand(cmd)
+	if isNoCimObjectsFoundError(err) {
+		return fmt.Errorf("RSC on %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to set RSC settings of %s: %w", adapterName, err)
+	}
+
+	return nil
+}
+
+// GetSRIOVEnabled returns whether SR-IOV is enabled on the adapter
+func (na *networkAdapter) GetSRIOVEnabled(adapterName string) (bool, error) {
+	cmd := fmt.Sprintf("Get-NetAdapterSriov -Name '%s' | Select-Object -ExpandProperty Enabled", escapePowershellString(adapterName))
+	out, err := na.execClient.ExecutePowershellCommand(cmd)
+	if isNoCimObjectsFoundError(err) {
+		return false, fmt.Errorf("SR-IOV on %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to get SR-IOV settings of %s: %w", adapterName, err)
+	}
+
+	enabled, err := parsePowershellBool(out)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse SR-IOV enabled value %q of %s: %w", out, adapterName, err)
+	}
+
+	return enabled, nil
+}
+
+// EnableAdapter administratively enables the adapter
+func (na *networkAdapter) EnableAdapter(adapterName string) error {
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("Enable-NetAdapter -Name '%s' -Confirm:$false", escapePowershellString(adapterName))
+	if _, err := na.execClient.ExecutePowershellCommand(cmd); err != nil {
+		return fmt.Errorf("failed to enable adapter %s: %w", adapterName, err)
+	}
+
+	return nil
+}
+
+// DisableAdapter administratively disables the adapter
+func (na *networkAdapter) DisableAdapter(adapterName string) error {
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("Disable-NetAdapter -Name '%s' -Confirm:$false", escapePowershellString(adapterName))
+	if _, err := na.execClient.ExecutePowershellCommand(cmd); err != nil {
+		return fmt.Errorf("failed to disable adapter %s: %w", adapterName, err)
+	}
+
+	return nil
+}
+
+// checkAdapterExists returns ErrAdapterNotFound if the host has no adapter with the given name,
+// so that operations on it fail with an actionable error rather than the error of a cmdlet
+func (na *networkAdapter) checkAdapterExists(adapterName string) error {
+	exists, err := na.AdapterExists(adapterName)
+	if err != nil {
+		return fmt.Errorf("failed to check if adapter %s exists: %w", adapterName, err)
+	}
+
+	if !exists {
+		return fmt.Errorf("%s: %w", adapterName, adapter.ErrAdapterNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,337 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"github.com/Azure/azure-container-networking/platform/windows/adapter/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errNoRscSettingData = errors.New("exit status 1:Get-NetAdapterRsc : No MSFT_NetAdapterRscSettingData objects found " +
+	"with property 'Name' equal to 'Ethernet 2'.")
+
+// adapterNamesResponder answers powershell commands of a host with the adapters Ethernet and Ethernet 2
+func adapterNamesResponder(cmd string) (string, error) {
+	if cmd == GetAdapterNamesCommand {
+		return "Ethernet\r\nEthernet 2\r\n", nil
+	}
+	return "", nil
+}
+
+func TestAdapterNameWithQuote(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	require.NoError(t, SetDNSServers(mockExecClient, "Bob's NIC", []string{"10.0.0.10"}))
+	_, err := GetDNSServers(mockExecClient, "Bob's NIC")
+	require.NoError(t, err)
+	_, _ = IsAdapterLinkUp(mockExecClient, "Bob's NIC")
+
+	commands := mockExecClient.RecordedPowershellCommands()
+	require.Len(t, commands, 3)
+	for _, cmd := range commands {
+		assert.Contains(t, cmd, "'Bob''s NIC'")
+	}
+	assert.Contains(t, commands, "Set-DnsClientServerAddress -InterfaceAlias 'Bob''s NIC' -ServerAddresses ('10.0.0.10')")
+}
+
+func TestGetAdapterNames(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "Ethernet\r\nEthernet 2\r\n", nil
+	})
+
+	names, err := NewNetworkAdapter(mockExecClient).GetAdapterNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Ethernet", "Ethernet 2"}, names)
+
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "Ethernet\r\nEthernet Ünïcødé 网络\r\n", nil
+	})
+	names, err = NewNetworkAdapter(mockExecClient).GetAdapterNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Ethernet", "Ethernet Ünïcødé 网络"}, names)
+
+	_, err = NewNetworkAdapter(NewMockExecClient(false)).GetAdapterNames()
+	require.ErrorIs(t, err, adapter.ErrNoAdaptersFound)
+
+	_, err = NewNetworkAdapter(NewMockExecClient(true)).GetAdapterNames()
+	require.ErrorIs(t, err, ErrMockExec)
+	require.NotErrorIs(t, err, adapter.ErrNoAdaptersFound)
+}
+
+func TestHasNetworkAdapter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	na := mocks.NewMockNetworkAdapter(ctrl)
+	na.EXPECT().GetAdapterNames().Return([]string{"Ethernet"}, nil)
+	found, err := hasNetworkAdapter(na)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	na.EXPECT().GetAdapterNames().Return(nil, fmt.Errorf("failed to get adapter names: %w", adapter.ErrNoAdaptersFound))
+	found, err = hasNetworkAdapter(na)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	na.EXPECT().GetAdapterNames().Return(nil, ErrMockExec)
+	found, err = hasNetworkAdapter(na)
+	require.ErrorIs(t, err, ErrMockExec)
+	assert.False(t, found)
+}
+
+func TestGetHNSHostAdapterNames(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, GetAdapterNamesCommand, cmd)
+		return "Ethernet\r\nvEthernet (Ethernet)\r\nEthernet 2\r\nvEthernet (nat)\r\nvEthernet-like\r\n", nil
+	})
+
+	names, err := GetHNSHostAdapterNames(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vEthernet (Ethernet)", "vEthernet (nat)"}, names)
+
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "Ethernet\r\nEthernet 2", nil
+	})
+	names, err = GetHNSHostAdapterNames(mockExecClient)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	_, err = GetHNSHostAdapterNames(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestGetRSCSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected adapter.RSCSettings
+	}{
+		{"enabled", `{"IPv4Enabled": true, "IPv6Enabled": true}`, adapter.RSCSettings{IPv4Enabled: true, IPv6Enabled: true}},
+		{"ipv6 disabled", `{"IPv4Enabled": true, "IPv6Enabled": false}`, adapter.RSCSettings{IPv4Enabled: true}},
+		{"ipv4 disabled", `{"IPv4Enabled": false, "IPv6Enabled": true}`, adapter.RSCSettings{IPv6Enabled: true}},
+		{"disabled", `{"IPv4Enabled": false, "IPv6Enabled": false}`, adapter.RSCSettings{}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				if cmd == GetAdapterNamesCommand {
+					return adapterNamesResponder(cmd)
+				}
+				return tt.output, nil
+			})
+
+			settings, err := NewNetworkAdapter(mockExecClient).GetRSCSettings("Ethernet 2")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, settings)
+		})
+	}
+}
+
+func TestSetRSCSettings(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	na := NewNetworkAdapter(mockExecClient)
+
+	require.NoError(t, na.SetRSCSettings("Ethernet 2", adapter.RSCSettings{IPv4Enabled: true, IPv6Enabled: true}))
+	require.NoError(t, na.SetRSCSettings("Ethernet 2", adapter.RSCSettings{IPv4Enabled: true}))
+	require.NoError(t, na.SetRSCSettings("Ethernet 2", adapter.RSCSettings{IPv6Enabled: true}))
+	assert.Equal(t, []string{
+		GetAdapterNamesCommand,
+		"Set-NetAdapterRsc -Name 'Ethernet 2' -IPv4Enabled $true -IPv6Enabled $true",
+		GetAdapterNamesCommand,
+		"Set-NetAdapterRsc -Name 'Ethernet 2' -IPv4Enabled $true -IPv6Enabled $false",
+		GetAdapterNamesCommand,
+		"Set-NetAdapterRsc -Name 'Ethernet 2' -IPv4Enabled $false -IPv6Enabled $true",
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestRSCUnknownAdapter(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	na := NewNetworkAdapter(mockExecClient)
+
+	_, err := na.GetRSCSettings("Ethernet 3")
+	require.ErrorIs(t, err, adapter.ErrAdapterNotFound)
+	require.ErrorIs(t, na.SetRSCSettings("Ethernet 3", adapter.RSCSettings{}), adapter.ErrAdapterNotFound)
+	assert.Equal(t, []string{GetAdapterNamesCommand, GetAdapterNamesCommand}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestRSCUnsupported(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == GetAdapterNamesCommand {
+			return adapterNamesResponder(cmd)
+		}
+		return "", errNoRscSettingData
+	})
+	na := NewNetworkAdapter(mockExecClient)
+
+	_, err := na.GetRSCSettings("Ethernet 2")
+	require.ErrorIs(t, err, adapter.ErrFeatureUnsupported)
+	require.ErrorIs(t, na.SetRSCSettings("Ethernet 2", adapter.RSCSettings{IPv4Enabled: true}), adapter.ErrFeatureUnsupported)
+
+	_, err = NewNetworkAdapter(NewMockExecClient(true)).GetRSCSettings("Ethernet 2")
+	require.ErrorIs(t, err, ErrMockExec)
+	require.NotErrorIs(t, err, adapter.ErrFeatureUnsupported)
+}
+
+func TestGetSRIOVEnabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		err         error
+		expected    bool
+		expectedErr error
+	}{
+		{name: "enabled", output: "True", expected: true},
+		{name: "disabled", output: "False", expected: false},
+		{
+			name:        "unsupported",
+			err:         errors.New("Get-NetAdapterSriov : No MSFT_NetAdapterSriovSettingData objects found with property 'Name' equal to 'Ethernet 2'"),
+			expectedErr: adapter.ErrFeatureUnsupported,
+		},
+		{name: "failure", err: ErrMockExec, expectedErr: ErrMockExec},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				assert.Equal(t, "Get-NetAdapterSriov -Name 'Ethernet 2' | Select-Object -ExpandProperty Enabled", cmd)
+				return tt.output, tt.err
+			})
+
+			enabled, err := NewNetworkAdapter(mockExecClient).GetSRIOVEnabled("Ethernet 2")
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, enabled)
+		})
+	}
+}
+
+func TestEnableDisableAdapter(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	na := NewNetworkAdapter(mockExecClient)
+
+	require.NoError(t, na.DisableAdapter("Ethernet 2"))
+	require.NoError(t, na.EnableAdapter("Ethernet 2"))
+	assert.Equal(t, []string{
+		GetAdapterNamesCommand,
+		"Disable-NetAdapter -Name 'Ethernet 2' -Confirm:$false",
+		GetAdapterNamesCommand,
+		"Enable-NetAdapter -Name 'Ethernet 2' -Confirm:$false",
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestEnableDisableUnknownAdapter(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	na := NewNetworkAdapter(mockExecClient)
+
+	require.ErrorIs(t, na.DisableAdapter("Ethernet 3"), adapter.ErrAdapterNotFound)
+	require.ErrorIs(t, na.EnableAdapter("Ethernet 3"), adapter.ErrAdapterNotFound)
+	assert.Equal(t, []string{GetAdapterNamesCommand, GetAdapterNamesCommand}, mockExecClient.RecordedPowershellCommands())
+
+	require.ErrorIs(t, NewNetworkAdapter(NewMockExecClient(false)).EnableAdapter("Ethernet"), adapter.ErrAdapterNotFound)
+	require.ErrorIs(t, NewNetworkAdapter(NewMockExecClient(true)).EnableAdapter("Ethernet"), ErrMockExec)
+}
+
+func TestAdapterExists(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	na := NewNetworkAdapter(mockExecClient)
+
+	exists, err := na.AdapterExists("Ethernet 2")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// names are matched exactly
+	for _, name := range []string{"Ethernet 3", "ethernet", "Ethernet*"} {
+		exists, err = na.AdapterExists(name)
+		require.NoError(t, err)
+		assert.False(t, exists, name)
+	}
+
+	// a host without adapters has none of the given name
+	exists, err = NewNetworkAdapter(NewMockExecClient(false)).AdapterExists("Ethernet")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = NewNetworkAdapter(NewMockExecClient(true)).AdapterExists("Ethernet")
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestWaitForAdapter(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence([]func(string) (string, error){
+		func(cmd string) (string, error) {
+			assert.Equal(t, "Get-NetAdapter | Where-Object { $_.InterfaceDescription -like '*Mellanox*' } | "+
+				"Select-Object -ExpandProperty Name", cmd)
+			return "", nil
+		},
+		func(string) (string, error) { return "", ErrMockExec },
+		func(string) (string, error) { return "Ethernet 3\r\n", nil },
+	})
+
+	adapterName, err := WaitForAdapter(context.Background(), mockExecClient, mellanoxSearchString, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, "Ethernet 3", adapterName)
+	assert.Len(t, mockExecClient.RecordedPowershellCommands(), 3)
+}
+
+func TestWaitForAdapterContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := WaitForAdapter(ctx, NewMockExecClient(false), mellanoxSearchString, time.Millisecond)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestIsAdapterLinkUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected bool
+	}{
+		{"connected", `{"Status": "Up", "MediaConnectionState": 1}`, true},
+		{"disconnected", `{"Status": "Disconnected", "MediaConnectionState": 2}`, false},
+		{"disabled", `{"Status": "Disabled", "MediaConnectionState": 0}`, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				assert.Equal(t, "Get-NetAdapter -Name 'Ethernet 3' | Select-Object Status, MediaConnectionState | ConvertTo-Json -Depth 10", cmd)
+				return tt.output, nil
+			})
+
+			up, err := IsAdapterLinkUp(mockExecClient, "Ethernet 3")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, up)
+		})
+	}
+
+	_, err := IsAdapterLinkUp(NewMockExecClient(true), "Ethernet 3")
+	require.ErrorIs(t, err, ErrMockExec)
+}
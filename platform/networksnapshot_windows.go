@@ -0,0 +1,106 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Command to get the adapters of the host as JSON
+	GetAdaptersSnapshotCommand = "Get-NetAdapter | Select-Object Name, InterfaceDescription, ifIndex, Status, MacAddress, " +
+		"LinkSpeed, MtuSize | ConvertTo-Json -Depth 10"
+
+	// Command to get the IP addresses of the host as JSON
+	GetIPAddressesSnapshotCommand = "Get-NetIPAddress | Select-Object InterfaceAlias, IPAddress, PrefixLength, " +
+		"@{Name='AddressFamily'; Expression={$_.AddressFamily.ToString()}}, " +
+		"@{Name='AddressState'; Expression={$_.AddressState.ToString()}} | ConvertTo-Json -Depth 10"
+
+	// Command to get the routes of the host as JSON
+	GetRoutesSnapshotCommand = "Get-NetRoute | Select-Object DestinationPrefix, NextHop, InterfaceAlias, RouteMetric | " +
+		"ConvertTo-Json -Depth 10"
+)
+
+// NetworkSnapshot is the networking configuration of the host at a point in time.
+// Sections hold the JSON output of the commands querying them, null if the command failed,
+// in which case Errors holds its error by section.
+type NetworkSnapshot struct {
+	Timestamp              time.Time         `json:"timestamp"`
+	Adapters               json.RawMessage   `json:"adapters"`
+	IPAddresses            json.RawMessage   `json:"ipAddresses"`
+	Routes                 json.RawMessage   `json:"routes"`
+	HNSNetworks            json.RawMessage   `json:"hnsNetworks"`
+	HNSEndpoints           json.RawMessage   `json:"hnsEndpoints"`
+	SdnRemoteArpMacAddress string            `json:"sdnRemoteArpMacAddress"`
+	Errors                 map[string]string `json:"errors,omitempty"`
+}
+
+// CaptureNetworkSnapshot returns the adapters, IP addresses, routes, hns networks and endpoints and the
+// SDNRemoteArpMacAddress of the host as a single JSON document, e.g. to attach it to an incident.
+// A section failing to be captured is reported in the errors of the snapshot rather than failing the capture.
+// Returns the context error if it is done before all sections are captured.
+func CaptureNetworkSnapshot(ctx context.Context, execClient ExecClient) ([]byte, error) {
+	snapshot := NetworkSnapshot{Timestamp: time.Now().UTC(), Errors: map[string]string{}}
+
+	sections := []struct {
+		name    string
+		cmd     string
+		section *json.RawMessage
+	}{
+		{"adapters", GetAdaptersSnapshotCommand, &snapshot.Adapters},
+		{"ipAddresses", GetIPAddressesSnapshotCommand, &snapshot.IPAddresses},
+		{"routes", GetRoutesSnapshotCommand, &snapshot.Routes},
+		{"hnsNetworks", GetHNSNetworksCommand, &snapshot.HNSNetworks},
+		{"hnsEndpoints", GetHNSEndpointsCommand, &snapshot.HNSEndpoints},
+	}
+
+	for _, s := range sections {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("network snapshot cancelled: %w", err)
+		}
+
+		out, err := execClient.ExecutePowershellCommand(s.cmd)
+		out = strings.TrimSpace(out)
+		switch {
+		case err != nil:
+		case out == "":
+			// no objects, e.g. no hns endpoints
+			out = "[]"
+		case !json.Valid([]byte(out)):
+			err = fmt.Errorf("invalid JSON output %q", out)
+		}
+
+		if err != nil {
+			log.Errorf("Failed to capture %s of network snapshot, continuing: %v", s.name, err)
+			snapshot.Errors[s.name] = err.Error()
+			continue
+		}
+
+		*s.section = json.RawMessage(out)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("network snapshot cancelled: %w", err)
+	}
+
+	out, err := execClient.ExecutePowershellCommand(GetSdnRemoteArpMacAddressCommand)
+	if err != nil {
+		log.Errorf("Failed to capture sdnRemoteArpMacAddress of network snapshot, continuing: %v", err)
+		snapshot.Errors["sdnRemoteArpMacAddress"] = err.Error()
+	}
+	snapshot.SdnRemoteArpMacAddress = strings.TrimSpace(out)
+
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal network snapshot: %w", err)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// networkSnapshotResponder answers the commands of CaptureNetworkSnapshot with a host of one adapter,
+// failing the commands in failed
+func networkSnapshotResponder(failed ...string) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		for _, f := range failed {
+			if cmd == f {
+				return "", ErrMockExec
+			}
+		}
+
+		switch cmd {
+		case GetAdaptersSnapshotCommand:
+			return `{"Name": "Ethernet 2", "ifIndex": 6, "Status": "Up"}`, nil
+		case GetIPAddressesSnapshotCommand:
+			return `[{"InterfaceAlias": "Ethernet 2", "IPAddress": "10.240.0.4", "PrefixLength": 16}]`, nil
+		case GetRoutesSnapshotCommand:
+			return `[{"DestinationPrefix": "0.0.0.0/0", "NextHop": "10.240.0.1", "InterfaceAlias": "Ethernet 2"}]`, nil
+		case GetHNSNetworksCommand:
+			return `{"ID": "8D2A1C3B-7E4F-4A6B-8C9D-1E2F3A4B5C6D", "Name": "azure", "Type": "L2Bridge"}`, nil
+		case GetHNSEndpointsCommand:
+			return "", nil
+		case GetSdnRemoteArpMacAddressCommand:
+			return SDNRemoteArpMacAddress + "\r\n", nil
+		}
+		return "", nil
+	}
+}
+
+func TestCaptureNetworkSnapshot(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(networkSnapshotResponder())
+
+	b, err := CaptureNetworkSnapshot(context.Background(), mockExecClient)
+	require.NoError(t, err)
+
+	var document map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(b, &document))
+	for _, section := range []string{"timestamp", "adapters", "ipAddresses", "routes", "hnsNetworks", "hnsEndpoints", "sdnRemoteArpMacAddress"} {
+		assert.Contains(t, document, section)
+	}
+	assert.NotContains(t, document, "errors")
+
+	var snapshot NetworkSnapshot
+	require.NoError(t, json.Unmarshal(b, &snapshot))
+	assert.False(t, snapshot.Timestamp.IsZero())
+	assert.JSONEq(t, `{"Name": "Ethernet 2", "ifIndex": 6, "Status": "Up"}`, string(snapshot.Adapters))
+	assert.JSONEq(t, `[{"InterfaceAlias": "Ethernet 2", "IPAddress": "10.240.0.4", "PrefixLength": 16}]`, string(snapshot.IPAddresses))
+	assert.JSONEq(t, `[{"DestinationPrefix": "0.0.0.0/0", "NextHop": "10.240.0.1", "InterfaceAlias": "Ethernet 2"}]`, string(snapshot.Routes))
+	assert.JSONEq(t, `{"ID": "8D2A1C3B-7E4F-4A6B-8C9D-1E2F3A4B5C6D", "Name": "azure", "Type": "L2Bridge"}`, string(snapshot.HNSNetworks))
+	assert.JSONEq(t, `[]`, string(snapshot.HNSEndpoints))
+	assert.Equal(t, SDNRemoteArpMacAddress, snapshot.SdnRemoteArpMacAddress)
+}
+
+func TestCaptureNetworkSnapshotPartialFailure(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(networkSnapshotResponder(GetHNSNetworksCommand, GetSdnRemoteArpMacAddressCommand))
+
+	b, err := CaptureNetworkSnapshot(context.Background(), mockExecClient)
+	require.NoError(t, err)
+
+	var snapshot NetworkSnapshot
+	require.NoError(t, json.Unmarshal(b, &snapshot))
+	assert.JSONEq(t, "null", string(snapshot.HNSNetworks))
+	assert.Empty(t, snapshot.SdnRemoteArpMacAddress)
+	assert.NotNil(t, snapshot.Adapters)
+	assert.NotNil(t, snapshot.HNSEndpoints)
+	assert.Equal(t, map[string]string{
+		"hnsNetworks":            ErrMockExec.Error(),
+		"sdnRemoteArpMacAddress": ErrMockExec.Error(),
+	}, snapshot.Errors)
+
+	// every section failing still makes a snapshot
+	b, err = CaptureNetworkSnapshot(context.Background(), NewMockExecClient(true))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &snapshot))
+	assert.Len(t, snapshot.Errors, 6)
+}
+
+func TestCaptureNetworkSnapshotCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mockExecClient := NewMockExecClient(false)
+	_, err := CaptureNetworkSnapshot(ctx, mockExecClient)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+// Offload states of an adapter
+const (
+	OffloadDisabled    = "Disabled"
+	OffloadEnabled     = "Enabled"
+	OffloadTxEnabled   = "TxEnabled"
+	OffloadRxEnabled   = "RxEnabled"
+	OffloadRxTxEnabled = "RxTxEnabled"
+)
+
+// checksumOffloadRegistryValues maps the checksum offload states to their standardized registry values
+var checksumOffloadRegistryValues = map[string]string{
+	OffloadDisabled:    "0",
+	OffloadTxEnabled:   "1",
+	OffloadRxEnabled:   "2",
+	OffloadRxTxEnabled: "3",
+}
+
+// lsoRegistryValues maps the large send offload states to their standardized registry values
+var lsoRegistryValues = map[string]string{
+	OffloadDisabled: "0",
+	OffloadEnabled:  "1",
+}
+
+// OffloadSettings are the checksum and large send offload states of an adapter.
+// The state of an offload the adapter does not support is empty.
+type OffloadSettings struct {
+	TCPChecksumIPv4 string
+	TCPChecksumIPv6 string
+	UDPChecksumIPv4 string
+	UDPChecksumIPv6 string
+	LSOv2IPv4       string
+	LSOv2IPv6       string
+}
+
+// Registry keywords of the offload settings of an adapter
+const (
+	tcpChecksumOffloadKeywordPrefix = "*TCPChecksumOffload"
+	udpChecksumOffloadKeywordPrefix = "*UDPChecksumOffload"
+	lsoV2KeywordPrefix              = "*LsoV2"
+)
+
+// GetOffloadSettings returns the checksum and large send offload states of the adapter
+func GetOffloadSettings(execClient ExecClient, adapterName string) (OffloadSettings, error) {
+	var settings OffloadSettings
+	fields := []struct {
+		keyword string
+		values  map[string]string
+		state   *string
+	}{
+		{tcpChecksumOffloadKeywordPrefix + "IPv4", checksumOffloadRegistryValues, &settings.TCPChecksumIPv4},
+		{tcpChecksumOffloadKeywordPrefix + "IPv6", checksumOffloadRegistryValues, &settings.TCPChecksumIPv6},
+		{udpChecksumOffloadKeywordPrefix + "IPv4", checksumOffloadRegistryValues, &settings.UDPChecksumIPv4},
+		{udpChecksumOffloadKeywordPrefix + "IPv6", checksumOffloadRegistryValues, &settings.UDPChecksumIPv6},
+		{lsoV2KeywordPrefix + "IPv4", lsoRegistryValues, &settings.LSOv2IPv4},
+		{lsoV2KeywordPrefix + "IPv6", lsoRegistryValues, &settings.LSOv2IPv6},
+	}
+
+	keywords := make([]string, 0, len(fields))
+	for _, f := range fields {
+		keywords = append(keywords, f.keyword)
+	}
+
+	properties, err := getAdvancedProperties(execClient, adapterName, keywords...)
+	if err != nil {
+		return OffloadSettings{}, err
+	}
+
+	for _, f := range fields {
+		property, ok := properties[f.keyword]
+		if !ok {
+			continue
+		}
+
+		if *f.state, err = enumAdvancedPropertyValue(adapterName, property, f.values); err != nil {
+			return OffloadSettings{}, err
+		}
+	}
+
+	return settings, nil
+}
+
+// SetTCPChecksumOffload sets the TCP checksum offload state of the adapter for the address family to one of
+// OffloadDisabled, OffloadTxEnabled, OffloadRxEnabled or OffloadRxTxEnabled.
+// Returns ErrFeatureUnsupported if the adapter does not support it.
+func SetTCPChecksumOffload(execClient ExecClient, adapterName string, family AddressFamily, state string) error {
+	return setOffload(execClient, adapterName, tcpChecksumOffloadKeywordPrefix, family, checksumOffloadRegistryValues, state)
+}
+
+// SetUDPChecksumOffload sets the UDP checksum offload state of the adapter for the address family to one of
+// OffloadDisabled, OffloadTxEnabled, OffloadRxEnabled or OffloadRxTxEnabled.
+// Returns ErrFeatureUnsupported if the adapter does not support it.
+func SetUDPChecksumOffload(execClient ExecClient, adapterName string, family AddressFamily, state string) error {
+	return setOffload(execClient, adapterName, udpChecksumOffloadKeywordPrefix, family, checksumOffloadRegistryValues, state)
+}
+
+// SetLSOv2 sets the large send offload version 2 state of the adapter for the address family
+// to OffloadDisabled or OffloadEnabled.
+// Returns ErrFeatureUnsupported if the adapter does not support it.
+func SetLSOv2(execClient ExecClient, adapterName string, family AddressFamily, state string) error {
+	return setOffload(execClient, adapterName, lsoV2KeywordPrefix, family, lsoRegistryValues, state)
+}
+
+// setOffload sets the offload setting with the given keyword prefix of the adapter for the address family
+func setOffload(execClient ExecClient, adapterName, keywordPrefix string, family AddressFamily,
+	values map[string]string, state string,
+) error {
+	af, err := powershellAddressFamily(family)
+	if err != nil {
+		return err
+	}
+
+	return setEnumAdvancedProperty(execClient, adapterName, keywordPrefix+af, values, state)
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offloadPropertiesFixture lacks *UDPChecksumOffloadIPv6
+const offloadPropertiesFixture = `[
+    {
+        "RegistryKeyword":  "*TCPChecksumOffloadIPv4",
+        "RegistryValue":  ["3"],
+        "DisplayValue":  "Rx & Tx Enabled",
+        "ValidRegistryValues":  ["0", "1", "2", "3"]
+    },
+    {
+        "RegistryKeyword":  "*TCPChecksumOffloadIPv6",
+        "RegistryValue":  ["2"],
+        "DisplayValue":  "Rx Enabled",
+        "ValidRegistryValues":  ["0", "1", "2", "3"]
+    },
+    {
+        "RegistryKeyword":  "*UDPChecksumOffloadIPv4",
+        "RegistryValue":  ["0"],
+        "DisplayValue":  "Disabled",
+        "ValidRegistryValues":  ["0", "1", "2", "3"]
+    },
+    {
+        "RegistryKeyword":  "*LsoV2IPv4",
+        "RegistryValue":  ["1"],
+        "DisplayValue":  "Enabled",
+        "ValidRegistryValues":  ["0", "1"]
+    },
+    {
+        "RegistryKeyword":  "*LsoV2IPv6",
+        "RegistryValue":  ["0"],
+        "DisplayValue":  "Disabled",
+        "ValidRegistryValues":  ["0", "1"]
+    }
+]`
+
+// offloadPropertiesResponder answers the advanced property queries of an adapter with offloadPropertiesFixture
+func offloadPropertiesResponder(cmd string) (string, error) {
+	if !strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty") {
+		return adapterNamesResponder(cmd)
+	}
+
+	var properties, matching []advancedProperty
+	if err := json.Unmarshal([]byte(offloadPropertiesFixture), &properties); err != nil {
+		return "", err
+	}
+
+	for _, property := range properties {
+		if strings.Contains(cmd, "'"+property.RegistryKeyword+"'") {
+			matching = append(matching, property)
+		}
+	}
+
+	out, err := json.Marshal(matching)
+	return string(out), err
+}
+
+func TestGetOffloadSettings(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(offloadPropertiesResponder)
+
+	settings, err := GetOffloadSettings(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.Equal(t, OffloadSettings{
+		TCPChecksumIPv4: OffloadRxTxEnabled,
+		TCPChecksumIPv6: OffloadRxEnabled,
+		UDPChecksumIPv4: OffloadDisabled,
+		UDPChecksumIPv6: "",
+		LSOv2IPv4:       OffloadEnabled,
+		LSOv2IPv6:       OffloadDisabled,
+	}, settings)
+
+	// the adapter is looked up, then all the settings are queried at once
+	assert.Len(t, mockExecClient.RecordedPowershellCommands(), 2)
+}
+
+func TestSetOffload(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(offloadPropertiesResponder)
+
+	require.NoError(t, SetLSOv2(mockExecClient, "Ethernet 2", AfINET, OffloadDisabled))
+	require.NoError(t, SetTCPChecksumOffload(mockExecClient, "Ethernet 2", AfINET6, OffloadRxTxEnabled))
+	// already set
+	require.NoError(t, SetUDPChecksumOffload(mockExecClient, "Ethernet 2", AfINET, OffloadDisabled))
+
+	assert.Equal(t, []string{
+		"Set-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '*LsoV2IPv4' -RegistryValue '0'",
+		"Set-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '*TCPChecksumOffloadIPv6' -RegistryValue '3'",
+	}, setCommands(mockExecClient.RecordedPowershellCommands()))
+}
+
+func TestSetOffloadInvalid(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(offloadPropertiesResponder)
+
+	require.Error(t, SetLSOv2(mockExecClient, "Ethernet 2", AfINET, OffloadRxEnabled))
+	require.Error(t, SetTCPChecksumOffload(mockExecClient, "Ethernet 2", AfINET, OffloadEnabled))
+	require.Error(t, SetTCPChecksumOffload(mockExecClient, "Ethernet 2", AfUnspec, OffloadDisabled))
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+
+	require.ErrorIs(t, SetUDPChecksumOffload(mockExecClient, "Ethernet 2", AfINET6, OffloadDisabled), adapter.ErrFeatureUnsupported)
+}
@@ -2,12 +2,118 @@ package platform
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// ErrInsufficientDiskSpace is returned when a volume has less free space than required to write a file
+var ErrInsufficientDiskSpace = errors.New("insufficient disk space")
+
+// getFreeDiskSpace is GetFreeDiskSpace, replaced in tests
+var getFreeDiskSpace = GetFreeDiskSpace
+
+// CheckFreeDiskSpace returns ErrInsufficientDiskSpace if the volume of path has less than required bytes available
+func CheckFreeDiskSpace(path string, required uint64) error {
+	free, err := getFreeDiskSpace(path)
+	if err != nil {
+		return err
+	}
+
+	if free < required {
+		return fmt.Errorf("%s has %d bytes available, %d required: %w", path, free, required, ErrInsufficientDiskSpace)
+	}
+
+	return nil
+}
+
+// lockFileExtension is the extension of the lock files under CNILockPath
+const lockFileExtension = ".lock"
+
+// processExists is ProcessExists, replaced in tests
+var processExists = ProcessExists
+
+// removeFile is os.Remove, replaced in tests
+var removeFile = os.Remove
+
+// ErrNoLockDirectory is returned when the platform keeps its lock files in the working directory of each process
+// rather than in a lock directory
+var ErrNoLockDirectory = errors.New("no CNI lock directory")
+
+// CleanStaleLockFiles removes the lock files under CNILockPath last modified more than maxAge ago, e.g. left behind
+// by crashed CNI invocations, and returns the number of files removed. Lock files whose holder, the process
+// whose id they contain, is still running are kept regardless of their age, as are the lock files which can't be read,
+// e.g. as they are locked. Returns ErrNoLockDirectory if CNILockPath is empty, as on Windows.
+func CleanStaleLockFiles(maxAge time.Duration) (int, error) {
+	if CNILockPath == "" {
+		return 0, ErrNoLockDirectory
+	}
+
+	return cleanStaleLockFiles(CNILockPath, maxAge)
+}
+
+func cleanStaleLockFiles(dir string, maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read lock directory %s: %w", dir, err)
+	}
+
+	cleaned, failed := 0, 0
+	var firstErr error
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != lockFileExtension {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+
+		// the lock file holds the id of the process which locked it last
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Keeping lock file %s which can't be read: %v", path, err)
+			continue
+		}
+
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && processExists(pid) {
+			log.Printf("Keeping lock file %s held by running process %d", path, pid)
+			continue
+		}
+
+		if err = removeFile(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to remove stale lock file %s: %v", path, err)
+			if failed++; firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		log.Printf("Removed stale lock file %s last modified at %v", path, info.ModTime())
+		cleaned++
+	}
+
+	if firstErr != nil {
+		return cleaned, fmt.Errorf("failed to remove %d stale lock files in %s: %w", failed, dir, firstErr)
+	}
+
+	return cleaned, nil
+}
+
 // ReadFileByLines reads file line by line and return array of lines.
 func ReadFileByLines(filename string) ([]string, error) {
 	var lineStrArr []string
@@ -51,6 +157,36 @@ func CheckIfFileExists(filepath string) (bool, error) {
 	return true, err
 }
 
+// FileSHA256 returns the hex encoded SHA-256 hash of the file content
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyFileSHA256 returns whether the SHA-256 hash of the file content is the hex encoded expected hash, in any case
+func VerifyFileSHA256(path, expected string) (bool, error) {
+	if b, err := hex.DecodeString(expected); err != nil || len(b) != sha256.Size {
+		return false, fmt.Errorf("invalid SHA-256 hash %q", expected)
+	}
+
+	actual, err := FileSHA256(path)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(actual, expected), nil
+}
+
 func CreateDirectory(dirPath string) error {
 	if dirPath == "" {
 		log.Printf("dirPath is empty, nothing to create.")
@@ -1,26 +1,179 @@
 package platform
 
 import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/Azure/azure-container-networking/log"
 )
 
 const (
 	defaultExecTimeout = 10
+
+	// defaultCommandLogPrefix tags the logs of the commands run by an ExecClient without a log prefix
+	defaultCommandLogPrefix = "[Azure-Utils]"
 )
 
 type execClient struct {
-	Timeout time.Duration
+	Timeout   time.Duration
+	hooks     ExecHooks
+	logPrefix string
+	// resolvePaths is whether the logs of the raw commands include the path of the executable they run
+	resolvePaths bool
+}
+
+// ExecClientOption configures an ExecClient returned by NewExecClient
+type ExecClientOption func(*execClient)
+
+// WithHooks calls the given hooks around every command
+func WithHooks(hooks ExecHooks) ExecClientOption {
+	return func(p *execClient) {
+		p.hooks = hooks
+	}
+}
+
+// WithLogPrefix tags the logs of the commands with prefix, e.g. [CNS-Platform], to attribute them to the component running them
+func WithLogPrefix(prefix string) ExecClientOption {
+	return func(p *execClient) {
+		p.logPrefix = prefix
+	}
+}
+
+// WithResolvedPaths logs the absolute path of the executable run by each raw command, i.e. one not run by PowerShell,
+// for auditing
+func WithResolvedPaths() ExecClientOption {
+	return func(p *execClient) {
+		p.resolvePaths = true
+	}
 }
 
 //nolint:revive // ExecClient make sense
 type ExecClient interface {
 	ExecuteCommand(command string) (string, error)
+	ExecutePowershellCommand(command string) (string, error)
 }
 
-func NewExecClient() ExecClient {
-	return &execClient{
+// ExecHooks are optional functions called around every command run by an ExecClient,
+// e.g. for tracing or fault injection in tests.
+type ExecHooks struct {
+	// BeforeExec is called before the command runs. A non-nil error skips the command and is returned instead.
+	BeforeExec func(command string) error
+	// AfterExec is called after the command ran, with its output and error.
+	AfterExec func(command, output string, err error)
+}
+
+// Logger is a destination for the logs of the commands run by an ExecClient
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// commandLogger is where the commands run by an ExecClient are logged
+var commandLogger = struct {
+	sync.RWMutex
+	l Logger
+}{l: log.GetStd()}
+
+// SetCommandLogger redirects the logs of the commands run by an ExecClient to l,
+// leaving the other logs of the package on the standard logger. A nil l restores the standard logger.
+func SetCommandLogger(l Logger) {
+	if l == nil {
+		l = log.GetStd()
+	}
+
+	commandLogger.Lock()
+	defer commandLogger.Unlock()
+	commandLogger.l = l
+}
+
+// logCommand logs a command about to be run, tagged with the log prefix of the client
+// and with the values of its password and secret arguments masked
+func (p *execClient) logCommand(command string) {
+	prefix := p.logPrefix
+	if prefix == "" {
+		prefix = defaultCommandLogPrefix
+	}
+
+	commandLogger.RLock()
+	defer commandLogger.RUnlock()
+	commandLogger.l.Printf("%s %s", prefix, redactCommand(command))
+}
+
+// logRawCommand logs a raw command about to be run, i.e. one not run by PowerShell,
+// followed by the path of the executable it runs if the client resolves paths
+func (p *execClient) logRawCommand(command string) {
+	if p.resolvePaths {
+		command = withResolvedPath(command)
+	}
+
+	p.logCommand(command)
+}
+
+// lookPath finds executables, replaced in tests
+var lookPath = exec.LookPath
+
+// ResolveExecutable returns the absolute path of the executable which running name would run,
+// as found in the directories of the PATH environment variable
+func ResolveExecutable(name string) (string, error) {
+	path, err := lookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve executable %s: %w", name, err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of executable %s: %w", path, err)
+	}
+
+	return abs, nil
+}
+
+// withResolvedPath returns the command followed by the path of the executable it runs, e.g. to detect a wrong
+// binary picked up from the PATH. The command is returned as is if it runs no executable, e.g. a shell builtin.
+func withResolvedPath(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return command
+	}
+
+	path, err := ResolveExecutable(fields[0])
+	if err != nil {
+		return command
+	}
+
+	return fmt.Sprintf("%s (%s)", command, path)
+}
+
+// secretArgRegex matches the values of the password and secret arguments of a command,
+// including the plain text converted by ConvertTo-SecureString and the /p: password switch of e.g. net use
+var secretArgRegex = regexp.MustCompile(`(?i)(-(?:password|secret|token)\s+|(?:password|secret|token)=|` +
+	`ConvertTo-SecureString\s+(?:-String\s+)?|\s/p(?:assword)?:)('[^']*'|"[^"]*"|\S+)`)
+
+// redactCommand returns the command with the values of its password and secret arguments masked
+func redactCommand(command string) string {
+	return secretArgRegex.ReplaceAllString(command, "${1}***")
+}
+
+// newCommandError returns the error of a failed command, naming the (redacted) command
+// and keeping err in the chain, e.g. for errors.As with an *exec.ExitError
+func newCommandError(command string, err error, stderr string) error {
+	return fmt.Errorf("command %q failed: %w (stderr: %s)", redactCommand(command), err, strings.TrimSpace(stderr))
+}
+
+func NewExecClient(opts ...ExecClientOption) ExecClient {
+	p := &execClient{
 		Timeout: defaultExecTimeout * time.Second,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
 }
 
 func NewExecClientTimeout(timeout time.Duration) ExecClient {
@@ -28,3 +181,45 @@ func NewExecClientTimeout(timeout time.Duration) ExecClient {
 		Timeout: timeout,
 	}
 }
+
+func (p *execClient) ExecuteCommand(command string) (string, error) {
+	return p.execWithHooks(command, p.executeCommand)
+}
+
+func (p *execClient) ExecutePowershellCommand(command string) (string, error) {
+	return p.execWithHooks(command, p.executePowershellCommand)
+}
+
+func (p *execClient) execWithHooks(command string, exec func(string) (string, error)) (string, error) {
+	if p.hooks.BeforeExec != nil {
+		if err := p.hooks.BeforeExec(command); err != nil {
+			return "", err
+		}
+	}
+
+	out, err := exec(command)
+
+	if p.hooks.AfterExec != nil {
+		p.hooks.AfterExec(command, out, err)
+	}
+
+	return out, err
+}
+
+// ExecuteCommandAllowExitCodes runs the command with execClient and treats the given nonzero exit codes
+// as success, e.g. for commands which exit nonzero when there is nothing to do.
+// No output is returned for a command exiting with one of okCodes.
+func ExecuteCommandAllowExitCodes(execClient ExecClient, command string, okCodes ...int) (string, error) {
+	out, err := execClient.ExecuteCommand(command)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		for _, code := range okCodes {
+			if exitErr.ExitCode() == code {
+				return out, nil
+			}
+		}
+	}
+
+	return out, err
+}
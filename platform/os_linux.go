@@ -6,10 +6,12 @@ package platform
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Azure/azure-container-networking/log"
@@ -40,6 +42,8 @@ const (
 	DNCRuntimePath = "/var/run/"
 	// This file contains OS details
 	osReleaseFile = "/etc/os-release"
+	// Exit code of pkill when no process matches
+	pkillNoProcessMatchedExitCode = 1
 )
 
 // GetOSInfo returns OS version information.
@@ -79,8 +83,8 @@ func GetLastRebootTime() (time.Time, error) {
 	return rebootTime.UTC(), nil
 }
 
-func (p *execClient) ExecuteCommand(command string) (string, error) {
-	log.Printf("[Azure-Utils] %s", command)
+func (p *execClient) executeCommand(command string) (string, error) {
+	p.logRawCommand(command)
 
 	var stderr bytes.Buffer
 	var out bytes.Buffer
@@ -95,12 +99,17 @@ func (p *execClient) ExecuteCommand(command string) (string, error) {
 
 	err := cmd.Run()
 	if err != nil {
-		return "", fmt.Errorf("%s:%s", err.Error(), stderr.String())
+		return "", newCommandError(command, err, stderr.String())
 	}
 
 	return out.String(), nil
 }
 
+// executePowershellCommand is a no-op on linux
+func (p *execClient) executePowershellCommand(_ string) (string, error) {
+	return "", nil
+}
+
 func SetOutboundSNAT(subnet string) error {
 	p := NewExecClient()
 	cmd := fmt.Sprintf("iptables -t nat -A POSTROUTING -m iprange ! --dst-range 168.63.129.16 -m addrtype ! --dst-type local ! -d %v -j MASQUERADE",
@@ -119,10 +128,10 @@ func ClearNetworkConfiguration() (bool, error) {
 	return false, nil
 }
 
+// KillProcessByName kills the processes matching processName. No such process running is not an error.
 func KillProcessByName(processName string) error {
-	p := NewExecClient()
 	cmd := fmt.Sprintf("pkill -f %v", processName)
-	_, err := p.ExecuteCommand(cmd)
+	_, err := ExecuteCommandAllowExitCodes(NewExecClient(), cmd, pkillNoProcessMatchedExitCode)
 	return err
 }
 
@@ -132,6 +141,26 @@ func SetSdnRemoteArpMacAddress() error {
 	return nil
 }
 
+func setSdnRemoteArpMacAddress(_ ExecClient) error {
+	return nil
+}
+
+// ProcessExists returns whether a process with the given id is running
+func ProcessExists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	// signal 0 only checks the process exists, EPERM meaning it does but belongs to another user
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// IsRunningAsService returns whether the process runs as a Windows service, which it never does on linux
+func IsRunningAsService() (bool, error) {
+	return false, nil
+}
+
 func GetOSDetails() (map[string]string, error) {
 	linesArr, err := ReadFileByLines(osReleaseFile)
 	if err != nil || len(linesArr) <= 0 {
@@ -179,3 +208,13 @@ func PrintDependencyPackageDetails() {
 func ReplaceFile(source, destination string) error {
 	return os.Rename(source, destination)
 }
+
+// GetFreeDiskSpace returns the number of bytes available to the process on the volume of path
+func GetFreeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, fmt.Errorf("failed to get free disk space of %s: %w", path, err)
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -1,10 +1,16 @@
 package platform
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestMain(m *testing.M) {
@@ -81,3 +87,398 @@ func TestFileExists(t *testing.T) {
 		t.Errorf("Returned file found")
 	}
 }
+
+func TestExecClientBeforeExecHook(t *testing.T) {
+	errInjected := errors.New("injected error")
+	var afterCalled bool
+	client := NewExecClient(WithHooks(ExecHooks{
+		BeforeExec: func(string) error {
+			return errInjected
+		},
+		AfterExec: func(string, string, error) {
+			afterCalled = true
+		},
+	}))
+
+	if _, err := client.ExecuteCommand("echo hello"); !errors.Is(err, errInjected) {
+		t.Errorf("Expected injected error but got %v", err)
+	}
+
+	if afterCalled {
+		t.Errorf("AfterExec called for a command skipped by BeforeExec")
+	}
+}
+
+func TestExecClientAfterExecHook(t *testing.T) {
+	var commands, outputs []string
+	client := NewExecClient(WithHooks(ExecHooks{
+		AfterExec: func(command, output string, err error) {
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			commands = append(commands, command)
+			outputs = append(outputs, strings.TrimSpace(output))
+		},
+	}))
+
+	if _, err := client.ExecuteCommand("echo hello"); err != nil {
+		t.Errorf("ExecuteCommand failed: %v", err)
+	}
+
+	if len(commands) != 1 || commands[0] != "echo hello" || outputs[0] != "hello" {
+		t.Errorf("AfterExec recorded commands %v with outputs %v", commands, outputs)
+	}
+}
+
+type recordingLogger struct {
+	logs []string
+}
+
+func (l *recordingLogger) Printf(format string, args ...interface{}) {
+	l.logs = append(l.logs, fmt.Sprintf(format, args...))
+}
+
+func TestSetCommandLogger(t *testing.T) {
+	l := &recordingLogger{}
+	SetCommandLogger(l)
+	defer SetCommandLogger(nil)
+
+	if _, err := NewExecClient().ExecuteCommand("echo hello"); err != nil {
+		t.Errorf("ExecuteCommand failed: %v", err)
+	}
+
+	if len(l.logs) != 1 || l.logs[0] != "[Azure-Utils] echo hello" {
+		t.Errorf("Command logger recorded %v", l.logs)
+	}
+
+	SetCommandLogger(nil)
+	(&execClient{}).logCommand("echo hello")
+	if len(l.logs) != 1 {
+		t.Errorf("Command logger recorded %v after it was reset", l.logs)
+	}
+}
+
+func TestCommandLogPrefix(t *testing.T) {
+	l := &recordingLogger{}
+	SetCommandLogger(l)
+	defer SetCommandLogger(nil)
+
+	NewExecClient(WithLogPrefix("[CNS-Platform]")).(*execClient).logCommand("echo hello")
+	NewExecClient().(*execClient).logCommand("echo hello")
+
+	expected := []string{"[CNS-Platform] echo hello", "[Azure-Utils] echo hello"}
+	if len(l.logs) != len(expected) || l.logs[0] != expected[0] || l.logs[1] != expected[1] {
+		t.Errorf("Command logger recorded %v, expected %v", l.logs, expected)
+	}
+}
+
+func TestCommandLogRedactsSecrets(t *testing.T) {
+	l := &recordingLogger{}
+	SetCommandLogger(l)
+	defer SetCommandLogger(nil)
+
+	client := NewExecClient().(*execClient)
+	client.logCommand("New-LocalUser -Name azure -Password 'secret'")
+	client.logCommand("$p = ConvertTo-SecureString 'secret' -AsPlainText -Force")
+	client.logCommand("net use \\\\share /user:azure /p:secret")
+
+	expected := []string{
+		"[Azure-Utils] New-LocalUser -Name azure -Password ***",
+		"[Azure-Utils] $p = ConvertTo-SecureString *** -AsPlainText -Force",
+		"[Azure-Utils] net use \\\\share /user:azure /p:***",
+	}
+	if len(l.logs) != len(expected) {
+		t.Fatalf("Command logger recorded %v, expected %v", l.logs, expected)
+	}
+
+	for i := range expected {
+		if l.logs[i] != expected[i] || strings.Contains(l.logs[i], "secret") {
+			t.Errorf("Command logger recorded %q, expected %q", l.logs[i], expected[i])
+		}
+	}
+}
+
+// shell is an executable present on every host
+func shell() string {
+	if runtime.GOOS == "windows" {
+		return "cmd"
+	}
+	return "sh"
+}
+
+func TestResolveExecutable(t *testing.T) {
+	path, err := ResolveExecutable(shell())
+	if err != nil {
+		t.Fatalf("ResolveExecutable failed: %v", err)
+	}
+
+	if !filepath.IsAbs(path) || !strings.HasPrefix(filepath.Base(path), shell()) {
+		t.Errorf("ResolveExecutable resolved %s to %s", shell(), path)
+	}
+
+	if _, err = ResolveExecutable("azure-cni-no-such-executable"); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("ResolveExecutable of missing executable returned error %v", err)
+	}
+}
+
+func TestCommandLogResolvedPath(t *testing.T) {
+	l := &recordingLogger{}
+	SetCommandLogger(l)
+	defer SetCommandLogger(nil)
+
+	path, err := ResolveExecutable(shell())
+	if err != nil {
+		t.Fatalf("ResolveExecutable failed: %v", err)
+	}
+
+	client := NewExecClient(WithResolvedPaths()).(*execClient)
+	client.logRawCommand(shell() + " /c exit")
+	client.logRawCommand("azure-cni-no-such-executable --version")
+	// PowerShell commands run cmdlets rather than executables
+	client.logCommand(shell() + " /c exit")
+
+	expected := []string{
+		"[Azure-Utils] " + shell() + " /c exit (" + path + ")",
+		"[Azure-Utils] azure-cni-no-such-executable --version",
+		"[Azure-Utils] " + shell() + " /c exit",
+	}
+	if len(l.logs) != len(expected) || l.logs[0] != expected[0] || l.logs[1] != expected[1] || l.logs[2] != expected[2] {
+		t.Errorf("Command logger recorded %v, expected %v", l.logs, expected)
+	}
+}
+
+func TestExecuteCommandAllowExitCodes(t *testing.T) {
+	client := NewExecClient()
+
+	if _, err := ExecuteCommandAllowExitCodes(client, "exit 3", 1, 3); err != nil {
+		t.Errorf("ExecuteCommandAllowExitCodes failed for allowed exit code: %v", err)
+	}
+
+	_, err := ExecuteCommandAllowExitCodes(client, "exit 3", 1)
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("ExecuteCommandAllowExitCodes returned %v for disallowed exit code", err)
+	}
+}
+
+func TestExecuteCommandError(t *testing.T) {
+	_, err := NewExecClient().ExecuteCommand("echo oops 1>&2 && exit 3")
+	if err == nil {
+		t.Fatal("ExecuteCommand succeeded for a failing command")
+	}
+
+	if !strings.Contains(err.Error(), `command "echo oops 1>&2 && exit 3" failed`) || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("Error %q does not name the command and its stderr", err)
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("Error %v does not wrap the exit error", err)
+	}
+}
+
+func TestRedactCommand(t *testing.T) {
+	tests := map[string]string{
+		"net user azure -Password 'p@ss word'":                             "net user azure -Password ***",
+		"New-LocalUser -Name azure -Password \"secret\"":                   "New-LocalUser -Name azure -Password ***",
+		"runas /user:azure password=hunter2 cmd":                           "runas /user:azure password=*** cmd",
+		"Get-NetAdapter -Name 'Ethernet 2'":                                "Get-NetAdapter -Name 'Ethernet 2'",
+		"curl -H 'x' --data token=abc123 http://localhost":                 "curl -H 'x' --data token=*** http://localhost",
+		"$p = ConvertTo-SecureString 'p@ss' -AsPlainText -Force":           "$p = ConvertTo-SecureString *** -AsPlainText -Force",
+		"$p = ConvertTo-SecureString -String \"p@ss\" -AsPlainText -Force": "$p = ConvertTo-SecureString -String *** -AsPlainText -Force",
+		"msdeploy -verb:sync /u:azure /p:hunter2 /dest:x":                  "msdeploy -verb:sync /u:azure /p:*** /dest:x",
+		"net use \\\\share /password:hunter2":                              "net use \\\\share /password:***",
+		"copy C:\\ip:80 D:\\":                                              "copy C:\\ip:80 D:\\",
+	}
+
+	for command, expected := range tests {
+		if redacted := redactCommand(command); redacted != expected {
+			t.Errorf("redactCommand(%q) = %q, expected %q", command, redacted, expected)
+		}
+	}
+}
+
+func TestGetFreeDiskSpace(t *testing.T) {
+	free, err := GetFreeDiskSpace(os.TempDir())
+	if err != nil {
+		t.Fatalf("GetFreeDiskSpace failed: %v", err)
+	}
+
+	if free == 0 {
+		t.Errorf("GetFreeDiskSpace returned no free space")
+	}
+
+	if _, err = GetFreeDiskSpace("/does/not/exist"); err == nil {
+		t.Errorf("GetFreeDiskSpace succeeded for a missing path")
+	}
+}
+
+func TestCheckFreeDiskSpace(t *testing.T) {
+	getFreeDiskSpace = func(string) (uint64, error) { return 1024, nil }
+	defer func() { getFreeDiskSpace = GetFreeDiskSpace }()
+
+	if err := CheckFreeDiskSpace("C:\\k", 1024); err != nil {
+		t.Errorf("CheckFreeDiskSpace failed with enough space: %v", err)
+	}
+
+	if err := CheckFreeDiskSpace("C:\\k", 1025); !errors.Is(err, ErrInsufficientDiskSpace) {
+		t.Errorf("CheckFreeDiskSpace returned %v instead of ErrInsufficientDiskSpace", err)
+	}
+
+	errInjected := errors.New("injected error")
+	getFreeDiskSpace = func(string) (uint64, error) { return 0, errInjected }
+	if err := CheckFreeDiskSpace("C:\\k", 1); !errors.Is(err, errInjected) {
+		t.Errorf("CheckFreeDiskSpace returned %v instead of the space query error", err)
+	}
+}
+
+// test1SHA256 is the sha256sum of testfiles/test1
+const test1SHA256 = "a7d266b0cae314237ec66d8fa6a707004cac43d44493c187743704a650e8a345"
+
+func TestFileSHA256(t *testing.T) {
+	hash, err := FileSHA256("testfiles/test1")
+	if err != nil {
+		t.Fatalf("FileSHA256 failed: %v", err)
+	}
+
+	if hash != test1SHA256 {
+		t.Errorf("FileSHA256 returned %s instead of %s", hash, test1SHA256)
+	}
+
+	if _, err = FileSHA256("testfiles/filenotfound"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("FileSHA256 returned %v for a missing file", err)
+	}
+}
+
+func TestVerifyFileSHA256(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		match    bool
+		wantErr  bool
+	}{
+		{name: "match", expected: test1SHA256, match: true},
+		{name: "match uppercase", expected: strings.ToUpper(test1SHA256), match: true},
+		{name: "mismatch", expected: strings.Repeat("0", 64), match: false},
+		{name: "not hex", expected: strings.Repeat("z", 64), wantErr: true},
+		{name: "truncated", expected: test1SHA256[:32], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := VerifyFileSHA256("testfiles/test1", tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyFileSHA256 returned error %v, want error %v", err, tt.wantErr)
+			}
+
+			if match != tt.match {
+				t.Errorf("VerifyFileSHA256 returned %v instead of %v", match, tt.match)
+			}
+		})
+	}
+}
+
+func TestProcessExists(t *testing.T) {
+	if !ProcessExists(os.Getpid()) {
+		t.Errorf("ProcessExists returned false for the running test process")
+	}
+
+	if ProcessExists(-1) {
+		t.Errorf("ProcessExists returned true for an invalid process id")
+	}
+}
+
+func TestCleanStaleLockFiles(t *testing.T) {
+	const livePID, deadPID = 1234, 5678
+	processExists = func(pid int) bool { return pid == livePID }
+	defer func() { processExists = ProcessExists }()
+
+	dir := t.TempDir()
+	stale := time.Now().Add(-time.Hour)
+	files := []struct {
+		name    string
+		content string
+		modTime time.Time
+		cleaned bool
+	}{
+		{name: "azure-vnet.lock", content: strconv.Itoa(deadPID), modTime: stale, cleaned: true},
+		{name: "azure-vnet-ipam.lock", content: "", modTime: stale, cleaned: true},
+		{name: "azure-cns.lock", content: strconv.Itoa(livePID), modTime: stale},
+		{name: "azure-endpoints.lock", content: strconv.Itoa(deadPID), modTime: time.Now()},
+		{name: "azure-vnet.json", content: "{}", modTime: stale},
+	}
+
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, []byte(f.content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		if err := os.Chtimes(path, f.modTime, f.modTime); err != nil {
+			t.Fatalf("Failed to set modification time of %s: %v", path, err)
+		}
+	}
+
+	cleaned, err := cleanStaleLockFiles(dir, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("cleanStaleLockFiles failed: %v", err)
+	}
+
+	if cleaned != 2 {
+		t.Errorf("cleanStaleLockFiles cleaned %d files, expected 2", cleaned)
+	}
+
+	for _, f := range files {
+		_, err = os.Stat(filepath.Join(dir, f.name))
+		if exists := err == nil; exists == f.cleaned {
+			t.Errorf("%s exists: %t, expected it to be cleaned: %t", f.name, exists, f.cleaned)
+		}
+	}
+}
+
+func TestCleanStaleLockFilesUnreadableOrUnremovable(t *testing.T) {
+	errRemove := errors.New("sharing violation")
+	removeFile = func(path string) error {
+		if filepath.Base(path) == "azure-cns.lock" {
+			return errRemove
+		}
+		return os.Remove(path)
+	}
+	defer func() { removeFile = os.Remove }()
+
+	dir := t.TempDir()
+	stale := time.Now().Add(-time.Hour)
+	for _, name := range []string{"azure-cns.lock", "azure-vnet.lock"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		if err := os.Chtimes(path, stale, stale); err != nil {
+			t.Fatalf("Failed to set modification time of %s: %v", path, err)
+		}
+	}
+
+	// a lock file which can't be read, as its target doesn't exist
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "azure-cnm.lock")); err != nil {
+		t.Skipf("Failed to create symlink: %v", err)
+	}
+
+	// failing to remove a lock file doesn't stop the cleanup of the others
+	cleaned, err := cleanStaleLockFiles(dir, 10*time.Minute)
+	if !errors.Is(err, errRemove) || cleaned != 1 {
+		t.Errorf("cleanStaleLockFiles returned %d, %v", cleaned, err)
+	}
+
+	if _, err = os.Lstat(filepath.Join(dir, "azure-cnm.lock")); err != nil {
+		t.Errorf("Lock file which can't be read was removed: %v", err)
+	}
+}
+
+func TestCleanStaleLockFilesMissingDir(t *testing.T) {
+	cleaned, err := cleanStaleLockFiles(filepath.Join(t.TempDir(), "missing"), time.Minute)
+	if err != nil || cleaned != 0 {
+		t.Errorf("cleanStaleLockFiles of missing directory returned %d, %v", cleaned, err)
+	}
+}
@@ -5,15 +5,25 @@ package platform
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unsafe"
 
 	"github.com/Azure/azure-container-networking/log"
 	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
 )
 
 const (
@@ -55,21 +65,90 @@ const (
 	// for vlan tagged arp requests
 	SDNRemoteArpMacAddress = "12-34-56-78-9a-bc"
 
+	// Name of the SDNRemoteArpMacAddress value of the hns state registry key
+	sdnRemoteArpMacAddressValueName = "SDNRemoteArpMacAddress"
+
 	// Command to get SDNRemoteArpMacAddress registry key
 	GetSdnRemoteArpMacAddressCommand = "(Get-ItemProperty " +
 		"-Path HKLM:\\SYSTEM\\CurrentControlSet\\Services\\hns\\State -Name SDNRemoteArpMacAddress).SDNRemoteArpMacAddress"
 
 	// Command to set SDNRemoteArpMacAddress registry key
 	SetSdnRemoteArpMacAddressCommand = "Set-ItemProperty " +
-		"-Path HKLM:\\SYSTEM\\CurrentControlSet\\Services\\hns\\State -Name SDNRemoteArpMacAddress -Value \"12-34-56-78-9a-bc\""
+		"-Path HKLM:\\SYSTEM\\CurrentControlSet\\Services\\hns\\State -Name SDNRemoteArpMacAddress -Value '12-34-56-78-9a-bc'"
 
 	// Command to restart HNS service
 	RestartHnsServiceCommand = "Restart-Service -Name hns"
+
+	// Interval between successive checks of the SDNRemoteArpMacAddress regkey
+	defaultSdnRemoteArpMacAddressMonitorInterval = 30 * time.Second
+
+	// Exit code of taskkill when no process matches
+	taskkillProcessNotFoundExitCode = 128
+
+	// Prefix of powershell commands making powershell write its output as UTF-8 regardless of the host's code page,
+	// so that non-ASCII names such as adapter names round-trip
+	powershellUTF8OutputPrefix = "[Console]::OutputEncoding = [System.Text.Encoding]::UTF8; "
+
+	// Prefix of powershell commands preventing cmdlets from writing progress records, which may pollute their output
+	powershellNoProgressPrefix = "$ProgressPreference = 'SilentlyContinue'; "
+
+	// Trivial command run to measure the powershell startup latency
+	powershellLatencyProbeCommand = "exit 0"
+
+	// Command to get the powershell execution policy of each scope, with the enums as their names
+	GetExecutionPolicyListCommand = "Get-ExecutionPolicy -List | Select-Object @{Name='Scope'; Expression={$_.Scope.ToString()}}, " +
+		"@{Name='ExecutionPolicy'; Expression={$_.ExecutionPolicy.ToString()}}"
+
+	// Command to get the effective powershell execution policy
+	GetExecutionPolicyCommand = "(Get-ExecutionPolicy).ToString()"
+
+	// Command to get the product type of the OS, 1 for a workstation, 2 for a domain controller and 3 for a server
+	GetOSProductTypeCommand = "(Get-CimInstance -ClassName Win32_OperatingSystem).ProductType"
+
+	// Execution policy of a scope which doesn't set any
+	executionPolicyUndefined = "Undefined"
+
+	// Product type of a workstation, i.e. a client SKU of Windows
+	osProductTypeWorkstation = "1"
 )
 
 // Flag to check if sdnRemoteArpMacAddress registry key is set
 var sdnRemoteArpMacAddressSet = false
 
+// showPowershellProgress is whether powershell commands may write progress records, which they don't by default
+var showPowershellProgress atomic.Bool
+
+// powershellProgressRegex matches the progress records serialized in the output of powershell commands
+var powershellProgressRegex = regexp.MustCompile(`(?s)#< CLIXML\s*<Objs.*?</Objs>`)
+
+// ErrPowershellUnavailable is returned by powershell commands when the host has no powershell executable
+var ErrPowershellUnavailable = errors.New("powershell is not available on the host")
+
+// powershell caches the result of the lookup of the powershell executable, done at the first powershell command
+var powershell struct {
+	sync.Mutex
+	probed bool
+	path   string
+	err    error
+}
+
+// getPowershellPath returns the path of the powershell executable, or ErrPowershellUnavailable
+func getPowershellPath() (string, error) {
+	powershell.Lock()
+	defer powershell.Unlock()
+
+	if !powershell.probed {
+		powershell.path, powershell.err = lookPath("powershell.exe")
+		if powershell.err != nil {
+			log.Errorf("Failed to find powershell executable: %v", powershell.err)
+			powershell.err = ErrPowershellUnavailable
+		}
+		powershell.probed = true
+	}
+
+	return powershell.path, powershell.err
+}
+
 // GetOSInfo returns OS version information.
 func GetOSInfo() string {
 	return "windows"
@@ -96,8 +175,35 @@ func GetLastRebootTime() (time.Time, error) {
 	return rebootTime.UTC(), nil
 }
 
-func (p *execClient) ExecuteCommand(command string) (string, error) {
-	log.Printf("[Azure-Utils] %s", command)
+// Command to get the boot time of the host recorded by WMI, in UTC
+const GetWMIBootTimeCommand = "(Get-CimInstance Win32_OperatingSystem).LastBootUpTime.ToUniversalTime().ToString('o')"
+
+// getTickRebootTime is GetLastRebootTime, replaced in tests
+var getTickRebootTime = GetLastRebootTime
+
+// CompareRebootTimeSources returns the boot time of the host derived from the tick count, as GetLastRebootTime does,
+// the boot time recorded by WMI, and how many seconds the former is after the latter.
+// The tick count derived time is the current time minus the uptime, so it moves with every adjustment of the clock,
+// e.g. by time synchronization after a resume, while WMI records the boot time once, which makes it the preferred source.
+func CompareRebootTimeSources(execClient ExecClient) (tickTime, wmiTime time.Time, driftSeconds float64, err error) {
+	if tickTime, err = getTickRebootTime(); err != nil {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("failed to get boot time from tick count: %w", err)
+	}
+
+	out, err := execClient.ExecutePowershellCommand(GetWMIBootTimeCommand)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("failed to get boot time from WMI: %w", err)
+	}
+
+	if wmiTime, err = time.Parse(time.RFC3339Nano, strings.TrimSpace(out)); err != nil {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("failed to parse WMI boot time %q: %w", out, err)
+	}
+
+	return tickTime, wmiTime.UTC(), tickTime.Sub(wmiTime).Seconds(), nil
+}
+
+func (p *execClient) executeCommand(command string) (string, error) {
+	p.logRawCommand(command)
 
 	var stderr bytes.Buffer
 	var out bytes.Buffer
@@ -107,12 +213,175 @@ func (p *execClient) ExecuteCommand(command string) (string, error) {
 
 	err := cmd.Run()
 	if err != nil {
-		return "", fmt.Errorf("%s:%s", err.Error(), stderr.String())
+		return "", newCommandError(command, err, stderr.String())
 	}
 
 	return out.String(), nil
 }
 
+// LogonType is how the user a command runs as is logged on, one of the non-interactive LOGON32_LOGON types of LogonUser
+type LogonType uint32
+
+const (
+	// LogonBatch logs on users with the right to log on as a batch job, e.g. accounts of scheduled tasks
+	LogonBatch LogonType = 4
+
+	// LogonService logs on users with the right to log on as a service, e.g. service accounts
+	LogonService LogonType = 5
+
+	// Logon provider of the users ExecuteCommandAsUser runs commands as
+	logon32ProviderDefault = 0
+
+	// Length under which a password is not masked wherever it appears as a token of a command or its output,
+	// as it would also mask unrelated text
+	minRedactedPasswordLength = 6
+)
+
+// procLogonUser is LogonUserW, which x/sys/windows doesn't expose
+var procLogonUser = windows.NewLazySystemDLL("advapi32.dll").NewProc("LogonUserW")
+
+// createProcessAsUser is logonAndCreateProcess, replaced in tests
+var createProcessAsUser = logonAndCreateProcess
+
+// userCommandExecutor is an ExecClient which can run commands as another user
+type userCommandExecutor interface {
+	ExecuteCommandAsUser(logonType LogonType, username, password, command string) (string, error)
+}
+
+// ExecuteCommandAsUser runs the command with execClient as the given user, e.g. a service account, rather than the current one.
+// username is either a local user, DOMAIN\user or user@domain, logged on as a service.
+// The password is never logged, nor returned in errors. Returns an error if execClient can't run commands as another user.
+func ExecuteCommandAsUser(execClient ExecClient, username, password, command string) (string, error) {
+	return ExecuteCommandAsUserWithLogonType(execClient, LogonService, username, password, command)
+}
+
+// ExecuteCommandAsUserWithLogonType is ExecuteCommandAsUser logging the user on with logonType,
+// e.g. LogonBatch for accounts which may only log on as a batch job.
+func ExecuteCommandAsUserWithLogonType(execClient ExecClient, logonType LogonType, username, password, command string) (string, error) {
+	userExecClient, ok := execClient.(userCommandExecutor)
+	if !ok {
+		return "", fmt.Errorf("exec client %T can't run commands as another user", execClient)
+	}
+
+	return userExecClient.ExecuteCommandAsUser(logonType, username, password, command)
+}
+
+// ExecuteCommandAsUser runs the command as the given user, logged on with logonType.
+// The hooks of the client get the command with the password redacted.
+func (p *execClient) ExecuteCommandAsUser(logonType LogonType, username, password, command string) (string, error) {
+	if username == "" {
+		return "", fmt.Errorf("no user to run command as")
+	}
+
+	// redact the password wherever it appears in the command, not only as a password argument
+	redacted := redactPassword(redactCommand(command), password)
+
+	user, domain := username, "."
+	if i := strings.Index(username, "\\"); i >= 0 {
+		domain, user = username[:i], username[i+1:]
+	} else if strings.Contains(username, "@") {
+		// a user principal name carries its domain
+		domain = ""
+	}
+
+	return p.execWithHooks(redacted, func(string) (string, error) {
+		p.logCommand(fmt.Sprintf("%s (as %s)", redacted, username))
+
+		out, stderr, err := createProcessAsUser(logonType, user, domain, password, command, p.Timeout)
+		if err != nil {
+			return "", fmt.Errorf("failed to run command as %s: %w", username, newCommandError(redacted, err, redactPassword(stderr, password)))
+		}
+
+		return out, nil
+	})
+}
+
+// redactPassword returns s with the password masked where it appears as a whole token, e.g. an argument value.
+// Passwords shorter than minRedactedPasswordLength are left to redactCommand.
+func redactPassword(s, password string) string {
+	if len(password) < minRedactedPasswordLength {
+		return s
+	}
+
+	var b strings.Builder
+	start := 0
+	for {
+		i := strings.Index(s[start:], password)
+		if i < 0 {
+			break
+		}
+
+		i += start
+		end := i + len(password)
+		if !isTokenBoundary(s, i-1) || !isTokenBoundary(s, end) {
+			// part of a longer token, look for the password again from the next byte
+			b.WriteString(s[start : i+1])
+			start = i + 1
+			continue
+		}
+
+		b.WriteString(s[start:i])
+		b.WriteString("***")
+		start = end
+	}
+	b.WriteString(s[start:])
+
+	return b.String()
+}
+
+// isTokenBoundary returns whether the byte of s at i, if any, separates tokens, e.g. arguments or a name from its value
+func isTokenBoundary(s string, i int) bool {
+	return i < 0 || i >= len(s) || strings.IndexByte(" \t\r\n'\"=:,;", s[i]) >= 0
+}
+
+// logonAndCreateProcess logs the user on and runs the command with its token through CreateProcessAsUser,
+// returning the stdout and stderr of the command. An empty domain is that of a user principal name.
+// The command is killed after timeout, if positive.
+func logonAndCreateProcess(logonType LogonType, user, domain, password, command string, timeout time.Duration) (stdout, stderr string, err error) {
+	userPtr, err := windows.UTF16PtrFromString(user)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid user %q: %w", user, err)
+	}
+
+	var domainPtr *uint16
+	if domain != "" {
+		if domainPtr, err = windows.UTF16PtrFromString(domain); err != nil {
+			return "", "", fmt.Errorf("invalid domain %q: %w", domain, err)
+		}
+	}
+
+	passwordPtr, err := windows.UTF16PtrFromString(password)
+	if err != nil {
+		// not wrapping the error, which names the password
+		return "", "", errors.New("invalid password")
+	}
+
+	var token windows.Token
+	r, _, callErr := procLogonUser.Call(uintptr(unsafe.Pointer(userPtr)), uintptr(unsafe.Pointer(domainPtr)),
+		uintptr(unsafe.Pointer(passwordPtr)), uintptr(logonType), logon32ProviderDefault, uintptr(unsafe.Pointer(&token)))
+	if r == 0 {
+		return "", "", fmt.Errorf("failed to log on user %s: %w", user, callErr)
+	}
+	defer token.Close()
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var errBuf bytes.Buffer
+	var outBuf bytes.Buffer
+	cmd := exec.CommandContext(ctx, "cmd", "/c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Token: syscall.Token(token), HideWindow: true}
+	cmd.Stderr = &errBuf
+	cmd.Stdout = &outBuf
+
+	err = cmd.Run()
+	return outBuf.String(), errBuf.String(), err
+}
+
 func SetOutboundSNAT(subnet string) error {
 	return nil
 }
@@ -132,22 +401,110 @@ func ClearNetworkConfiguration() (bool, error) {
 	return true, nil
 }
 
-func KillProcessByName(processName string) {
-	p := NewExecClient()
+// KillProcessByName kills the processes with the given image name. No such process running is not an error.
+func KillProcessByName(processName string) error {
 	cmd := fmt.Sprintf("taskkill /IM %v /F", processName)
-	p.ExecuteCommand(cmd)
+	_, err := ExecuteCommandAllowExitCodes(NewExecClient(), cmd, taskkillProcessNotFoundExitCode)
+	return err
+}
+
+// GetProcessesCommand is the command to get the id, parent id and UTC creation time of the processes of the host
+const GetProcessesCommand = "Get-CimInstance Win32_Process | Select-Object ProcessId, ParentProcessId, " +
+	"@{Name='CreationDate'; Expression={if ($_.CreationDate) { $_.CreationDate.ToUniversalTime().ToString('o') }}}"
+
+// KillProcessTree kills the process and its descendants, children before their parent so that none is
+// left orphaned. Processes exiting meanwhile are not an error.
+func KillProcessTree(execClient ExecClient, pid int) error {
+	processes, err := ExecutePowershellJSON[[]struct {
+		ProcessID       int `json:"ProcessId"`
+		ParentProcessID int `json:"ParentProcessId"`
+		CreationDate    time.Time
+	}](execClient, GetProcessesCommand)
+	if err != nil {
+		return fmt.Errorf("failed to get processes: %w", err)
+	}
+
+	created := make(map[int]time.Time, len(processes))
+	for _, p := range processes {
+		created[p.ProcessID] = p.CreationDate
+	}
+
+	children := make(map[int][]int)
+	for _, p := range processes {
+		if p.ProcessID == p.ParentProcessID {
+			continue
+		}
+
+		// the parent id is stale if the parent exited and its id was reused by a process created later
+		parentCreated, ok := created[p.ParentProcessID]
+		if ok && !parentCreated.IsZero() && !p.CreationDate.IsZero() && !p.CreationDate.After(parentCreated) {
+			continue
+		}
+
+		children[p.ParentProcessID] = append(children[p.ParentProcessID], p.ProcessID)
+	}
+
+	// processes with unknown creation times may still form a cycle, don't kill a process twice
+	killed := make(map[int]bool)
+	var kill func(pid int) error
+	kill = func(pid int) error {
+		killed[pid] = true
+		sort.Ints(children[pid])
+		for _, child := range children[pid] {
+			if killed[child] {
+				continue
+			}
+
+			if err := kill(child); err != nil {
+				return err
+			}
+		}
+
+		cmd := fmt.Sprintf("taskkill /PID %d /F", pid)
+		if _, err := ExecuteCommandAllowExitCodes(execClient, cmd, taskkillProcessNotFoundExitCode); err != nil {
+			return fmt.Errorf("failed to kill process %d: %w", pid, err)
+		}
+
+		return nil
+	}
+
+	return kill(pid)
 }
 
 // ExecutePowershellCommand executes powershell command
 func ExecutePowershellCommand(command string) (string, error) {
-	ps, err := exec.LookPath("powershell.exe")
+	return NewExecClient().ExecutePowershellCommand(command)
+}
+
+// MeasurePowershellStartupLatency runs a trivial powershell command samples times and returns the average
+// time taken, which is dominated by the startup of the powershell process on the host
+func MeasurePowershellStartupLatency(execClient ExecClient, samples int) (time.Duration, error) {
+	if samples <= 0 {
+		return 0, fmt.Errorf("invalid number of samples %d", samples)
+	}
+
+	var total time.Duration
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		if _, err := execClient.ExecutePowershellCommand(powershellLatencyProbeCommand); err != nil {
+			return 0, fmt.Errorf("failed to run powershell latency probe: %w", err)
+		}
+		total += time.Since(start)
+	}
+
+	return total / time.Duration(samples), nil
+}
+
+// executePowershellCommand executes powershell command
+func (p *execClient) executePowershellCommand(command string) (string, error) {
+	ps, err := getPowershellPath()
 	if err != nil {
-		return "", fmt.Errorf("Failed to find powershell executable")
+		return "", err
 	}
 
-	log.Printf("[Azure-Utils] %s", command)
+	p.logCommand(command)
 
-	cmd := exec.Command(ps, command)
+	cmd := exec.Command(ps, withUTF8Output(withoutProgress(command)))
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -155,38 +512,316 @@ func ExecutePowershellCommand(command string) (string, error) {
 
 	err = cmd.Run()
 	if err != nil {
-		return "", fmt.Errorf("%s:%s", err.Error(), stderr.String())
+		return "", newCommandError(command, err, stderr.String())
+	}
+
+	return strings.TrimSpace(stripPowershellProgress(stdout.String())), nil
+}
+
+// SetPowershellProgressSuppressed sets whether powershell commands are prevented from writing progress records,
+// which they are by default since progress records may corrupt the parsing of their output
+func SetPowershellProgressSuppressed(suppressed bool) {
+	showPowershellProgress.Store(!suppressed)
+}
+
+// withoutProgress returns the powershell command not writing progress records, unless they are not suppressed
+func withoutProgress(command string) string {
+	if showPowershellProgress.Load() {
+		return command
+	}
+
+	return powershellNoProgressPrefix + command
+}
+
+// stripPowershellProgress returns the output of a powershell command without the progress records written to it
+func stripPowershellProgress(out string) string {
+	return powershellProgressRegex.ReplaceAllString(out, "")
+}
+
+// withUTF8Output returns the powershell command writing the output of command as UTF-8
+func withUTF8Output(command string) string {
+	return powershellUTF8OutputPrefix + command
+}
+
+// escapePowershellString escapes s to be used within a single quoted powershell string
+func escapePowershellString(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// powershellStringList returns the values as a powershell list of single quoted strings, e.g. 'a', 'b'
+func powershellStringList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, "'"+escapePowershellString(v)+"'")
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+// splitPowershellLines splits powershell output into its non-empty trimmed lines
+func splitPowershellLines(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// parsePowershellBool parses the output of a boolean powershell expression, True or False in any case, or 1 or 0
+func parsePowershellBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("unrecognized powershell boolean %q", s)
+}
+
+// unmarshalPowershellJSONList unmarshals the ConvertTo-Json output of a list of objects into v.
+// ConvertTo-Json emits a bare object rather than an array when the list has a single element,
+// and nothing at all when the list is empty.
+func unmarshalPowershellJSONList(out string, v interface{}) error {
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(out, "{") {
+		out = "[" + out + "]"
+	}
+
+	return json.Unmarshal([]byte(out), v)
+}
+
+// ExecutePowershellJSON runs the powershell command with its output converted to JSON and unmarshals it into a T.
+// Empty output is the zero T. For slice types a single object is unmarshalled as a slice of one element,
+// as powershell does not wrap a single result in an array.
+func ExecutePowershellJSON[T any](client ExecClient, command string) (T, error) {
+	var result T
+
+	out, err := client.ExecutePowershellCommand(command + " | ConvertTo-Json -Depth 10")
+	if err != nil {
+		return result, err
+	}
+
+	if reflect.TypeOf(result) != nil && reflect.TypeOf(result).Kind() == reflect.Slice {
+		err = unmarshalPowershellJSONList(out, &result)
+	} else if out = strings.TrimSpace(out); out != "" {
+		err = json.Unmarshal([]byte(out), &result)
+	}
+
+	if err != nil {
+		return result, fmt.Errorf("failed to parse output of %q: %w", command, err)
+	}
+
+	return result, nil
+}
+
+// powershellErrorRecordMarker prefixes the error record written by a command wrapped by withErrorRecord
+const powershellErrorRecordMarker = "ACN-POWERSHELL-ERROR:"
+
+// PowershellError is the error record of a failed powershell command
+type PowershellError struct {
+	Message               string `json:"Message"`
+	CategoryInfo          string `json:"CategoryInfo"`
+	FullyQualifiedErrorID string `json:"FullyQualifiedErrorId"`
+}
+
+func (e *PowershellError) Error() string {
+	return fmt.Sprintf("%s (category: %s, id: %s)", e.Message, e.CategoryInfo, e.FullyQualifiedErrorID)
+}
+
+// withErrorRecord wraps the powershell command so that a failure writes its error record as JSON to the output.
+// Errors are made terminating so that they are caught.
+func withErrorRecord(command string) string {
+	return "try { $ErrorActionPreference = 'Stop'; " + command + " } catch { " +
+		"Write-Output ('" + powershellErrorRecordMarker + "' + (@{ Message = $_.Exception.Message; " +
+		"CategoryInfo = $_.CategoryInfo.ToString(); FullyQualifiedErrorId = $_.FullyQualifiedErrorId } | ConvertTo-Json -Compress)) }"
+}
+
+// parsePowershellErrorRecord returns the output of a command wrapped by withErrorRecord without the error record,
+// and the error record if the command failed
+func parsePowershellErrorRecord(out string) (string, *PowershellError, error) {
+	i := strings.LastIndex(out, powershellErrorRecordMarker)
+	if i < 0 {
+		return out, nil, nil
+	}
+
+	var record PowershellError
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out[i+len(powershellErrorRecordMarker):])), &record); err != nil {
+		return "", nil, fmt.Errorf("failed to parse powershell error record: %w", err)
+	}
+
+	return strings.TrimSpace(out[:i]), &record, nil
+}
+
+// ExecutePowershellCommandWithErrorRecord runs the powershell command and returns the error record of its failure
+// as a *PowershellError, which has more details than the stderr of the command
+func ExecutePowershellCommandWithErrorRecord(execClient ExecClient, command string) (string, error) {
+	out, err := execClient.ExecutePowershellCommand(withErrorRecord(command))
+	if err != nil {
+		return "", err
+	}
+
+	out, record, err := parsePowershellErrorRecord(out)
+	if err != nil {
+		return "", err
+	}
+
+	if record != nil {
+		return out, fmt.Errorf("command %q failed: %w", command, record)
+	}
+
+	return out, nil
+}
+
+// GetPowershellExecutionPolicyList returns the powershell execution policy of each scope, e.g. LocalMachine: RemoteSigned
+func GetPowershellExecutionPolicyList(execClient ExecClient) (map[string]string, error) {
+	list, err := ExecutePowershellJSON[[]struct {
+		Scope           string
+		ExecutionPolicy string
+	}](execClient, GetExecutionPolicyListCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get execution policies: %w", err)
+	}
+
+	if len(list) == 0 {
+		return nil, errors.New("no execution policy")
+	}
+
+	policies := make(map[string]string, len(list))
+	for _, policy := range list {
+		policies[policy.Scope] = policy.ExecutionPolicy
+	}
+
+	return policies, nil
+}
+
+// GetPowershellExecutionPolicy returns the effective powershell execution policy. If no scope sets one,
+// it is the default of the OS, Restricted on client SKUs of Windows and RemoteSigned on Windows Server.
+func GetPowershellExecutionPolicy(execClient ExecClient) (string, error) {
+	out, err := execClient.ExecutePowershellCommand(GetExecutionPolicyCommand)
+	if err != nil {
+		return "", fmt.Errorf("failed to get execution policy: %w", err)
+	}
+
+	policy := strings.TrimSpace(out)
+	if policy == "" {
+		return "", errors.New("no execution policy")
+	}
+
+	if !strings.EqualFold(policy, executionPolicyUndefined) {
+		return policy, nil
+	}
+
+	out, err = execClient.ExecutePowershellCommand(GetOSProductTypeCommand)
+	if err != nil {
+		return "", fmt.Errorf("failed to get OS product type for default execution policy: %w", err)
+	}
+
+	if strings.TrimSpace(out) == osProductTypeWorkstation {
+		return "Restricted", nil
+	}
+
+	return "RemoteSigned", nil
+}
+
+// ExecutionPolicyBlocksCommands returns whether the execution policy prevents the commands of this package from running.
+// Restricted prevents loading the script modules of cmdlets such as the hns ones, and AllSigned prevents it for unsigned ones.
+func ExecutionPolicyBlocksCommands(policy string) bool {
+	return strings.EqualFold(policy, "Restricted") || strings.EqualFold(policy, "AllSigned")
+}
+
+// isSdnRemoteArpMacAddress returns whether value is the SDNRemoteArpMacAddress, in any MAC address notation
+func isSdnRemoteArpMacAddress(value string) bool {
+	mac, err := NormalizeMAC(value)
+	if err != nil {
+		return false
 	}
 
-	return strings.TrimSpace(stdout.String()), nil
+	expected, _ := NormalizeMAC(SDNRemoteArpMacAddress)
+	return mac == expected
 }
 
 // SetSdnRemoteArpMacAddress sets the regkey for SDNRemoteArpMacAddress needed for multitenancy
 func SetSdnRemoteArpMacAddress() error {
+	return setSdnRemoteArpMacAddress(NewExecClient())
+}
+
+// setSdnRemoteArpMacAddress sets the regkey for SDNRemoteArpMacAddress with execClient, once per process
+func setSdnRemoteArpMacAddress(execClient ExecClient) error {
 	if sdnRemoteArpMacAddressSet == false {
-		result, err := ExecutePowershellCommand(GetSdnRemoteArpMacAddressCommand)
+		set, err := setSdnRemoteArpMacAddressIfRequired(execClient)
 		if err != nil {
 			return err
 		}
 
-		// Set the reg key if not already set or has incorrect value
-		if result != SDNRemoteArpMacAddress {
-			if _, err = ExecutePowershellCommand(SetSdnRemoteArpMacAddressCommand); err != nil {
-				log.Printf("Failed to set SDNRemoteArpMacAddress due to error %s", err.Error())
-				return err
-			}
+		// HNS may only be installed later, in which case the regkey still has to be set then
+		sdnRemoteArpMacAddressSet = set
+	}
 
-			log.Printf("[Azure CNS] SDNRemoteArpMacAddress regKey set successfully. Restarting hns service.")
-			if _, err := ExecutePowershellCommand(RestartHnsServiceCommand); err != nil {
-				log.Printf("Failed to Restart HNS Service due to error %s", err.Error())
-				return err
+	return nil
+}
+
+// MonitorAndSetSdnRemoteArpMacAddress checks the SDNRemoteArpMacAddress regkey every interval and sets it again
+// if it was reset, e.g. by a driver or HNS reinstall. HNS is only restarted when the regkey had to be set.
+// Returns when ctx is done.
+func MonitorAndSetSdnRemoteArpMacAddress(ctx context.Context, interval time.Duration, execClient ExecClient) {
+	if interval <= 0 {
+		interval = defaultSdnRemoteArpMacAddressMonitorInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("context cancelled, stopping SDNRemoteArpMacAddress monitoring: %v", ctx.Err())
+			return
+		case <-ticker.C:
+			if _, err := setSdnRemoteArpMacAddressIfRequired(execClient); err != nil {
+				log.Errorf("Failed to set SDNRemoteArpMacAddress, continuing: %v", err)
 			}
 		}
+	}
+}
 
-		sdnRemoteArpMacAddressSet = true
+// setSdnRemoteArpMacAddressIfRequired sets the SDNRemoteArpMacAddress regkey and restarts HNS
+// if HNS is present and the regkey is not set to the expected value.
+// Returns whether the regkey is set, i.e. false if there is no HNS on the host.
+func setSdnRemoteArpMacAddressIfRequired(execClient ExecClient) (bool, error) {
+	hnsEnabled, err := IsHNSEnabled(execClient)
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	// Nothing to set on a host without HNS
+	if !hnsEnabled {
+		log.Printf("HNS is not enabled on the host, skipping SDNRemoteArpMacAddress")
+		return false, nil
+	}
+
+	result, err := GetHNSStateValue(execClient, sdnRemoteArpMacAddressValueName)
+	if err != nil {
+		return false, err
+	}
+
+	// Set the reg key if not already set or has incorrect value
+	if !isSdnRemoteArpMacAddress(result) {
+		log.Printf("[Azure CNS] Setting SDNRemoteArpMacAddress regKey and restarting hns service.")
+		if err = SetHNSStateValue(execClient, sdnRemoteArpMacAddressValueName, SDNRemoteArpMacAddress, true); err != nil {
+			log.Printf("Failed to set SDNRemoteArpMacAddress due to error %s", err.Error())
+			return false, err
+		}
+	}
+
+	return true, nil
 }
 
 func GetOSDetails() (map[string]string, error) {
@@ -223,8 +858,23 @@ func GetProcessNameByID(pidstr string) (string, error) {
 func PrintDependencyPackageDetails() {
 }
 
+// Number of attempts of a file replacement failing with a sharing violation, e.g. while an antivirus scans the file,
+// and the delay before the first retry, doubled before each following one
+const replaceFileAttempts = 5
+
+var replaceFileRetryDelay = 100 * time.Millisecond
+
+// moveFileEx moves files, replaced in tests
+var moveFileEx = windows.MoveFileEx
+
 // https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-movefileexw
 func ReplaceFile(source, destination string) error {
+	return replaceFile(NewExecClient(), source, destination)
+}
+
+// replaceFile replaces destination with source, retrying while the move fails with a sharing violation.
+// If it still does after replaceFileAttempts, the processes holding the files are logged.
+func replaceFile(execClient ExecClient, source, destination string) error {
 	src, err := syscall.UTF16PtrFromString(source)
 	if err != nil {
 		return err
@@ -235,5 +885,85 @@ func ReplaceFile(source, destination string) error {
 		return err
 	}
 
-	return windows.MoveFileEx(src, dest, windows.MOVEFILE_REPLACE_EXISTING|windows.MOVEFILE_WRITE_THROUGH)
+	delay := replaceFileRetryDelay
+	for attempt := 1; ; attempt++ {
+		err = moveFileEx(src, dest, windows.MOVEFILE_REPLACE_EXISTING|windows.MOVEFILE_WRITE_THROUGH)
+		if !errors.Is(err, windows.ERROR_SHARING_VIOLATION) {
+			return err
+		}
+
+		if attempt == replaceFileAttempts {
+			logFileHolders(execClient, source, destination)
+			return fmt.Errorf("failed to replace %s with %s: %w", destination, source, err)
+		}
+
+		log.Printf("Replacing %s failed with a sharing violation, retrying in %v", destination, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// logFileHolders logs the processes which have the given files loaded, to diagnose which one,
+// typically an antivirus, prevents operations on them
+func logFileHolders(execClient ExecClient, paths ...string) {
+	for _, path := range paths {
+		cmd := fmt.Sprintf("Get-Process | Where-Object { $_.Path -eq '%[1]s' -or ($_.Modules.FileName -contains '%[1]s') } | "+
+			"ForEach-Object { \"$($_.ProcessName) ($($_.Id))\" }", escapePowershellString(path))
+		out, err := execClient.ExecutePowershellCommand(cmd)
+		if err != nil {
+			log.Errorf("Failed to get the processes holding %s: %v", path, err)
+			continue
+		}
+
+		log.Printf("Processes holding %s: %v", path, splitPowershellLines(out))
+	}
+}
+
+// GetFreeDiskSpace returns the number of bytes available to the process on the volume of path
+func GetFreeDiskSpace(path string) (uint64, error) {
+	dir, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var freeBytes uint64
+	if err = windows.GetDiskFreeSpaceEx(dir, &freeBytes, nil, nil); err != nil {
+		return 0, fmt.Errorf("failed to get free disk space of %s: %w", path, err)
+	}
+
+	return freeBytes, nil
+}
+
+// stillActiveExitCode is the exit code of processes which are still running
+const stillActiveExitCode = 259
+
+// ProcessExists returns whether a process with the given id is running
+func ProcessExists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// the process exists if it is only not accessible
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(handle) //nolint:errcheck // best effort to close the query handle
+
+	var exitCode uint32
+	if err = windows.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return true
+	}
+
+	return exitCode == stillActiveExitCode
+}
+
+// IsRunningAsService returns whether the process runs as a Windows service rather than from a console
+func IsRunningAsService() (bool, error) {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return false, fmt.Errorf("failed to determine if running as a service: %w", err)
+	}
+
+	return isService, nil
 }
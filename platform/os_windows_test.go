@@ -0,0 +1,815 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
+)
+
+// sdnRemoteArpMacAddressResponder answers powershell commands of a host with HNS
+// whose SDNRemoteArpMacAddress registry value is currentValue
+func sdnRemoteArpMacAddressResponder(currentValue string) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		switch cmd {
+		case CheckIfHNSServiceExistsCommand, CheckIfHNSStatePathExistsCommand:
+			return "True", nil
+		case GetSdnRemoteArpMacAddressCommand:
+			return currentValue, nil
+		}
+		return "", nil
+	}
+}
+
+func TestSetSdnRemoteArpMacAddress(t *testing.T) {
+	sdnRemoteArpMacAddressSet = false
+	defer func() { sdnRemoteArpMacAddressSet = false }()
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(sdnRemoteArpMacAddressResponder(""))
+	require.NoError(t, setSdnRemoteArpMacAddress(mockExecClient))
+	assert.Equal(t, []string{
+		CheckIfHNSServiceExistsCommand,
+		CheckIfHNSStatePathExistsCommand,
+		GetSdnRemoteArpMacAddressCommand,
+		SetSdnRemoteArpMacAddressCommand,
+		RestartHnsServiceCommand,
+	}, mockExecClient.RecordedPowershellCommands())
+	assert.Empty(t, mockExecClient.RecordedCommands())
+
+	// the value is only set once per process
+	require.NoError(t, setSdnRemoteArpMacAddress(mockExecClient))
+	assert.Len(t, mockExecClient.RecordedPowershellCommands(), 5)
+}
+
+func TestSetSdnRemoteArpMacAddressAlreadySet(t *testing.T) {
+	sdnRemoteArpMacAddressSet = false
+	defer func() { sdnRemoteArpMacAddressSet = false }()
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(sdnRemoteArpMacAddressResponder(SDNRemoteArpMacAddress))
+
+	require.NoError(t, setSdnRemoteArpMacAddress(mockExecClient))
+	assert.Equal(t, []string{
+		CheckIfHNSServiceExistsCommand,
+		CheckIfHNSStatePathExistsCommand,
+		GetSdnRemoteArpMacAddressCommand,
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestSetSdnRemoteArpMacAddressError(t *testing.T) {
+	sdnRemoteArpMacAddressSet = false
+	defer func() { sdnRemoteArpMacAddressSet = false }()
+
+	mockExecClient := NewMockExecClient(true)
+	require.ErrorIs(t, setSdnRemoteArpMacAddress(mockExecClient), ErrMockExec)
+	assert.Equal(t, []string{CheckIfHNSServiceExistsCommand}, mockExecClient.RecordedPowershellCommands())
+	assert.False(t, sdnRemoteArpMacAddressSet)
+}
+
+func TestSetSdnRemoteArpMacAddressDifferentNotation(t *testing.T) {
+	sdnRemoteArpMacAddressSet = false
+	defer func() { sdnRemoteArpMacAddressSet = false }()
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(sdnRemoteArpMacAddressResponder("12-34-56-78-9A-BC"))
+
+	require.NoError(t, setSdnRemoteArpMacAddress(mockExecClient))
+	assert.NotContains(t, mockExecClient.RecordedPowershellCommands(), SetSdnRemoteArpMacAddressCommand)
+}
+
+func TestSetSdnRemoteArpMacAddressNoHNS(t *testing.T) {
+	tests := []struct {
+		name          string
+		serviceExists string
+		pathExists    string
+	}{
+		{"no hns service", "False", "True"},
+		{"no hns state key", "True", "False"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sdnRemoteArpMacAddressSet = false
+			defer func() { sdnRemoteArpMacAddressSet = false }()
+
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				switch cmd {
+				case CheckIfHNSServiceExistsCommand:
+					return tt.serviceExists, nil
+				case CheckIfHNSStatePathExistsCommand:
+					return tt.pathExists, nil
+				}
+				return "", ErrMockExec
+			})
+
+			require.NoError(t, setSdnRemoteArpMacAddress(mockExecClient))
+			commands := mockExecClient.RecordedPowershellCommands()
+			assert.NotContains(t, commands, GetSdnRemoteArpMacAddressCommand)
+			assert.NotContains(t, commands, SetSdnRemoteArpMacAddressCommand)
+			assert.NotContains(t, commands, RestartHnsServiceCommand)
+			assert.False(t, sdnRemoteArpMacAddressSet)
+		})
+	}
+}
+
+func TestSetSdnRemoteArpMacAddressHNSInstalledLater(t *testing.T) {
+	sdnRemoteArpMacAddressSet = false
+	defer func() { sdnRemoteArpMacAddressSet = false }()
+
+	hnsEnabled := false
+	responder := sdnRemoteArpMacAddressResponder("")
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == CheckIfHNSServiceExistsCommand && !hnsEnabled {
+			return "False", nil
+		}
+		return responder(cmd)
+	})
+
+	require.NoError(t, setSdnRemoteArpMacAddress(mockExecClient))
+	assert.NotContains(t, mockExecClient.RecordedPowershellCommands(), SetSdnRemoteArpMacAddressCommand)
+
+	// the regkey is set once HNS is present
+	hnsEnabled = true
+	require.NoError(t, setSdnRemoteArpMacAddress(mockExecClient))
+	assert.Contains(t, mockExecClient.RecordedPowershellCommands(), SetSdnRemoteArpMacAddressCommand)
+	assert.True(t, sdnRemoteArpMacAddressSet)
+}
+
+func TestMonitorAndSetSdnRemoteArpMacAddressReconcile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the regkey was reset, the first tick sets it and restarts hns
+	responder := sdnRemoteArpMacAddressResponder("")
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == RestartHnsServiceCommand {
+			cancel()
+		}
+		return responder(cmd)
+	})
+
+	MonitorAndSetSdnRemoteArpMacAddress(ctx, time.Millisecond, mockExecClient)
+	assert.Equal(t, []string{
+		CheckIfHNSServiceExistsCommand,
+		CheckIfHNSStatePathExistsCommand,
+		GetSdnRemoteArpMacAddressCommand,
+		SetSdnRemoteArpMacAddressCommand,
+		RestartHnsServiceCommand,
+	}, mockExecClient.RecordedPowershellCommands()[:5])
+}
+
+func TestMonitorAndSetSdnRemoteArpMacAddressAlreadyCorrect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// stop after the regkey was checked on two ticks
+	checks := 0
+	responder := sdnRemoteArpMacAddressResponder(SDNRemoteArpMacAddress)
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == GetSdnRemoteArpMacAddressCommand {
+			if checks++; checks >= 2 {
+				cancel()
+			}
+		}
+		return responder(cmd)
+	})
+
+	MonitorAndSetSdnRemoteArpMacAddress(ctx, time.Millisecond, mockExecClient)
+	assert.GreaterOrEqual(t, checks, 2)
+	assert.NotContains(t, mockExecClient.RecordedPowershellCommands(), SetSdnRemoteArpMacAddressCommand)
+	assert.NotContains(t, mockExecClient.RecordedPowershellCommands(), RestartHnsServiceCommand)
+}
+
+func TestMeasurePowershellStartupLatency(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		time.Sleep(time.Millisecond)
+		return "", nil
+	})
+
+	latency, err := MeasurePowershellStartupLatency(mockExecClient, 3)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, latency, time.Millisecond)
+	assert.Equal(t, []string{
+		powershellLatencyProbeCommand,
+		powershellLatencyProbeCommand,
+		powershellLatencyProbeCommand,
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestMeasurePowershellStartupLatencyError(t *testing.T) {
+	_, err := MeasurePowershellStartupLatency(NewMockExecClient(false), 0)
+	require.Error(t, err)
+
+	_, err = MeasurePowershellStartupLatency(NewMockExecClient(true), 3)
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestPowershellUnavailable(t *testing.T) {
+	resetPowershellProbe := func() {
+		powershell.Lock()
+		defer powershell.Unlock()
+		powershell.probed = false
+	}
+	resetPowershellProbe()
+	defer resetPowershellProbe()
+
+	lookups := 0
+	lookPath = func(string) (string, error) {
+		lookups++
+		return "", exec.ErrNotFound
+	}
+	defer func() { lookPath = exec.LookPath }()
+
+	_, err := NewExecClient().ExecutePowershellCommand("Get-NetAdapter")
+	require.ErrorIs(t, err, ErrPowershellUnavailable)
+
+	_, err = GetProcessNameByID("1")
+	require.ErrorIs(t, err, ErrPowershellUnavailable)
+
+	_, err = GetMellanoxPriorityVLANTag(NewExecClient(), "Ethernet 3")
+	require.ErrorIs(t, err, ErrPowershellUnavailable)
+
+	// the lookup is done once
+	assert.Equal(t, 1, lookups)
+}
+
+func TestWithUTF8Output(t *testing.T) {
+	assert.Equal(t, "[Console]::OutputEncoding = [System.Text.Encoding]::UTF8; Get-NetAdapter", withUTF8Output("Get-NetAdapter"))
+}
+
+func TestWithoutProgress(t *testing.T) {
+	assert.Equal(t, "$ProgressPreference = 'SilentlyContinue'; Get-NetAdapter", withoutProgress("Get-NetAdapter"))
+
+	SetPowershellProgressSuppressed(false)
+	defer SetPowershellProgressSuppressed(true)
+	assert.Equal(t, "Get-NetAdapter", withoutProgress("Get-NetAdapter"))
+}
+
+func TestStripPowershellProgress(t *testing.T) {
+	// the PriorityVLANTag value following a progress record
+	out := "#< CLIXML\r\n<Objs Version=\"1.1.0.1\" xmlns=\"http://schemas.microsoft.com/powershell/2004/04\">" +
+		"<Obj S=\"progress\" RefId=\"0\"><TN RefId=\"0\"><T>System.Management.Automation.PSCustomObject</T></TN>" +
+		"<MS><I64 N=\"SourceId\">1</I64><PR N=\"Record\"><AV>Preparing modules for first use.</AV></PR></MS></Obj></Objs>\r\n3\r\n"
+
+	value, err := strconv.Atoi(strings.TrimSpace(stripPowershellProgress(out)))
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+
+	assert.Equal(t, "3", stripPowershellProgress("3"))
+}
+
+func TestExecutePowershellCommandNonASCIIOutput(t *testing.T) {
+	out, err := NewExecClient().ExecutePowershellCommand("Write-Output 'Ethernet Ünïcødé 网络'")
+	require.NoError(t, err)
+	assert.Equal(t, "Ethernet Ünïcødé 网络", out)
+}
+
+func TestExecutePowershellJSON(t *testing.T) {
+	type adapter struct {
+		Name   string
+		Status string
+	}
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence([]func(string) (string, error){
+		func(cmd string) (string, error) {
+			assert.Equal(t, "Get-NetAdapter -Name 'Ethernet' | ConvertTo-Json -Depth 10", cmd)
+			return `{"Name": "Ethernet", "Status": "Up"}`, nil
+		},
+		// a single result is not wrapped in an array
+		func(string) (string, error) { return `{"Name": "Ethernet", "Status": "Up"}`, nil },
+		func(string) (string, error) {
+			return `[{"Name": "Ethernet", "Status": "Up"}, {"Name": "Ethernet 2", "Status": "Disconnected"}]`, nil
+		},
+		func(string) (string, error) { return "", nil },
+		func(string) (string, error) { return "", nil },
+	})
+
+	object, err := ExecutePowershellJSON[adapter](mockExecClient, "Get-NetAdapter -Name 'Ethernet'")
+	require.NoError(t, err)
+	assert.Equal(t, adapter{Name: "Ethernet", Status: "Up"}, object)
+
+	list, err := ExecutePowershellJSON[[]adapter](mockExecClient, "Get-NetAdapter")
+	require.NoError(t, err)
+	assert.Equal(t, []adapter{{Name: "Ethernet", Status: "Up"}}, list)
+
+	list, err = ExecutePowershellJSON[[]adapter](mockExecClient, "Get-NetAdapter")
+	require.NoError(t, err)
+	assert.Equal(t, []adapter{{Name: "Ethernet", Status: "Up"}, {Name: "Ethernet 2", Status: "Disconnected"}}, list)
+
+	// empty results
+	list, err = ExecutePowershellJSON[[]adapter](mockExecClient, "Get-NetAdapter")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	object, err = ExecutePowershellJSON[adapter](mockExecClient, "Get-NetAdapter -Name 'Ethernet 3'")
+	require.NoError(t, err)
+	assert.Zero(t, object)
+}
+
+func TestExecutePowershellJSONError(t *testing.T) {
+	_, err := ExecutePowershellJSON[[]string](NewMockExecClient(true), "Get-NetAdapter")
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "WARNING: not json", nil
+	})
+	_, err = ExecutePowershellJSON[map[string]string](mockExecClient, "Get-NetAdapter")
+	require.Error(t, err)
+}
+
+func TestExecutePowershellCommandWithErrorRecord(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandSequence([]func(string) (string, error){
+		func(cmd string) (string, error) {
+			assert.Equal(t, withErrorRecord("Get-NetAdapter -Name 'Ethernet'"), cmd)
+			return "Ethernet", nil
+		},
+		func(string) (string, error) {
+			return `ACN-POWERSHELL-ERROR:{"Message":"No MSFT_NetAdapter objects found with property 'Name' equal to 'Ethernet 9'.",` +
+				`"CategoryInfo":"ObjectNotFound: (Ethernet 9:String) [Get-NetAdapter], CimJobException",` +
+				`"FullyQualifiedErrorId":"CmdletizationQuery_NotFound_Name,Get-NetAdapter"}`, nil
+		},
+		func(string) (string, error) { return "ACN-POWERSHELL-ERROR:not json", nil },
+		func(string) (string, error) { return "", ErrMockExec },
+	})
+
+	out, err := ExecutePowershellCommandWithErrorRecord(mockExecClient, "Get-NetAdapter -Name 'Ethernet'")
+	require.NoError(t, err)
+	assert.Equal(t, "Ethernet", out)
+
+	_, err = ExecutePowershellCommandWithErrorRecord(mockExecClient, "Get-NetAdapter -Name 'Ethernet 9'")
+	var record *PowershellError
+	require.ErrorAs(t, err, &record)
+	assert.Equal(t, PowershellError{
+		Message:               "No MSFT_NetAdapter objects found with property 'Name' equal to 'Ethernet 9'.",
+		CategoryInfo:          "ObjectNotFound: (Ethernet 9:String) [Get-NetAdapter], CimJobException",
+		FullyQualifiedErrorID: "CmdletizationQuery_NotFound_Name,Get-NetAdapter",
+	}, *record)
+
+	_, err = ExecutePowershellCommandWithErrorRecord(mockExecClient, "Get-NetAdapter")
+	require.Error(t, err)
+
+	_, err = ExecutePowershellCommandWithErrorRecord(mockExecClient, "Get-NetAdapter")
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+const executionPolicyListFixture = `[
+    {
+        "Scope":  "MachinePolicy",
+        "ExecutionPolicy":  "Undefined"
+    },
+    {
+        "Scope":  "UserPolicy",
+        "ExecutionPolicy":  "Undefined"
+    },
+    {
+        "Scope":  "Process",
+        "ExecutionPolicy":  "Bypass"
+    },
+    {
+        "Scope":  "CurrentUser",
+        "ExecutionPolicy":  "Undefined"
+    },
+    {
+        "Scope":  "LocalMachine",
+        "ExecutionPolicy":  "RemoteSigned"
+    }
+]`
+
+func TestGetPowershellExecutionPolicyList(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, GetExecutionPolicyListCommand+" | ConvertTo-Json -Depth 10", cmd)
+		return executionPolicyListFixture, nil
+	})
+
+	policies, err := GetPowershellExecutionPolicyList(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"MachinePolicy": "Undefined",
+		"UserPolicy":    "Undefined",
+		"Process":       "Bypass",
+		"CurrentUser":   "Undefined",
+		"LocalMachine":  "RemoteSigned",
+	}, policies)
+
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) { return "", nil })
+	_, err = GetPowershellExecutionPolicyList(mockExecClient)
+	require.Error(t, err)
+
+	_, err = GetPowershellExecutionPolicyList(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestGetPowershellExecutionPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         string
+		productType    string
+		expectedPolicy string
+		blocks         bool
+	}{
+		{
+			name:           "bypass",
+			policy:         "Bypass\r\n",
+			expectedPolicy: "Bypass",
+		},
+		{
+			name:           "restricted",
+			policy:         "Restricted\r\n",
+			expectedPolicy: "Restricted",
+			blocks:         true,
+		},
+		{
+			name:           "all signed",
+			policy:         "AllSigned\r\n",
+			expectedPolicy: "AllSigned",
+			blocks:         true,
+		},
+		{
+			name:           "undefined on a server",
+			policy:         "Undefined\r\n",
+			productType:    "3\r\n",
+			expectedPolicy: "RemoteSigned",
+		},
+		{
+			name:           "undefined on a workstation",
+			policy:         "Undefined\r\n",
+			productType:    "1\r\n",
+			expectedPolicy: "Restricted",
+			blocks:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+				if cmd == GetOSProductTypeCommand {
+					return tt.productType, nil
+				}
+				return tt.policy, nil
+			})
+
+			policy, err := GetPowershellExecutionPolicy(mockExecClient)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedPolicy, policy)
+			assert.Equal(t, tt.blocks, ExecutionPolicyBlocksCommands(policy))
+		})
+	}
+
+	_, err := GetPowershellExecutionPolicy(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestIsRunningAsService(t *testing.T) {
+	isService, err := IsRunningAsService()
+	require.NoError(t, err)
+	assert.False(t, isService)
+}
+
+func TestParsePowershellBool(t *testing.T) {
+	for _, s := range []string{"True", "true", "TRUE", "tRuE", "1", " True\r\n"} {
+		b, err := parsePowershellBool(s)
+		require.NoError(t, err, s)
+		assert.True(t, b, s)
+	}
+
+	for _, s := range []string{"False", "false", "FALSE", "0", "False\r\n"} {
+		b, err := parsePowershellBool(s)
+		require.NoError(t, err, s)
+		assert.False(t, b, s)
+	}
+
+	for _, s := range []string{"", "Wahr", "yes", "t", "2"} {
+		_, err := parsePowershellBool(s)
+		require.Error(t, err, s)
+	}
+}
+
+// processTreeFixture is the output of GetProcessesCommand for the process tree
+// 100 -> (200 -> 400 -> 500, 300), and the unrelated process 999
+const processTreeFixture = `[
+    {"ProcessId": 100, "ParentProcessId": 4, "CreationDate": "2024-01-01T00:00:00.0000000Z"},
+    {"ProcessId": 200, "ParentProcessId": 100, "CreationDate": "2024-01-01T00:00:01.0000000Z"},
+    {"ProcessId": 300, "ParentProcessId": 100, "CreationDate": "2024-01-01T00:00:02.0000000Z"},
+    {"ProcessId": 400, "ParentProcessId": 200, "CreationDate": "2024-01-01T00:00:03.0000000Z"},
+    {"ProcessId": 500, "ParentProcessId": 400, "CreationDate": "2024-01-01T00:00:04.0000000Z"},
+    {"ProcessId": 999, "ParentProcessId": 4, "CreationDate": "2024-01-01T00:00:05.0000000Z"}
+]`
+
+func TestKillProcessTree(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, GetProcessesCommand+" | ConvertTo-Json -Depth 10", cmd)
+		return processTreeFixture, nil
+	})
+
+	require.NoError(t, KillProcessTree(mockExecClient, 100))
+	assert.Equal(t, []string{
+		"taskkill /PID 500 /F",
+		"taskkill /PID 400 /F",
+		"taskkill /PID 200 /F",
+		"taskkill /PID 300 /F",
+		"taskkill /PID 100 /F",
+	}, mockExecClient.RecordedCommands())
+}
+
+func TestKillProcessTreeReusedParentID(t *testing.T) {
+	// 500 was started by 100, and 100 has the id of its exited parent reused by 500
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `[
+    {"ProcessId": 100, "ParentProcessId": 500, "CreationDate": "2024-01-01T00:00:00.0000000Z"},
+    {"ProcessId": 500, "ParentProcessId": 100, "CreationDate": "2024-01-01T00:00:01.0000000Z"}
+]`, nil
+	})
+
+	require.NoError(t, KillProcessTree(mockExecClient, 100))
+	assert.Equal(t, []string{"taskkill /PID 500 /F", "taskkill /PID 100 /F"}, mockExecClient.RecordedCommands())
+
+	// killing 500 leaves 100 alone, it is not a child of the later 500
+	mockExecClient = NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `[
+    {"ProcessId": 100, "ParentProcessId": 500, "CreationDate": "2024-01-01T00:00:00.0000000Z"},
+    {"ProcessId": 500, "ParentProcessId": 100, "CreationDate": "2024-01-01T00:00:01.0000000Z"}
+]`, nil
+	})
+
+	require.NoError(t, KillProcessTree(mockExecClient, 500))
+	assert.Equal(t, []string{"taskkill /PID 500 /F"}, mockExecClient.RecordedCommands())
+}
+
+func TestKillProcessTreeStaleParentID(t *testing.T) {
+	// 300 was started by an exited process whose id was reused by the later 200, and
+	// 400 has no known creation time so is assumed to be a child of 200
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `[
+    {"ProcessId": 300, "ParentProcessId": 200, "CreationDate": "2024-01-01T00:00:00.0000000Z"},
+    {"ProcessId": 200, "ParentProcessId": 4, "CreationDate": "2024-01-01T00:00:01.0000000Z"},
+    {"ProcessId": 400, "ParentProcessId": 200, "CreationDate": null}
+]`, nil
+	})
+
+	require.NoError(t, KillProcessTree(mockExecClient, 200))
+	assert.Equal(t, []string{"taskkill /PID 400 /F", "taskkill /PID 200 /F"}, mockExecClient.RecordedCommands())
+}
+
+func TestKillProcessTreeError(t *testing.T) {
+	require.ErrorIs(t, KillProcessTree(NewMockExecClient(true), 100), ErrMockExec)
+
+	// killing stops at the first failure
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return processTreeFixture, nil
+	})
+	mockExecClient.SetCommandResponder(func(string) (string, error) {
+		return "", ErrMockExec
+	})
+	require.ErrorIs(t, KillProcessTree(mockExecClient, 200), ErrMockExec)
+	assert.Equal(t, []string{"taskkill /PID 500 /F"}, mockExecClient.RecordedCommands())
+}
+
+// fakeMoveFileEx makes file moves return the given errors in turn for the duration of the test,
+// and returns the number of moves done
+func fakeMoveFileEx(t *testing.T, errs ...error) *int {
+	moves := 0
+	moveFileEx = func(*uint16, *uint16, uint32) error {
+		err := errs[moves]
+		moves++
+		return err
+	}
+	replaceFileRetryDelay = time.Millisecond
+	t.Cleanup(func() {
+		moveFileEx = windows.MoveFileEx
+		replaceFileRetryDelay = 100 * time.Millisecond
+	})
+
+	return &moves
+}
+
+func TestReplaceFileSharingViolation(t *testing.T) {
+	moves := fakeMoveFileEx(t, windows.ERROR_SHARING_VIOLATION, nil)
+	mockExecClient := NewMockExecClient(false)
+
+	require.NoError(t, replaceFile(mockExecClient, `C:\k\azure-vnet.exe.new`, `C:\k\azure-vnet.exe`))
+	assert.Equal(t, 2, *moves)
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestReplaceFileError(t *testing.T) {
+	errs := make([]error, replaceFileAttempts)
+	for i := range errs {
+		errs[i] = windows.ERROR_SHARING_VIOLATION
+	}
+	moves := fakeMoveFileEx(t, errs...)
+	mockExecClient := NewMockExecClient(false)
+
+	err := replaceFile(mockExecClient, `C:\k\azure-vnet.exe.new`, `C:\k\azure-vnet.exe`)
+	require.ErrorIs(t, err, windows.ERROR_SHARING_VIOLATION)
+	assert.Equal(t, replaceFileAttempts, *moves)
+	assert.Equal(t, []string{
+		`Get-Process | Where-Object { $_.Path -eq 'C:\k\azure-vnet.exe.new' -or ($_.Modules.FileName -contains 'C:\k\azure-vnet.exe.new') } | ` +
+			`ForEach-Object { "$($_.ProcessName) ($($_.Id))" }`,
+		`Get-Process | Where-Object { $_.Path -eq 'C:\k\azure-vnet.exe' -or ($_.Modules.FileName -contains 'C:\k\azure-vnet.exe') } | ` +
+			`ForEach-Object { "$($_.ProcessName) ($($_.Id))" }`,
+	}, mockExecClient.RecordedPowershellCommands())
+
+	// errors other than sharing violations are not retried
+	errNotFound := errors.New("The system cannot find the file specified.")
+	moves = fakeMoveFileEx(t, errNotFound)
+	require.ErrorIs(t, replaceFile(mockExecClient, `C:\k\azure-vnet.exe.new`, `C:\k\azure-vnet.exe`), errNotFound)
+	assert.Equal(t, 1, *moves)
+}
+
+// fakeTickRebootTime makes the tick count derived boot time rebootTime for the duration of the test
+func fakeTickRebootTime(t *testing.T, rebootTime time.Time) {
+	getTickRebootTime = func() (time.Time, error) { return rebootTime, nil }
+	t.Cleanup(func() { getTickRebootTime = GetLastRebootTime })
+}
+
+func TestCompareRebootTimeSources(t *testing.T) {
+	fakeTickRebootTime(t, time.Date(2023, 5, 4, 10, 0, 42, 0, time.UTC))
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, GetWMIBootTimeCommand, cmd)
+		return "2023-05-04T10:00:00.5000000Z\r\n", nil
+	})
+
+	tickTime, wmiTime, drift, err := CompareRebootTimeSources(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 5, 4, 10, 0, 42, 0, time.UTC), tickTime)
+	assert.Equal(t, time.Date(2023, 5, 4, 10, 0, 0, 500000000, time.UTC), wmiTime)
+	assert.InDelta(t, 41.5, drift, 1e-9)
+}
+
+func TestCompareRebootTimeSourcesError(t *testing.T) {
+	fakeTickRebootTime(t, time.Date(2023, 5, 4, 10, 0, 42, 0, time.UTC))
+
+	_, _, _, err := CompareRebootTimeSources(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "5/4/2023 10:00:00 AM", nil
+	})
+	_, _, _, err = CompareRebootTimeSources(mockExecClient)
+	require.Error(t, err)
+}
+
+// userProcess is the logon type, user and command a process was created with by a fake createProcessAsUser
+type userProcess struct {
+	logonType                       LogonType
+	user, domain, password, command string
+	timeout                         time.Duration
+}
+
+// fakeCreateProcessAsUser records the processes created as a user for the duration of the test,
+// which fail with err and stderr if err is not nil
+func fakeCreateProcessAsUser(t *testing.T, err error, stderr string) *[]userProcess {
+	var processes []userProcess
+	createProcessAsUser = func(logonType LogonType, user, domain, password, command string, timeout time.Duration) (string, string, error) {
+		processes = append(processes, userProcess{
+			logonType: logonType, user: user, domain: domain, password: password, command: command, timeout: timeout,
+		})
+		if err != nil {
+			return "", stderr, err
+		}
+		return "hello\r\n", "", nil
+	}
+	t.Cleanup(func() { createProcessAsUser = logonAndCreateProcess })
+
+	return &processes
+}
+
+func TestExecuteCommandAsUser(t *testing.T) {
+	processes := fakeCreateProcessAsUser(t, nil, "")
+	execClient := NewExecClientTimeout(time.Minute)
+
+	out, err := ExecuteCommandAsUser(execClient, `CONTOSO\svc-cni`, "p@ss word", "echo hello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\r\n", out)
+
+	_, err = ExecuteCommandAsUserWithLogonType(execClient, LogonBatch, "svc-cni@contoso.com", "p@ss word", "echo hello")
+	require.NoError(t, err)
+	_, err = ExecuteCommandAsUser(execClient, "svc-cni", "p@ss word", "echo hello")
+	require.NoError(t, err)
+
+	assert.Equal(t, []userProcess{
+		{logonType: LogonService, user: "svc-cni", domain: "CONTOSO", password: "p@ss word", command: "echo hello", timeout: time.Minute},
+		{logonType: LogonBatch, user: "svc-cni@contoso.com", domain: "", password: "p@ss word", command: "echo hello", timeout: time.Minute},
+		{logonType: LogonService, user: "svc-cni", domain: ".", password: "p@ss word", command: "echo hello", timeout: time.Minute},
+	}, *processes)
+
+	_, err = ExecuteCommandAsUser(execClient, "", "p@ss word", "echo hello")
+	require.Error(t, err)
+	assert.Len(t, *processes, 3)
+}
+
+func TestExecuteCommandAsUserHooks(t *testing.T) {
+	processes := fakeCreateProcessAsUser(t, nil, "")
+
+	var before, after []string
+	execClient := NewExecClient(WithHooks(ExecHooks{
+		BeforeExec: func(command string) error {
+			before = append(before, command)
+			if strings.Contains(command, "fail") {
+				return ErrMockExec
+			}
+			return nil
+		},
+		AfterExec: func(command, output string, err error) {
+			after = append(after, command)
+		},
+	}))
+
+	_, err := ExecuteCommandAsUser(execClient, "svc-cni", "hunter2", "net use \\\\share hunter2")
+	require.NoError(t, err)
+	_, err = ExecuteCommandAsUser(execClient, "svc-cni", "hunter2", "fail")
+	require.ErrorIs(t, err, ErrMockExec)
+
+	// the hooks never see the password, and a failing BeforeExec skips the command
+	assert.Equal(t, []string{"net use \\\\share ***", "fail"}, before)
+	assert.Equal(t, []string{"net use \\\\share ***"}, after)
+	assert.Len(t, *processes, 1)
+}
+
+func TestExecuteCommandAsUserMock(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(func(string) (string, error) {
+		return "hello", nil
+	})
+
+	out, err := ExecuteCommandAsUser(mockExecClient, "svc-cni", "hunter2", "echo hello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", out)
+	assert.Equal(t, []string{"echo hello"}, mockExecClient.RecordedCommands())
+}
+
+func TestExecuteCommandAsUserRedactsPassword(t *testing.T) {
+	l := &recordingLogger{}
+	SetCommandLogger(l)
+	defer SetCommandLogger(nil)
+
+	fakeCreateProcessAsUser(t, ErrMockExec, "net use: invalid password hunter2")
+	execClient := NewExecClient(WithLogPrefix("[CNS-Platform]"))
+
+	_, err := ExecuteCommandAsUser(execClient, `CONTOSO\svc-cni`, "hunter2", "net use \\\\share /user:svc-cni hunter2")
+	require.ErrorIs(t, err, ErrMockExec)
+	assert.NotContains(t, err.Error(), "hunter2")
+
+	_, err = ExecuteCommandAsUser(execClient, `CONTOSO\svc-cni`, "hunter2", "New-LocalUser -Name azure -Password 'secret'")
+	require.ErrorIs(t, err, ErrMockExec)
+	assert.NotContains(t, err.Error(), "secret")
+
+	assert.Equal(t, []string{
+		`[CNS-Platform] net use \\share /user:svc-cni *** (as CONTOSO\svc-cni)`,
+		`[CNS-Platform] New-LocalUser -Name azure -Password *** (as CONTOSO\svc-cni)`,
+	}, l.logs)
+}
+
+func TestRedactPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		password string
+		want     string
+	}{
+		{"argument", "net use \\\\share /user:svc-cni hunter2", "hunter2", "net use \\\\share /user:svc-cni ***"},
+		{"quoted", "net use \\\\share 'hunter2'", "hunter2", "net use \\\\share '***'"},
+		{"argument value", "login /password:hunter2", "hunter2", "login /password:***"},
+		{"repeated", "hunter2 hunter2", "hunter2", "*** ***"},
+		{"part of a longer token", "echo hunter22 xhunter2", "hunter2", "echo hunter22 xhunter2"},
+		{"short password", "echo pass", "pass", "echo pass"},
+		{"no password", "echo hello", "", "echo hello"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactPassword(tt.s, tt.password))
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Size of the IPv4 and ICMP headers added to the payload of a ping
+	pingHeaderSize = 28
+
+	// Bounds of the path MTU discovered by GetPathMTU: the minimum MTU of IPv4 links and the jumbo frame MTU
+	minPathMTU = 576
+	maxPathMTU = 9000
+
+	// maxPathMTUProbes bounds the number of pings of a path MTU discovery, enough for a binary search
+	// between minPathMTU and maxPathMTU
+	maxPathMTUProbes = 16
+
+	// Timeout of a single ping, in milliseconds
+	pathMTUProbeTimeoutMs = 1000
+)
+
+// hostnameRegex matches valid host names
+var hostnameRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+
+// GetPathMTU discovers the MTU of the path to the IPv4 destination with a binary search of do-not-fragment pings.
+// The destination is an IPv4 address or a host name pinged over IPv4, as the sizes account for IPv4 headers.
+// A ping without reply is considered too large, as a router dropping it may not report that it needs fragmentation.
+// Returns an error if even a ping of minPathMTU gets no reply, or when ctx is done.
+func GetPathMTU(ctx context.Context, execClient ExecClient, destination string) (int, error) {
+	if ip := net.ParseIP(destination); ip != nil && ip.To4() == nil {
+		return 0, fmt.Errorf("destination %s is not an IPv4 address", destination)
+	} else if ip == nil && !hostnameRegex.MatchString(destination) {
+		return 0, fmt.Errorf("invalid destination %q", destination)
+	}
+
+	// fits is whether a ping of the given MTU gets a reply
+	probes := 0
+	fits := func(mtu int) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, fmt.Errorf("path MTU discovery to %s interrupted: %w", destination, err)
+		}
+
+		if probes++; probes > maxPathMTUProbes {
+			return false, fmt.Errorf("path MTU discovery to %s exceeded %d probes", destination, maxPathMTUProbes)
+		}
+
+		// ping exits with an error when it gets no reply, its output tells why
+		cmd := fmt.Sprintf("ping -4 -f -l %d -n 1 -w %d %s & exit /b 0", mtu-pingHeaderSize, pathMTUProbeTimeoutMs, destination)
+		out, err := execClient.ExecuteCommand(cmd)
+		if err != nil {
+			return false, fmt.Errorf("failed to ping %s: %w", destination, err)
+		}
+
+		return strings.Contains(out, "TTL="), nil
+	}
+
+	ok, err := fits(minPathMTU)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		return 0, fmt.Errorf("no reply from %s to a ping of %d bytes", destination, minPathMTU)
+	}
+
+	// the path MTU is in [low, high]
+	low, high := minPathMTU, maxPathMTU
+	for low < high {
+		mtu := (low + high + 1) / 2
+		if ok, err = fits(mtu); err != nil {
+			return 0, err
+		}
+
+		if ok {
+			low = mtu
+		} else {
+			high = mtu - 1
+		}
+	}
+
+	return low, nil
+}
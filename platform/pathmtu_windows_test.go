@@ -0,0 +1,93 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pingSizeRegex = regexp.MustCompile(`^ping -4 -f -l (\d+) -n 1 -w 1000 10\.224\.0\.4 & exit /b 0$`)
+
+// pingResponder answers do-not-fragment pings to 10.224.0.4 over a path of the given MTU.
+// Larger pings are reported as needing fragmentation up to blackHoleAbove, and dropped silently above it.
+func pingResponder(t *testing.T, pathMTU, blackHoleAbove int) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		match := pingSizeRegex.FindStringSubmatch(cmd)
+		require.NotNil(t, match, cmd)
+		size, _ := strconv.Atoi(match[1])
+
+		switch {
+		case size+pingHeaderSize <= pathMTU:
+			return fmt.Sprintf("\r\nPinging 10.224.0.4 with %d bytes of data:\r\nReply from 10.224.0.4: bytes=%d time<1ms TTL=128\r\n", size, size), nil
+		case size+pingHeaderSize <= blackHoleAbove:
+			return fmt.Sprintf("\r\nPinging 10.224.0.4 with %d bytes of data:\r\nPacket needs to be fragmented but DF set.\r\n", size), nil
+		}
+		return fmt.Sprintf("\r\nPinging 10.224.0.4 with %d bytes of data:\r\nRequest timed out.\r\n", size), nil
+	}
+}
+
+func TestGetPathMTU(t *testing.T) {
+	tests := []struct {
+		name           string
+		pathMTU        int
+		blackHoleAbove int
+	}{
+		{"vxlan overlay", 1450, maxPathMTU},
+		{"ethernet", 1500, maxPathMTU},
+		{"jumbo frames", maxPathMTU, maxPathMTU},
+		{"minimum", minPathMTU, maxPathMTU},
+		{"black hole", 1400, 1500},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetCommandResponder(pingResponder(t, tt.pathMTU, tt.blackHoleAbove))
+
+			mtu, err := GetPathMTU(context.Background(), mockExecClient, "10.224.0.4")
+			require.NoError(t, err)
+			assert.Equal(t, tt.pathMTU, mtu)
+			assert.LessOrEqual(t, len(mockExecClient.RecordedCommands()), maxPathMTUProbes)
+		})
+	}
+}
+
+func TestGetPathMTUError(t *testing.T) {
+	// unreachable
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(pingResponder(t, 0, 0))
+	_, err := GetPathMTU(context.Background(), mockExecClient, "10.224.0.4")
+	require.Error(t, err)
+	assert.Len(t, mockExecClient.RecordedCommands(), 1)
+
+	// interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	mockExecClient = NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(func(cmd string) (string, error) {
+		cancel()
+		return pingResponder(t, 1500, maxPathMTU)(cmd)
+	})
+	_, err = GetPathMTU(ctx, mockExecClient, "10.224.0.4")
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = GetPathMTU(context.Background(), NewMockExecClient(true), "10.224.0.4")
+	require.ErrorIs(t, err, ErrMockExec)
+
+	_, err = GetPathMTU(context.Background(), NewMockExecClient(false), "10.224.0.4 & shutdown /s")
+	require.Error(t, err)
+
+	// the ping sizes account for IPv4 headers
+	mockExecClient = NewMockExecClient(false)
+	_, err = GetPathMTU(context.Background(), mockExecClient, "fd00::4")
+	require.Error(t, err)
+	assert.Empty(t, mockExecClient.RecordedCommands())
+}
@@ -0,0 +1,51 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import "time"
+
+// Platform is the set of operations on the host used by CNS and CNI, so that their callers can be tested
+// with a fake implementation instead of the host
+type Platform interface {
+	// GetLastRebootTime returns the time the host last booted
+	GetLastRebootTime() (time.Time, error)
+	// ClearNetworkConfiguration clears the network configuration persisted by CNI, returning whether the platform has one
+	ClearNetworkConfiguration() (bool, error)
+	// SetSdnRemoteArpMacAddress sets the SDNRemoteArpMacAddress registry value needed for multitenancy
+	SetSdnRemoteArpMacAddress() error
+	// KillProcessByName kills the processes with the given name
+	KillProcessByName(processName string) error
+	// ReplaceFile atomically replaces destination with source
+	ReplaceFile(source, destination string) error
+}
+
+// osPlatform is the Platform of the host, running its commands with execClient
+type osPlatform struct {
+	execClient ExecClient
+}
+
+// NewPlatform returns the Platform of the host, running its commands with execClient
+func NewPlatform(execClient ExecClient) Platform {
+	return &osPlatform{execClient: execClient}
+}
+
+func (p *osPlatform) GetLastRebootTime() (time.Time, error) {
+	return GetLastRebootTime()
+}
+
+func (p *osPlatform) ClearNetworkConfiguration() (bool, error) {
+	return ClearNetworkConfiguration()
+}
+
+func (p *osPlatform) SetSdnRemoteArpMacAddress() error {
+	return setSdnRemoteArpMacAddress(p.execClient)
+}
+
+func (p *osPlatform) KillProcessByName(processName string) error {
+	return KillProcessByName(processName)
+}
+
+func (p *osPlatform) ReplaceFile(source, destination string) error {
+	return ReplaceFile(source, destination)
+}
@@ -0,0 +1,76 @@
+package platform
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePlatform is a Platform recording the operations run on it
+type fakePlatform struct {
+	rebootTime time.Time
+	clearErr   error
+	calls      []string
+}
+
+func (p *fakePlatform) GetLastRebootTime() (time.Time, error) {
+	p.calls = append(p.calls, "GetLastRebootTime")
+	return p.rebootTime, nil
+}
+
+func (p *fakePlatform) ClearNetworkConfiguration() (bool, error) {
+	p.calls = append(p.calls, "ClearNetworkConfiguration")
+	return true, p.clearErr
+}
+
+func (p *fakePlatform) SetSdnRemoteArpMacAddress() error {
+	p.calls = append(p.calls, "SetSdnRemoteArpMacAddress")
+	return nil
+}
+
+func (p *fakePlatform) KillProcessByName(processName string) error {
+	p.calls = append(p.calls, "KillProcessByName "+processName)
+	return nil
+}
+
+func (p *fakePlatform) ReplaceFile(source, destination string) error {
+	p.calls = append(p.calls, "ReplaceFile "+source+" "+destination)
+	return nil
+}
+
+// clearConfigurationIfRebooted is a caller of the platform, clearing the network configuration
+// if the host rebooted since the configuration was saved
+func clearConfigurationIfRebooted(p Platform, savedAt time.Time) error {
+	rebootTime, err := p.GetLastRebootTime()
+	if err != nil {
+		return err
+	}
+
+	if rebootTime.After(savedAt) {
+		if _, err = p.ClearNetworkConfiguration(); err != nil {
+			return err
+		}
+	}
+
+	return p.SetSdnRemoteArpMacAddress()
+}
+
+func TestFakePlatform(t *testing.T) {
+	savedAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	p := &fakePlatform{rebootTime: savedAt.Add(time.Hour)}
+	require.NoError(t, clearConfigurationIfRebooted(p, savedAt))
+	assert.Equal(t, []string{"GetLastRebootTime", "ClearNetworkConfiguration", "SetSdnRemoteArpMacAddress"}, p.calls)
+
+	p = &fakePlatform{rebootTime: savedAt.Add(-time.Hour)}
+	require.NoError(t, clearConfigurationIfRebooted(p, savedAt))
+	assert.Equal(t, []string{"GetLastRebootTime", "SetSdnRemoteArpMacAddress"}, p.calls)
+
+	errClear := errors.New("access denied")
+	p = &fakePlatform{rebootTime: savedAt.Add(time.Hour), clearErr: errClear}
+	require.ErrorIs(t, clearConfigurationIfRebooted(p, savedAt), errClear)
+	assert.Equal(t, []string{"GetLastRebootTime", "ClearNetworkConfiguration"}, p.calls)
+}
@@ -0,0 +1,34 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// WaitForPort dials the TCP port of the host every pollInterval until it connects, e.g. to wait for a service
+// to listen on it. Returns the context error if it is done before the port accepts connections.
+func WaitForPort(ctx context.Context, host string, port int, pollInterval time.Duration) error {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			return conn.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("port %s not listening: %w (last error: %v)", address, ctx.Err(), err)
+		case <-ticker.C:
+		}
+	}
+}
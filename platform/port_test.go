@@ -0,0 +1,52 @@
+package platform
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// freePort returns a local TCP port nothing listens on
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+	return port
+}
+
+func TestWaitForPort(t *testing.T) {
+	port := freePort(t)
+
+	// the service listens after a delay
+	listening := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		if err != nil {
+			close(listening)
+			return
+		}
+		listening <- l
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, WaitForPort(ctx, "127.0.0.1", port, 10*time.Millisecond))
+
+	l, ok := <-listening
+	require.True(t, ok)
+	l.Close()
+}
+
+func TestWaitForPortTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := WaitForPort(ctx, "127.0.0.1", freePort(t), 10*time.Millisecond)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
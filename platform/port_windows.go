@@ -0,0 +1,80 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Command to get the TCP ports the host listens on and the ids of their owning processes
+	GetListeningTCPConnectionsCommand = "Get-NetTCPConnection -State Listen | Select-Object LocalAddress, LocalPort, OwningProcess"
+
+	// Command to get the ids and names of the processes of the host
+	GetProcessNamesCommand = "Get-Process | Select-Object Id, ProcessName"
+
+	// maxListeningPorts bounds the number of listening ports returned by GetListeningPorts
+	maxListeningPorts = 4096
+)
+
+// PortOwner is a TCP port the host listens on and the process owning it
+type PortOwner struct {
+	LocalAddress string
+	Port         int
+	PID          int
+	ProcessName  string
+}
+
+// GetListeningPorts returns the TCP ports the host listens on with their owning process, sorted by port then address.
+// At most maxListeningPorts are returned.
+func GetListeningPorts(execClient ExecClient) ([]PortOwner, error) {
+	connections, err := ExecutePowershellJSON[[]struct {
+		LocalAddress  string
+		LocalPort     int
+		OwningProcess int
+	}](execClient, GetListeningTCPConnectionsCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get listening TCP connections: %w", err)
+	}
+
+	processes, err := ExecutePowershellJSON[[]struct {
+		ID          int `json:"Id"`
+		ProcessName string
+	}](execClient, GetProcessNamesCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get processes: %w", err)
+	}
+
+	processNames := make(map[int]string, len(processes))
+	for _, process := range processes {
+		processNames[process.ID] = process.ProcessName
+	}
+
+	owners := make([]PortOwner, 0, len(connections))
+	for _, connection := range connections {
+		owners = append(owners, PortOwner{
+			LocalAddress: connection.LocalAddress,
+			Port:         connection.LocalPort,
+			PID:          connection.OwningProcess,
+			ProcessName:  processNames[connection.OwningProcess],
+		})
+	}
+
+	sort.Slice(owners, func(i, j int) bool {
+		if owners[i].Port != owners[j].Port {
+			return owners[i].Port < owners[j].Port
+		}
+		return owners[i].LocalAddress < owners[j].LocalAddress
+	})
+
+	if len(owners) > maxListeningPorts {
+		log.Printf("Host listens on %d ports, returning the first %d", len(owners), maxListeningPorts)
+		owners = owners[:maxListeningPorts]
+	}
+
+	return owners, nil
+}
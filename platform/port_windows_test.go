@@ -0,0 +1,73 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	listeningTCPConnectionsFixture = `[
+  {"LocalAddress": "0.0.0.0", "LocalPort": 10090, "OwningProcess": 4120},
+  {"LocalAddress": "::", "LocalPort": 135, "OwningProcess": 960},
+  {"LocalAddress": "0.0.0.0", "LocalPort": 135, "OwningProcess": 960},
+  {"LocalAddress": "127.0.0.1", "LocalPort": 10256, "OwningProcess": 7000}
+]`
+	processNamesFixture = `[
+  {"Id": 960, "ProcessName": "svchost"},
+  {"Id": 4120, "ProcessName": "azure-cns"},
+  {"Id": 5312, "ProcessName": "kubelet"}
+]`
+)
+
+// listeningPortsResponder answers the powershell commands of GetListeningPorts with the given outputs
+func listeningPortsResponder(connections, processes string) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		switch cmd {
+		case GetListeningTCPConnectionsCommand + " | ConvertTo-Json -Depth 10":
+			return connections, nil
+		case GetProcessNamesCommand + " | ConvertTo-Json -Depth 10":
+			return processes, nil
+		}
+		return "", fmt.Errorf("unexpected command %q", cmd)
+	}
+}
+
+func TestGetListeningPorts(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(listeningPortsResponder(listeningTCPConnectionsFixture, processNamesFixture))
+
+	owners, err := GetListeningPorts(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, []PortOwner{
+		{LocalAddress: "0.0.0.0", Port: 135, PID: 960, ProcessName: "svchost"},
+		{LocalAddress: "::", Port: 135, PID: 960, ProcessName: "svchost"},
+		{LocalAddress: "0.0.0.0", Port: 10090, PID: 4120, ProcessName: "azure-cns"},
+		// the owning process exited since the connections were listed
+		{LocalAddress: "127.0.0.1", Port: 10256, PID: 7000},
+	}, owners)
+}
+
+func TestGetListeningPortsNone(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(listeningPortsResponder("", processNamesFixture))
+
+	owners, err := GetListeningPorts(mockExecClient)
+	require.NoError(t, err)
+	assert.Empty(t, owners)
+}
+
+func TestGetListeningPortsError(t *testing.T) {
+	_, err := GetListeningPorts(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(listeningPortsResponder(listeningTCPConnectionsFixture, "{"))
+	_, err = GetListeningPorts(mockExecClient)
+	require.Error(t, err)
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+)
+
+// DisableAdapterPowerManagement prevents the host from turning the adapter off to save power,
+// which drops the connectivity of idle nodes.
+// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if it has no power management.
+func DisableAdapterPowerManagement(execClient ExecClient, adapterName string) error {
+	na := &networkAdapter{execClient: execClient}
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("Set-NetAdapterPowerManagement -Name '%s' -AllowComputerToTurnOffDevice Disabled", escapePowershellString(adapterName))
+	_, err := execClient.ExecutePowershellCommand(cmd)
+	if isNoCimObjectsFoundError(err) {
+		return fmt.Errorf("power management of %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to disable power management of %s: %w", adapterName, err)
+	}
+
+	return nil
+}
+
+// wakeOnMagicPacketUnsupported is the WakeOnMagicPacket setting of adapters that cannot wake the host
+const wakeOnMagicPacketUnsupported = "Unsupported"
+
+// GetWakeOnLAN returns whether the adapter wakes the host on a magic packet.
+// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if it cannot wake the host.
+func GetWakeOnLAN(execClient ExecClient, adapterName string) (bool, error) {
+	na := &networkAdapter{execClient: execClient}
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return false, err
+	}
+
+	cmd := fmt.Sprintf("Get-NetAdapterPowerManagement -Name '%s' -ErrorAction Stop | Select-Object -ExpandProperty WakeOnMagicPacket", escapePowershellString(adapterName))
+	out, err := execClient.ExecutePowershellCommand(cmd)
+	if isNoCimObjectsFoundError(err) {
+		return false, fmt.Errorf("wake on lan of %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to get wake on lan of %s: %w", adapterName, err)
+	}
+
+	value := strings.TrimSpace(out)
+	if strings.EqualFold(value, wakeOnMagicPacketUnsupported) {
+		return false, fmt.Errorf("wake on lan of %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	return strings.EqualFold(value, "Enabled"), nil
+}
+
+// SetWakeOnLAN enables or disables waking the host on a magic packet sent to the adapter,
+// which wakes up nodes unexpectedly in some environments.
+// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if it has no power management.
+func SetWakeOnLAN(execClient ExecClient, adapterName string, enabled bool) error {
+	na := &networkAdapter{execClient: execClient}
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return err
+	}
+
+	value := "Disabled"
+	if enabled {
+		value = "Enabled"
+	}
+
+	cmd := fmt.Sprintf("Set-NetAdapterPowerManagement -Name '%s' -WakeOnMagicPacket %s", escapePowershellString(adapterName), value)
+	_, err := execClient.ExecutePowershellCommand(cmd)
+	if isNoCimObjectsFoundError(err) {
+		return fmt.Errorf("wake on lan of %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to set wake on lan of %s to %s: %w", adapterName, value, err)
+	}
+
+	return nil
+}
+
+const (
+	// Command to get the active power plan of the host
+	GetActivePowerPlanCommand = "powercfg /getactivescheme"
+
+	// HighPerformancePowerPlanGUID is the GUID of the built-in High performance power plan
+	HighPerformancePowerPlanGUID = "8c5e7fda-e8bf-4a96-9a85-a6e23a8c635c"
+)
+
+// ErrPowerPlanNotParsed is returned when the output of powercfg has no power plan
+var ErrPowerPlanNotParsed = errors.New("no power plan in powercfg output")
+
+// powerPlanRegex matches the power plan printed by powercfg, e.g.
+// Power Scheme GUID: 381b4222-f694-41f0-9685-ff5bb260df2e  (Balanced)
+var powerPlanRegex = regexp.MustCompile(`([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})\s+\((.*)\)`)
+
+// PowerPlan is a power plan of the host
+type PowerPlan struct {
+	GUID string
+	Name string
+}
+
+// GetActivePowerPlan returns the active power plan of the host.
+// The Balanced plan throttles the CPU on some SKUs, which hurts network throughput.
+func GetActivePowerPlan(execClient ExecClient) (PowerPlan, error) {
+	out, err := execClient.ExecuteCommand(GetActivePowerPlanCommand)
+	if err != nil {
+		return PowerPlan{}, fmt.Errorf("failed to get active power plan: %w", err)
+	}
+
+	match := powerPlanRegex.FindStringSubmatch(out)
+	if match == nil {
+		return PowerPlan{}, fmt.Errorf("failed to parse active power plan %q: %w", out, ErrPowerPlanNotParsed)
+	}
+
+	return PowerPlan{GUID: strings.ToLower(match[1]), Name: match[2]}, nil
+}
+
+// SetActivePowerPlanHighPerformance makes the High performance power plan the active one
+func SetActivePowerPlanHighPerformance(execClient ExecClient) error {
+	if _, err := execClient.ExecuteCommand("powercfg /setactive " + HighPerformancePowerPlanGUID); err != nil {
+		return fmt.Errorf("failed to set active power plan to high performance: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisableAdapterPowerManagement(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	require.NoError(t, DisableAdapterPowerManagement(mockExecClient, "Ethernet 2"))
+	assert.Equal(t, []string{
+		GetAdapterNamesCommand,
+		"Set-NetAdapterPowerManagement -Name 'Ethernet 2' -AllowComputerToTurnOffDevice Disabled",
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestDisableAdapterPowerManagementError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	require.ErrorIs(t, DisableAdapterPowerManagement(mockExecClient, "Ethernet 3"), adapter.ErrAdapterNotFound)
+	assert.Equal(t, []string{GetAdapterNamesCommand}, mockExecClient.RecordedPowershellCommands())
+
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == GetAdapterNamesCommand {
+			return adapterNamesResponder(cmd)
+		}
+		return "", errors.New("Set-NetAdapterPowerManagement : No matching MSFT_NetAdapterPowerManagementSettingData objects found")
+	})
+	require.ErrorIs(t, DisableAdapterPowerManagement(mockExecClient, "Ethernet 2"), adapter.ErrFeatureUnsupported)
+
+	require.ErrorIs(t, DisableAdapterPowerManagement(NewMockExecClient(true), "Ethernet 2"), ErrMockExec)
+}
+
+// wakeOnMagicPacketResponder answers the adapter names and the given WakeOnMagicPacket setting
+func wakeOnMagicPacketResponder(value string) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		if cmd == GetAdapterNamesCommand {
+			return adapterNamesResponder(cmd)
+		}
+		return value + "\r\n", nil
+	}
+}
+
+func TestGetWakeOnLAN(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(wakeOnMagicPacketResponder("Enabled"))
+
+	enabled, err := GetWakeOnLAN(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, []string{
+		GetAdapterNamesCommand,
+		"Get-NetAdapterPowerManagement -Name 'Ethernet 2' -ErrorAction Stop | Select-Object -ExpandProperty WakeOnMagicPacket",
+	}, mockExecClient.RecordedPowershellCommands())
+
+	mockExecClient.SetPowershellCommandResponder(wakeOnMagicPacketResponder("Disabled"))
+	enabled, err = GetWakeOnLAN(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestGetWakeOnLANError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	_, err := GetWakeOnLAN(mockExecClient, "Ethernet 3")
+	require.ErrorIs(t, err, adapter.ErrAdapterNotFound)
+
+	mockExecClient.SetPowershellCommandResponder(wakeOnMagicPacketResponder("Unsupported"))
+	_, err = GetWakeOnLAN(mockExecClient, "Ethernet 2")
+	require.ErrorIs(t, err, adapter.ErrFeatureUnsupported)
+
+	_, err = GetWakeOnLAN(NewMockExecClient(true), "Ethernet 2")
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestSetWakeOnLAN(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	require.NoError(t, SetWakeOnLAN(mockExecClient, "Ethernet 2", false))
+	require.NoError(t, SetWakeOnLAN(mockExecClient, "Ethernet", true))
+	assert.Equal(t, []string{
+		GetAdapterNamesCommand,
+		"Set-NetAdapterPowerManagement -Name 'Ethernet 2' -WakeOnMagicPacket Disabled",
+		GetAdapterNamesCommand,
+		"Set-NetAdapterPowerManagement -Name 'Ethernet' -WakeOnMagicPacket Enabled",
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestSetWakeOnLANError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	require.ErrorIs(t, SetWakeOnLAN(mockExecClient, "Ethernet 3", false), adapter.ErrAdapterNotFound)
+	assert.Equal(t, []string{GetAdapterNamesCommand}, mockExecClient.RecordedPowershellCommands())
+
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == GetAdapterNamesCommand {
+			return adapterNamesResponder(cmd)
+		}
+		return "", errors.New("Set-NetAdapterPowerManagement : No matching MSFT_NetAdapterPowerManagementSettingData objects found")
+	})
+	require.ErrorIs(t, SetWakeOnLAN(mockExecClient, "Ethernet 2", false), adapter.ErrFeatureUnsupported)
+
+	require.ErrorIs(t, SetWakeOnLAN(NewMockExecClient(true), "Ethernet 2", false), ErrMockExec)
+}
+
+func TestGetActivePowerPlan(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, GetActivePowerPlanCommand, cmd)
+		return "Power Scheme GUID: 381B4222-F694-41F0-9685-FF5BB260DF2E  (Balanced)\r\n", nil
+	})
+
+	plan, err := GetActivePowerPlan(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, PowerPlan{GUID: "381b4222-f694-41f0-9685-ff5bb260df2e", Name: "Balanced"}, plan)
+
+	mockExecClient.SetCommandResponder(func(string) (string, error) {
+		return "Power Scheme GUID: 8c5e7fda-e8bf-4a96-9a85-a6e23a8c635c  (High performance)", nil
+	})
+	plan, err = GetActivePowerPlan(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, PowerPlan{GUID: HighPerformancePowerPlanGUID, Name: "High performance"}, plan)
+}
+
+func TestGetActivePowerPlanError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(func(string) (string, error) {
+		return "Unable to perform operation. An unexpected error (0x65b) has occurred", nil
+	})
+	_, err := GetActivePowerPlan(mockExecClient)
+	require.ErrorIs(t, err, ErrPowerPlanNotParsed)
+
+	_, err = GetActivePowerPlan(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestSetActivePowerPlanHighPerformance(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	require.NoError(t, SetActivePowerPlanHighPerformance(mockExecClient))
+	assert.Equal(t, []string{"powercfg /setactive 8c5e7fda-e8bf-4a96-9a85-a6e23a8c635c"}, mockExecClient.RecordedCommands())
+
+	require.ErrorIs(t, SetActivePowerPlanHighPerformance(NewMockExecClient(true)), ErrMockExec)
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// processExitPollInterval is the interval between checks of whether a process exited
+const processExitPollInterval = 100 * time.Millisecond
+
+// processNameRegex matches the valid image names of processes, which are interpolated into cmd commands
+var processNameRegex = regexp.MustCompile(`^[\w.-]+$`)
+
+// RestartProcessesError reports, by process name, the processes RestartProcessesInOrder failed to restart
+type RestartProcessesError struct {
+	Errors map[string]error
+}
+
+func (e *RestartProcessesError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", name, e.Errors[name]))
+	}
+
+	return fmt.Sprintf("failed to restart processes: %s", strings.Join(msgs, "; "))
+}
+
+// Is reports whether the error of any of the processes is target
+func (e *RestartProcessesError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RestartProcessesInOrder stops the processes with the given image names one after the other, asking each to close
+// and killing it if it did not exit within grace, then starts the command of startCommands of its name, if any.
+// A process failing to restart does not prevent the following ones from being restarted, the error is
+// a *RestartProcessesError holding the error of each failed process.
+func RestartProcessesInOrder(ctx context.Context, execClient ExecClient, names []string, grace time.Duration,
+	startCommands map[string]string,
+) error {
+	errs := map[string]error{}
+	for _, name := range names {
+		if err := restartProcess(ctx, execClient, name, grace, startCommands[name]); err != nil {
+			log.Errorf("Failed to restart %s, continuing: %v", name, err)
+			errs[name] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return &RestartProcessesError{Errors: errs}
+	}
+
+	return nil
+}
+
+// restartProcess stops the processes with the given image name, gracefully within grace or else forcefully,
+// then runs startCommand if it is not empty
+func restartProcess(ctx context.Context, execClient ExecClient, name string, grace time.Duration, startCommand string) error {
+	if !processNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid process name %q", name)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// processes without a window can't be asked to close, they are killed once grace is over
+	cmd := fmt.Sprintf("taskkill /IM %s", name)
+	if _, err := ExecuteCommandAllowExitCodes(execClient, cmd, taskkillProcessNotFoundExitCode); err != nil {
+		log.Printf("Failed to ask %s to close: %v", name, err)
+	}
+
+	graceCtx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+
+	err := waitForProcessExit(graceCtx, execClient, name)
+	if err != nil && ctx.Err() != nil {
+		return err
+	}
+
+	if err != nil {
+		log.Printf("%s did not exit within %v, killing it", name, grace)
+		cmd = fmt.Sprintf("taskkill /IM %s /F", name)
+		if _, err = ExecuteCommandAllowExitCodes(execClient, cmd, taskkillProcessNotFoundExitCode); err != nil {
+			return fmt.Errorf("failed to kill %s: %w", name, err)
+		}
+	}
+
+	if startCommand == "" {
+		return nil
+	}
+
+	if _, err = execClient.ExecuteCommand(fmt.Sprintf("start \"\" %s", startCommand)); err != nil {
+		return fmt.Errorf("failed to start %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// waitForProcessExit polls every processExitPollInterval until no process has the given image name.
+// Failing to list the processes does not end the wait. Returns the context error if it is done before.
+func waitForProcessExit(ctx context.Context, execClient ExecClient, name string) error {
+	ticker := time.NewTicker(processExitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		running, err := isProcessRunning(execClient, name)
+		if err != nil {
+			log.Printf("Failed to check whether %s exited, retrying: %v", name, err)
+		} else if !running {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s still running: %w", name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// isProcessRunning returns whether a process has the given image name
+func isProcessRunning(execClient ExecClient, name string) (bool, error) {
+	out, err := execClient.ExecuteCommand(fmt.Sprintf("tasklist /FI \"IMAGENAME eq %s\" /NH /FO CSV", name))
+	if err != nil {
+		return false, fmt.Errorf("failed to list processes named %s: %w", name, err)
+	}
+
+	return strings.Contains(strings.ToLower(out), strings.ToLower(fmt.Sprintf("%q", name))), nil
+}
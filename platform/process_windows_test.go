@@ -0,0 +1,131 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// processResponder answers tasklist and taskkill commands for the given processes, gracefulExit telling whether
+// each process exits when asked to close. Processes killed forcefully always exit.
+func processResponder(gracefulExit map[string]bool) func(string) (string, error) {
+	exited := map[string]bool{}
+	return func(cmd string) (string, error) {
+		for name, graceful := range gracefulExit {
+			switch cmd {
+			case "taskkill /IM " + name:
+				exited[name] = graceful
+				return "", nil
+			case "taskkill /IM " + name + " /F":
+				exited[name] = true
+				return "", nil
+			}
+
+			if strings.HasPrefix(cmd, "tasklist") && strings.Contains(cmd, name) {
+				if exited[name] {
+					return "INFO: No tasks are running which match the specified criteria.", nil
+				}
+				return `"` + name + `","1234","Services","0","10,000 K"`, nil
+			}
+		}
+
+		return "", nil
+	}
+}
+
+func TestRestartProcessesInOrder(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(processResponder(map[string]bool{"first.exe": true, "second.exe": false}))
+
+	err := RestartProcessesInOrder(context.Background(), mockExecClient, []string{"first.exe", "second.exe"},
+		time.Millisecond, map[string]string{"first.exe": `C:\k\first.exe`})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"taskkill /IM first.exe",
+		`tasklist /FI "IMAGENAME eq first.exe" /NH /FO CSV`,
+		`start "" C:\k\first.exe`,
+		"taskkill /IM second.exe",
+		`tasklist /FI "IMAGENAME eq second.exe" /NH /FO CSV`,
+		"taskkill /IM second.exe /F",
+	}, mockExecClient.RecordedCommands())
+}
+
+func TestRestartProcessesInOrderTasklistFailure(t *testing.T) {
+	responder := processResponder(map[string]bool{"first.exe": true})
+	tasklistFailed := false
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(func(cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "tasklist") && !tasklistFailed {
+			tasklistFailed = true
+			return "", ErrMockExec
+		}
+		return responder(cmd)
+	})
+
+	// a transient failure to list the processes doesn't cut the grace period short
+	err := RestartProcessesInOrder(context.Background(), mockExecClient, []string{"first.exe"}, time.Minute, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"taskkill /IM first.exe",
+		`tasklist /FI "IMAGENAME eq first.exe" /NH /FO CSV`,
+		`tasklist /FI "IMAGENAME eq first.exe" /NH /FO CSV`,
+	}, mockExecClient.RecordedCommands())
+}
+
+func TestRestartProcessesInOrderContinuesOnFailure(t *testing.T) {
+	responder := processResponder(map[string]bool{"first.exe": false, "second.exe": true})
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(func(cmd string) (string, error) {
+		if cmd == "taskkill /IM first.exe /F" {
+			return "", ErrMockExec
+		}
+		return responder(cmd)
+	})
+
+	err := RestartProcessesInOrder(context.Background(), mockExecClient, []string{"first.exe", "second.exe"},
+		time.Millisecond, nil)
+	require.ErrorIs(t, err, ErrMockExec)
+
+	var restartErr *RestartProcessesError
+	require.ErrorAs(t, err, &restartErr)
+	assert.Len(t, restartErr.Errors, 1)
+	assert.ErrorIs(t, restartErr.Errors["first.exe"], ErrMockExec)
+	assert.Contains(t, mockExecClient.RecordedCommands(), "taskkill /IM second.exe")
+}
+
+func TestRestartProcessesInOrderContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mockExecClient := NewMockExecClient(false)
+	err := RestartProcessesInOrder(ctx, mockExecClient, []string{"first.exe", "second.exe"}, time.Second, nil)
+	require.ErrorIs(t, err, context.Canceled)
+
+	var restartErr *RestartProcessesError
+	require.ErrorAs(t, err, &restartErr)
+	assert.Len(t, restartErr.Errors, 2)
+	assert.Empty(t, mockExecClient.RecordedCommands())
+}
+
+func TestRestartProcessesInOrderInvalidName(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(processResponder(map[string]bool{"second.exe": true}))
+
+	err := RestartProcessesInOrder(context.Background(), mockExecClient, []string{"first.exe & shutdown /s", "second.exe"},
+		time.Millisecond, nil)
+
+	var restartErr *RestartProcessesError
+	require.ErrorAs(t, err, &restartErr)
+	assert.Len(t, restartErr.Errors, 1)
+	assert.Contains(t, restartErr.Errors, "first.exe & shutdown /s")
+	for _, cmd := range mockExecClient.RecordedCommands() {
+		assert.NotContains(t, cmd, "first.exe")
+	}
+}
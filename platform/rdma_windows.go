@@ -0,0 +1,61 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+)
+
+// GetRDMAEnabled returns whether RDMA is enabled on the adapter.
+// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if it doesn't support RDMA.
+func GetRDMAEnabled(execClient ExecClient, adapterName string) (bool, error) {
+	na := &networkAdapter{execClient: execClient}
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return false, err
+	}
+
+	cmd := fmt.Sprintf("Get-NetAdapterRdma -Name '%s' | Select-Object -ExpandProperty Enabled", escapePowershellString(adapterName))
+	out, err := execClient.ExecutePowershellCommand(cmd)
+	if isNoCimObjectsFoundError(err) {
+		return false, fmt.Errorf("RDMA on %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to get RDMA settings of %s: %w", adapterName, err)
+	}
+
+	enabled, err := parsePowershellBool(out)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse RDMA enabled value %q of %s: %w", out, adapterName, err)
+	}
+
+	return enabled, nil
+}
+
+// SetRDMAEnabled enables or disables RDMA on the adapter, e.g. for storage and HPC workloads on Mellanox adapters.
+// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if it doesn't support RDMA.
+func SetRDMAEnabled(execClient ExecClient, adapterName string, enabled bool) error {
+	na := &networkAdapter{execClient: execClient}
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return err
+	}
+
+	cmdlet := "Disable-NetAdapterRdma"
+	if enabled {
+		cmdlet = "Enable-NetAdapterRdma"
+	}
+
+	_, err := execClient.ExecutePowershellCommand(fmt.Sprintf("%s -Name '%s'", cmdlet, escapePowershellString(adapterName)))
+	if isNoCimObjectsFoundError(err) {
+		return fmt.Errorf("RDMA on %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to set RDMA enabled to %t on %s: %w", enabled, adapterName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errRDMAUnsupported is the error of the RDMA cmdlets on an adapter without RDMA
+var errRDMAUnsupported = errors.New("Get-NetAdapterRdma : No matching MSFT_NetAdapterRdmaSettingData objects found")
+
+func TestGetRDMAEnabled(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == GetAdapterNamesCommand {
+			return adapterNamesResponder(cmd)
+		}
+		assert.Equal(t, "Get-NetAdapterRdma -Name 'Ethernet 2' | Select-Object -ExpandProperty Enabled", cmd)
+		return "True\r\n", nil
+	})
+
+	enabled, err := GetRDMAEnabled(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Len(t, mockExecClient.RecordedPowershellCommands(), 2)
+}
+
+func TestSetRDMAEnabled(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	require.NoError(t, SetRDMAEnabled(mockExecClient, "Ethernet 2", true))
+	require.NoError(t, SetRDMAEnabled(mockExecClient, "Ethernet 2", false))
+	assert.Equal(t, []string{
+		GetAdapterNamesCommand,
+		"Enable-NetAdapterRdma -Name 'Ethernet 2'",
+		GetAdapterNamesCommand,
+		"Disable-NetAdapterRdma -Name 'Ethernet 2'",
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestRDMAUnsupported(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == GetAdapterNamesCommand {
+			return adapterNamesResponder(cmd)
+		}
+		return "", errRDMAUnsupported
+	})
+
+	_, err := GetRDMAEnabled(mockExecClient, "Ethernet 2")
+	require.ErrorIs(t, err, adapter.ErrFeatureUnsupported)
+	require.ErrorIs(t, SetRDMAEnabled(mockExecClient, "Ethernet 2", true), adapter.ErrFeatureUnsupported)
+}
+
+func TestRDMAError(t *testing.T) {
+	_, err := GetRDMAEnabled(NewMockExecClient(true), "Ethernet 2")
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	_, err = GetRDMAEnabled(mockExecClient, "Ethernet 3")
+	require.ErrorIs(t, err, adapter.ErrAdapterNotFound)
+	require.ErrorIs(t, SetRDMAEnabled(mockExecClient, "Ethernet 3", true), adapter.ErrAdapterNotFound)
+	assert.Equal(t, []string{GetAdapterNamesCommand, GetAdapterNamesCommand}, mockExecClient.RecordedPowershellCommands())
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Command to get the status of the HNS service
+	GetHnsServiceStatusCommand = "(Get-Service -Name hns).Status"
+
+	// Command to get the names of the physical adapters which are up
+	GetUpPhysicalAdapterNamesCommand = "Get-NetAdapter -Physical | Where-Object { $_.Status -eq 'Up' } | " +
+		"Select-Object -ExpandProperty Name"
+)
+
+// ReadinessCheck is the result of a single node network readiness check.
+type ReadinessCheck struct {
+	Ready  bool   `json:"ready"`
+	Detail string `json:"detail"`
+}
+
+// ReadinessReport is the result of CheckNodeNetworkReadiness.
+type ReadinessReport struct {
+	Ready                   bool           `json:"ready"`
+	HNSRunning              ReadinessCheck `json:"hnsRunning"`
+	PhysicalAdapterUp       ReadinessCheck `json:"physicalAdapterUp"`
+	MellanoxPriorityVLANTag ReadinessCheck `json:"mellanoxPriorityVLANTag"`
+}
+
+// CheckNodeNetworkReadiness checks that HNS is running, that at least one physical adapter is up and,
+// if a Mellanox adapter is present, that its PriorityVLANTag is set to the desired value.
+// Failed checks are reported in the ReadinessReport; an error is only returned if ctx is done.
+func CheckNodeNetworkReadiness(ctx context.Context, execClient ExecClient) (ReadinessReport, error) {
+	var report ReadinessReport
+
+	checks := []struct {
+		result *ReadinessCheck
+		check  func(ExecClient) ReadinessCheck
+	}{
+		{&report.HNSRunning, checkHNSRunning},
+		{&report.PhysicalAdapterUp, checkPhysicalAdapterUp},
+		{&report.MellanoxPriorityVLANTag, checkMellanoxPriorityVLANTag},
+	}
+
+	report.Ready = true
+	for _, c := range checks {
+		if err := ctx.Err(); err != nil {
+			return ReadinessReport{}, fmt.Errorf("node network readiness check cancelled: %w", err)
+		}
+
+		*c.result = c.check(execClient)
+		report.Ready = report.Ready && c.result.Ready
+	}
+
+	return report, nil
+}
+
+func checkHNSRunning(execClient ExecClient) ReadinessCheck {
+	status, err := execClient.ExecutePowershellCommand(GetHnsServiceStatusCommand)
+	if err != nil {
+		return ReadinessCheck{Detail: fmt.Sprintf("failed to get hns service status: %v", err)}
+	}
+
+	if !strings.EqualFold(status, "Running") {
+		return ReadinessCheck{Detail: fmt.Sprintf("hns service status is %q", status)}
+	}
+
+	return ReadinessCheck{Ready: true, Detail: "hns service is running"}
+}
+
+func checkPhysicalAdapterUp(execClient ExecClient) ReadinessCheck {
+	out, err := execClient.ExecutePowershellCommand(GetUpPhysicalAdapterNamesCommand)
+	if err != nil {
+		return ReadinessCheck{Detail: fmt.Sprintf("failed to get physical adapters: %v", err)}
+	}
+
+	if out == "" {
+		return ReadinessCheck{Detail: "no physical adapter is up"}
+	}
+
+	return ReadinessCheck{
+		Ready:  true,
+		Detail: fmt.Sprintf("physical adapters up: %s", strings.Join(splitPowershellLines(out), ", ")),
+	}
+}
+
+func checkMellanoxPriorityVLANTag(execClient ExecClient) ReadinessCheck {
+	adapterName, err := getMellanoxAdapterName(execClient)
+	if errors.Is(err, ErrMellanoxAdapterNotFound) {
+		return ReadinessCheck{Ready: true, Detail: "no Mellanox adapter present"}
+	}
+
+	if err != nil {
+		return ReadinessCheck{Detail: err.Error()}
+	}
+
+	value, err := GetMellanoxPriorityVLANTag(execClient, adapterName)
+	if err != nil {
+		return ReadinessCheck{Detail: err.Error()}
+	}
+
+	if value != DesiredMellanoxPriorityVLANTag {
+		return ReadinessCheck{
+			Detail: fmt.Sprintf("PriorityVLANTag of %s is %d, expected %d", adapterName, value, DesiredMellanoxPriorityVLANTag),
+		}
+	}
+
+	return ReadinessCheck{Ready: true, Detail: fmt.Sprintf("PriorityVLANTag of %s is %d", adapterName, value)}
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errReadinessTest = errors.New("readiness test error")
+
+func TestCheckNodeNetworkReadinessReady(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch {
+		case cmd == GetHnsServiceStatusCommand:
+			return "Running", nil
+		case cmd == GetUpPhysicalAdapterNamesCommand:
+			return "Ethernet 2\r\nEthernet 3", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter |"):
+			return "Ethernet 3", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty"):
+			return "3", nil
+		}
+		return "", errReadinessTest
+	})
+
+	report, err := CheckNodeNetworkReadiness(context.Background(), mockExecClient)
+	require.NoError(t, err)
+	assert.True(t, report.Ready)
+	assert.True(t, report.HNSRunning.Ready)
+	assert.True(t, report.PhysicalAdapterUp.Ready)
+	assert.Equal(t, "physical adapters up: Ethernet 2, Ethernet 3", report.PhysicalAdapterUp.Detail)
+	assert.True(t, report.MellanoxPriorityVLANTag.Ready)
+}
+
+func TestCheckNodeNetworkReadinessNoMellanox(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch cmd {
+		case GetHnsServiceStatusCommand:
+			return "Running", nil
+		case GetUpPhysicalAdapterNamesCommand:
+			return "Ethernet", nil
+		}
+		return "", nil
+	})
+
+	report, err := CheckNodeNetworkReadiness(context.Background(), mockExecClient)
+	require.NoError(t, err)
+	assert.True(t, report.Ready)
+	assert.True(t, report.MellanoxPriorityVLANTag.Ready)
+	assert.Equal(t, "no Mellanox adapter present", report.MellanoxPriorityVLANTag.Detail)
+}
+
+func TestCheckNodeNetworkReadinessPartiallyReady(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		switch {
+		case cmd == GetHnsServiceStatusCommand:
+			return "Stopped", nil
+		case cmd == GetUpPhysicalAdapterNamesCommand:
+			return "Ethernet 2", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapter |"):
+			return "Ethernet 2", nil
+		case strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty"):
+			return "0", nil
+		}
+		return "", errReadinessTest
+	})
+
+	report, err := CheckNodeNetworkReadiness(context.Background(), mockExecClient)
+	require.NoError(t, err)
+	assert.False(t, report.Ready)
+	assert.False(t, report.HNSRunning.Ready)
+	assert.Equal(t, `hns service status is "Stopped"`, report.HNSRunning.Detail)
+	assert.True(t, report.PhysicalAdapterUp.Ready)
+	assert.False(t, report.MellanoxPriorityVLANTag.Ready)
+	assert.Equal(t, "PriorityVLANTag of Ethernet 2 is 0, expected 3", report.MellanoxPriorityVLANTag.Detail)
+}
+
+func TestCheckNodeNetworkReadinessCommandFailure(t *testing.T) {
+	report, err := CheckNodeNetworkReadiness(context.Background(), NewMockExecClient(true))
+	require.NoError(t, err)
+	assert.False(t, report.Ready)
+	assert.False(t, report.HNSRunning.Ready)
+	assert.False(t, report.PhysicalAdapterUp.Ready)
+	assert.False(t, report.MellanoxPriorityVLANTag.Ready)
+}
+
+func TestCheckNodeNetworkReadinessCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := CheckNodeNetworkReadiness(ctx, NewMockExecClient(false))
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,176 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Number of attempts of a registry write failing transiently, and the delay before the first retry,
+// doubled before each following one
+const registryWriteAttempts = 5
+
+var registryWriteRetryDelay = 200 * time.Millisecond
+
+// transientRegistryErrorRegex matches the powershell errors of registry writes racing the services initializing at boot
+var transientRegistryErrorRegex = regexp.MustCompile(`(?i)access is denied|registry access is not allowed|being used by another process`)
+
+var (
+	// ErrRegistryKeyNotFound is returned when a registry key does not exist
+	ErrRegistryKeyNotFound = errors.New("registry key not found")
+	// ErrRegistryValueNotFound is returned when a value does not exist under a registry key
+	ErrRegistryValueNotFound = errors.New("registry value not found")
+)
+
+// RegistryValuesError reports, by name, the values GetRegistryValues failed to read
+type RegistryValuesError struct {
+	Path   string
+	Errors map[string]error
+}
+
+func (e *RegistryValuesError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", name, e.Errors[name]))
+	}
+
+	return fmt.Sprintf("failed to read registry values of %s: %s", e.Path, strings.Join(msgs, "; "))
+}
+
+// Is reports whether the error of any of the values is target
+func (e *RegistryValuesError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetRegistryValues reads the named string and numeric values of the registry key at path
+// with a single Get-ItemProperty call. The values which could be read are returned even if
+// others could not, in which case the error is a *RegistryValuesError holding the error of each of them.
+func GetRegistryValues(execClient ExecClient, path string, names []string) (map[string]string, error) {
+	cmd := fmt.Sprintf("Get-ItemProperty -Path '%s' -Name %s -ErrorAction SilentlyContinue | Select-Object %s | ConvertTo-Json",
+		escapePowershellString(path), powershellStringList(names), powershellStringList(names))
+	out, err := execClient.ExecutePowershellCommand(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry values of %s: %w", path, err)
+	}
+
+	raw := map[string]json.RawMessage{}
+	if out != "" {
+		if err = json.Unmarshal([]byte(out), &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse registry values of %s: %w", path, err)
+		}
+	}
+
+	values := make(map[string]string, len(names))
+	valueErrs := map[string]error{}
+	for _, name := range names {
+		value, err := parseRegistryValue(raw[name])
+		if err != nil {
+			valueErrs[name] = err
+			continue
+		}
+
+		values[name] = value
+	}
+
+	if len(valueErrs) > 0 {
+		return values, &RegistryValuesError{Path: path, Errors: valueErrs}
+	}
+
+	return values, nil
+}
+
+// parseRegistryValue returns the string form of a registry value serialized by ConvertTo-Json
+func parseRegistryValue(raw json.RawMessage) (string, error) {
+	s := strings.TrimSpace(string(raw))
+	switch {
+	case s == "" || s == "null":
+		return "", ErrRegistryValueNotFound
+	case strings.HasPrefix(s, `"`):
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return "", fmt.Errorf("failed to parse registry value %s: %w", s, err)
+		}
+		return value, nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err != nil {
+		return "", fmt.Errorf("unsupported registry value %s: %w", s, err)
+	}
+
+	return number.String(), nil
+}
+
+// GetRegistryMultiStringValue reads the REG_MULTI_SZ value name of the registry key at path under root.
+// Returns ErrRegistryKeyNotFound or ErrRegistryValueNotFound if the key or the value does not exist.
+func GetRegistryMultiStringValue(root registry.Key, path, name string) ([]string, error) {
+	k, err := registry.OpenKey(root, path, registry.QUERY_VALUE)
+	if errors.Is(err, registry.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", path, ErrRegistryKeyNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open registry key %s: %w", path, err)
+	}
+	defer k.Close()
+
+	values, _, err := k.GetStringsValue(name)
+	if errors.Is(err, registry.ErrNotExist) {
+		return nil, fmt.Errorf("%s of %s: %w", name, path, ErrRegistryValueNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry value %s of %s: %w", name, path, err)
+	}
+
+	return values, nil
+}
+
+// isTransientRegistryError returns whether err is an access denied or sharing violation error, which registry
+// writes may transiently fail with early in boot
+func isTransientRegistryError(err error) bool {
+	if errors.Is(err, windows.ERROR_ACCESS_DENIED) || errors.Is(err, windows.ERROR_SHARING_VIOLATION) {
+		return true
+	}
+
+	return err != nil && transientRegistryErrorRegex.MatchString(err.Error())
+}
+
+// retryRegistryWrite runs the registry write until it succeeds or fails with an error other than a transient one,
+// at most registryWriteAttempts times
+func retryRegistryWrite(write func() error) error {
+	delay := registryWriteRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if !isTransientRegistryError(err) || attempt == registryWriteAttempts {
+			return err
+		}
+
+		log.Printf("Registry write failed transiently, retrying in %v: %v", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+const mellanoxDriverKeyPath = registryKeyPrefix + "{4d36e972-e325-11ce-bfc1-08002be10318}\\0001"
+
+func TestGetRegistryValues(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, "Get-ItemProperty -Path '"+mellanoxDriverKeyPath+"' -Name '*PriorityVLANTag', 'DriverVersion', "+
+			"'*JumboPacket' -ErrorAction SilentlyContinue | Select-Object '*PriorityVLANTag', 'DriverVersion', '*JumboPacket' | ConvertTo-Json", cmd)
+		return `{"*PriorityVLANTag": "3", "DriverVersion": "3.10.51000", "*JumboPacket": null}`, nil
+	})
+
+	values, err := GetRegistryValues(mockExecClient, mellanoxDriverKeyPath, []string{"*PriorityVLANTag", "DriverVersion", "*JumboPacket"})
+	assert.Equal(t, map[string]string{"*PriorityVLANTag": "3", "DriverVersion": "3.10.51000"}, values)
+	require.ErrorIs(t, err, ErrRegistryValueNotFound)
+
+	var valuesErr *RegistryValuesError
+	require.True(t, errors.As(err, &valuesErr))
+	assert.Len(t, valuesErr.Errors, 1)
+	assert.ErrorIs(t, valuesErr.Errors["*JumboPacket"], ErrRegistryValueNotFound)
+	assert.Len(t, mockExecClient.RecordedPowershellCommands(), 1)
+}
+
+func TestGetRegistryValuesNumeric(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return `{"Start": 2, "Type": 1}`, nil
+	})
+
+	values, err := GetRegistryValues(mockExecClient, "HKLM:\\SYSTEM\\CurrentControlSet\\Services\\hns", []string{"Start", "Type"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Start": "2", "Type": "1"}, values)
+}
+
+func TestGetRegistryValuesNoneFound(t *testing.T) {
+	values, err := GetRegistryValues(NewMockExecClient(false), mellanoxDriverKeyPath, []string{"a", "b"})
+	assert.Empty(t, values)
+	require.ErrorIs(t, err, ErrRegistryValueNotFound)
+}
+
+func TestGetRegistryValuesError(t *testing.T) {
+	_, err := GetRegistryValues(NewMockExecClient(true), mellanoxDriverKeyPath, []string{"a"})
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+const testRegistryKeyPath = `Software\AzureContainerNetworkingTest`
+
+func TestGetRegistryMultiStringValue(t *testing.T) {
+	k, _, err := registry.CreateKey(registry.CURRENT_USER, testRegistryKeyPath, registry.ALL_ACCESS)
+	require.NoError(t, err)
+	defer func() {
+		k.Close()
+		require.NoError(t, registry.DeleteKey(registry.CURRENT_USER, testRegistryKeyPath))
+	}()
+
+	require.NoError(t, k.SetStringsValue("DNSServers", []string{"168.63.129.16", "10.0.0.10"}))
+	require.NoError(t, k.SetStringValue("Name", "azure"))
+
+	values, err := GetRegistryMultiStringValue(registry.CURRENT_USER, testRegistryKeyPath, "DNSServers")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"168.63.129.16", "10.0.0.10"}, values)
+
+	_, err = GetRegistryMultiStringValue(registry.CURRENT_USER, testRegistryKeyPath, "Missing")
+	require.ErrorIs(t, err, ErrRegistryValueNotFound)
+
+	// not a REG_MULTI_SZ value
+	_, err = GetRegistryMultiStringValue(registry.CURRENT_USER, testRegistryKeyPath, "Name")
+	require.ErrorIs(t, err, registry.ErrUnexpectedType)
+
+	_, err = GetRegistryMultiStringValue(registry.CURRENT_USER, testRegistryKeyPath+`\Missing`, "DNSServers")
+	require.ErrorIs(t, err, ErrRegistryKeyNotFound)
+}
+
+// errRegistryAccessDenied is the error of a powershell registry write denied access
+var errRegistryAccessDenied = errors.New("Set-ItemProperty : Requested registry access is not allowed.")
+
+// shortenRegistryWriteRetryDelay shortens the delay between registry write retries for the duration of the test
+func shortenRegistryWriteRetryDelay(t *testing.T) {
+	registryWriteRetryDelay = time.Millisecond
+	t.Cleanup(func() { registryWriteRetryDelay = 200 * time.Millisecond })
+}
+
+func TestRetryRegistryWrite(t *testing.T) {
+	shortenRegistryWriteRetryDelay(t)
+
+	tests := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectedErr   error
+	}{
+		{name: "success", errs: []error{nil}, expectedCalls: 1},
+		{name: "access denied then success", errs: []error{errRegistryAccessDenied, nil}, expectedCalls: 2},
+		{name: "native sharing violation then success", errs: []error{windows.ERROR_SHARING_VIOLATION, nil}, expectedCalls: 2},
+		{name: "native access denied then success", errs: []error{fmt.Errorf("write: %w", windows.ERROR_ACCESS_DENIED), nil}, expectedCalls: 2},
+		{
+			name:          "value type error",
+			errs:          []error{errors.New("New-ItemProperty : Cannot convert value \"abc\" to type \"System.Int32\".")},
+			expectedCalls: 1,
+			expectedErr:   errors.New("New-ItemProperty : Cannot convert value \"abc\" to type \"System.Int32\"."),
+		},
+		{
+			name:          "access denied",
+			errs:          []error{errRegistryAccessDenied, errRegistryAccessDenied, errRegistryAccessDenied, errRegistryAccessDenied, errRegistryAccessDenied},
+			expectedCalls: registryWriteAttempts,
+			expectedErr:   errRegistryAccessDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryRegistryWrite(func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+
+			assert.Equal(t, tt.expectedCalls, calls)
+			if tt.expectedErr != nil {
+				require.EqualError(t, err, tt.expectedErr.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
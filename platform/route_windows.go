@@ -0,0 +1,94 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"net"
+)
+
+// Destinations of the default routes
+var defaultRouteDestinations = []string{"0.0.0.0/0", "::/0"}
+
+// EnsureRoute adds the route to destinationCIDR through nextHop on the adapter unless it already exists,
+// so it is safe to call repeatedly
+func EnsureRoute(execClient ExecClient, destinationCIDR, nextHop, adapterName string) error {
+	_, destination, err := net.ParseCIDR(destinationCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid route destination %q: %w", destinationCIDR, err)
+	}
+
+	gateway := net.ParseIP(nextHop)
+	if gateway == nil {
+		return fmt.Errorf("invalid route next hop %q", nextHop)
+	}
+
+	if (destination.IP.To4() == nil) != (gateway.To4() == nil) {
+		return fmt.Errorf("route next hop %s is not in the address family of %s", nextHop, destination)
+	}
+
+	cmd := fmt.Sprintf("Get-NetRoute -DestinationPrefix '%s' -InterfaceAlias '%s' -ErrorAction SilentlyContinue | "+
+		"Select-Object -ExpandProperty NextHop", destination, escapePowershellString(adapterName))
+	out, err := execClient.ExecutePowershellCommand(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to get routes to %s on %s: %w", destination, adapterName, err)
+	}
+
+	for _, hop := range splitPowershellLines(out) {
+		if gateway.Equal(net.ParseIP(hop)) {
+			return nil
+		}
+	}
+
+	cmd = fmt.Sprintf("New-NetRoute -DestinationPrefix '%s' -InterfaceAlias '%s' -NextHop '%s'", destination, escapePowershellString(adapterName), gateway)
+	if _, err = execClient.ExecutePowershellCommand(cmd); err != nil {
+		return fmt.Errorf("failed to add route to %s via %s on %s: %w", destination, gateway, adapterName, err)
+	}
+
+	return nil
+}
+
+// RemoveRoutesForAdapter removes the routes of the adapter and returns how many were removed.
+// The default routes are only removed if removeDefault is set.
+func RemoveRoutesForAdapter(execClient ExecClient, adapterName string, removeDefault bool) (int, error) {
+	type route struct {
+		DestinationPrefix string
+		NextHop           string
+	}
+
+	cmd := fmt.Sprintf("Get-NetRoute -InterfaceAlias '%s' -ErrorAction SilentlyContinue | "+
+		"Select-Object DestinationPrefix, NextHop", escapePowershellString(adapterName))
+	routes, err := ExecutePowershellJSON[[]route](execClient, cmd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get routes of %s: %w", adapterName, err)
+	}
+
+	removed := 0
+	for _, route := range routes {
+		if !removeDefault && isDefaultRouteDestination(route.DestinationPrefix) {
+			continue
+		}
+
+		cmd = fmt.Sprintf("Remove-NetRoute -InterfaceAlias '%s' -DestinationPrefix '%s' -NextHop '%s' -Confirm:$false",
+			escapePowershellString(adapterName), escapePowershellString(route.DestinationPrefix), escapePowershellString(route.NextHop))
+		if _, err = execClient.ExecutePowershellCommand(cmd); err != nil {
+			return removed, fmt.Errorf("failed to remove route to %s via %s on %s: %w",
+				route.DestinationPrefix, route.NextHop, adapterName, err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// isDefaultRouteDestination returns whether destination is the destination of a default route
+func isDefaultRouteDestination(destination string) bool {
+	for _, d := range defaultRouteDestinations {
+		if destination == d {
+			return true
+		}
+	}
+
+	return false
+}
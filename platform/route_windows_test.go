@@ -0,0 +1,113 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const getRoutesToDestinationCommand = "Get-NetRoute -DestinationPrefix '10.1.0.0/16' -InterfaceAlias 'Ethernet 2' -ErrorAction SilentlyContinue | " +
+	"Select-Object -ExpandProperty NextHop"
+
+func TestEnsureRouteAdd(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == getRoutesToDestinationCommand {
+			// a route to the destination through another next hop
+			return "10.240.0.2", nil
+		}
+		return "", nil
+	})
+
+	// the destination is normalized to its network address
+	require.NoError(t, EnsureRoute(mockExecClient, "10.1.2.3/16", "10.240.0.1", "Ethernet 2"))
+	assert.Equal(t, []string{
+		getRoutesToDestinationCommand,
+		"New-NetRoute -DestinationPrefix '10.1.0.0/16' -InterfaceAlias 'Ethernet 2' -NextHop '10.240.0.1'",
+	}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestEnsureRouteAlreadyExists(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "10.240.0.2\r\n10.240.0.1\r\n", nil
+	})
+
+	require.NoError(t, EnsureRoute(mockExecClient, "10.1.0.0/16", "10.240.0.1", "Ethernet 2"))
+	assert.Equal(t, []string{getRoutesToDestinationCommand}, mockExecClient.RecordedPowershellCommands())
+}
+
+func TestEnsureRouteInvalid(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	require.Error(t, EnsureRoute(mockExecClient, "10.1.0.0", "10.240.0.1", "Ethernet 2"))
+	require.Error(t, EnsureRoute(mockExecClient, "10.1.0.0/16", "10.240.0.300", "Ethernet 2"))
+	require.Error(t, EnsureRoute(mockExecClient, "10.1.0.0/16", "fe80::1", "Ethernet 2"))
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+
+	require.ErrorIs(t, EnsureRoute(NewMockExecClient(true), "10.1.0.0/16", "10.240.0.1", "Ethernet 2"), ErrMockExec)
+}
+
+const adapterRoutesFixture = `[
+    {"DestinationPrefix": "0.0.0.0/0", "NextHop": "10.240.0.1"},
+    {"DestinationPrefix": "10.1.0.0/16", "NextHop": "10.240.0.1"},
+    {"DestinationPrefix": "10.240.0.0/16", "NextHop": "0.0.0.0"},
+    {"DestinationPrefix": "::/0", "NextHop": "fe80::1"}
+]`
+
+func adapterRoutesResponder(cmd string) (string, error) {
+	if strings.HasPrefix(cmd, "Get-NetRoute -InterfaceAlias 'Ethernet 2'") {
+		return adapterRoutesFixture, nil
+	}
+	return "", nil
+}
+
+func TestRemoveRoutesForAdapter(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterRoutesResponder)
+
+	removed, err := RemoveRoutesForAdapter(mockExecClient, "Ethernet 2", false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	// the default routes are preserved
+	assert.Equal(t, []string{
+		"Remove-NetRoute -InterfaceAlias 'Ethernet 2' -DestinationPrefix '10.1.0.0/16' -NextHop '10.240.0.1' -Confirm:$false",
+		"Remove-NetRoute -InterfaceAlias 'Ethernet 2' -DestinationPrefix '10.240.0.0/16' -NextHop '0.0.0.0' -Confirm:$false",
+	}, mockExecClient.RecordedPowershellCommands()[1:])
+}
+
+func TestRemoveRoutesForAdapterIncludingDefault(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterRoutesResponder)
+
+	removed, err := RemoveRoutesForAdapter(mockExecClient, "Ethernet 2", true)
+	require.NoError(t, err)
+	assert.Equal(t, 4, removed)
+	assert.Contains(t, mockExecClient.RecordedPowershellCommands(),
+		"Remove-NetRoute -InterfaceAlias 'Ethernet 2' -DestinationPrefix '0.0.0.0/0' -NextHop '10.240.0.1' -Confirm:$false")
+}
+
+func TestRemoveRoutesForAdapterNoRoutes(t *testing.T) {
+	removed, err := RemoveRoutesForAdapter(NewMockExecClient(false), "Ethernet 2", false)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+}
+
+func TestRemoveRoutesForAdapterError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "Remove-NetRoute") && strings.Contains(cmd, "10.240.0.0/16") {
+			return "", ErrMockExec
+		}
+		return adapterRoutesResponder(cmd)
+	})
+
+	removed, err := RemoveRoutesForAdapter(mockExecClient, "Ethernet 2", false)
+	require.ErrorIs(t, err, ErrMockExec)
+	assert.Equal(t, 1, removed)
+}
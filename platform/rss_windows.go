@@ -0,0 +1,143 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	// numRSSQueuesKeyword is the registry keyword of the number of RSS queues of an adapter
+	numRSSQueuesKeyword = "*NumRssQueues"
+
+	// maxRSSProcessorsKeyword is the registry keyword of the maximum number of processors used for RSS by an adapter
+	maxRSSProcessorsKeyword = "*MaxRssProcessors"
+)
+
+// RSSProcessorInfo is the RSS configuration of an adapter, as reported by Get-NetAdapterRss
+type RSSProcessorInfo struct {
+	Enabled               bool
+	BaseProcessorGroup    int
+	BaseProcessorNumber   int
+	MaxProcessorNumber    int
+	MaxProcessors         int
+	NumberOfReceiveQueues int
+}
+
+// ErrInvalidProcessor is returned when a processor is not one of the logical processors of the host
+var ErrInvalidProcessor = errors.New("invalid processor")
+
+// numCPU returns the number of logical processors of the host
+var numCPU = runtime.NumCPU
+
+// activeProcessorCount returns the number of active logical processors in the processor group, zero if there is no such group
+var activeProcessorCount = windows.GetActiveProcessorCount
+
+// ConfigureRSSQueues sets the number of RSS queues and the maximum number of RSS processors of the adapter to maxQueues.
+// If maxQueues is zero it defaults to the number of logical processors, lowered to the largest value supported by the adapter.
+// An explicit maxQueues must be supported by the adapter.
+func ConfigureRSSQueues(execClient ExecClient, adapterName string, maxQueues int) error {
+	if maxQueues < 0 {
+		return fmt.Errorf("invalid number of RSS queues %d", maxQueues)
+	}
+
+	autoDerived := maxQueues == 0
+	if autoDerived {
+		maxQueues = numCPU()
+	}
+
+	keywords := []string{numRSSQueuesKeyword, maxRSSProcessorsKeyword}
+	properties := make([]advancedProperty, len(keywords))
+	values := make([]string, len(keywords))
+
+	// validate both values before setting any so that the adapter isn't left half configured
+	for i, keyword := range keywords {
+		property, err := getAdvancedProperty(execClient, adapterName, keyword)
+		if err != nil {
+			return err
+		}
+
+		value := strconv.Itoa(maxQueues)
+		if autoDerived {
+			if value, err = property.largestSupportedValue(maxQueues); err != nil {
+				return fmt.Errorf("failed to derive %s of %s: %w", keyword, adapterName, err)
+			}
+		}
+
+		if err = property.validate(value); err != nil {
+			return fmt.Errorf("invalid %s for %s: %w", keyword, adapterName, err)
+		}
+
+		properties[i], values[i] = property, value
+	}
+
+	for i, keyword := range keywords {
+		if properties[i].value() == values[i] {
+			continue
+		}
+
+		log.Printf("Setting %s of %s from %s to %s", keyword, adapterName, properties[i].value(), values[i])
+		if err := setAdvancedProperty(execClient, adapterName, keyword, values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetRSSProcessorInfo returns the processors the adapter spreads its receive queues over.
+// Returns ErrFeatureUnsupported if the adapter does not support RSS.
+func GetRSSProcessorInfo(execClient ExecClient, adapterName string) (RSSProcessorInfo, error) {
+	cmd := fmt.Sprintf("Get-NetAdapterRss -Name '%s' | Select-Object Enabled, BaseProcessorGroup, BaseProcessorNumber, "+
+		"MaxProcessorNumber, MaxProcessors, NumberOfReceiveQueues", escapePowershellString(adapterName))
+	info, err := ExecutePowershellJSON[RSSProcessorInfo](execClient, cmd)
+	if isNoCimObjectsFoundError(err) {
+		return RSSProcessorInfo{}, fmt.Errorf("RSS on %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return RSSProcessorInfo{}, fmt.Errorf("failed to get RSS settings of %s: %w", adapterName, err)
+	}
+
+	return info, nil
+}
+
+// SetRSSBaseProcessor sets the first processor the adapter spreads its receive queues over,
+// e.g. to keep RSS off CPU 0 which handles many of the system interrupts.
+// The processor number is the index of the processor within its group.
+// Returns ErrInvalidProcessor if the host has no such processor, ErrFeatureUnsupported if the adapter does not support RSS.
+func SetRSSBaseProcessor(execClient ExecClient, adapterName string, group, number int) error {
+	if group < 0 || group > math.MaxUint16 || number < 0 {
+		return fmt.Errorf("processor %d:%d: %w", group, number, ErrInvalidProcessor)
+	}
+
+	if active := activeProcessorCount(uint16(group)); uint32(number) >= active {
+		return fmt.Errorf("processor %d:%d with %d active logical processors in the group: %w", group, number, active, ErrInvalidProcessor)
+	}
+
+	na := &networkAdapter{execClient: execClient}
+	if err := na.checkAdapterExists(adapterName); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("Set-NetAdapterRss -Name '%s' -BaseProcessorGroup %d -BaseProcessorNumber %d", escapePowershellString(adapterName), group, number)
+	_, err := execClient.ExecutePowershellCommand(cmd)
+	if isNoCimObjectsFoundError(err) {
+		return fmt.Errorf("RSS on %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to set RSS base processor of %s to %d:%d: %w", adapterName, group, number, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	numRSSQueuesFixture = `{
+    "RegistryKeyword":  "*NumRssQueues",
+    "RegistryValue":  ["2"],
+    "DisplayValue":  "2 Queues",
+    "ValidRegistryValues":  ["1", "2", "4", "8", "16"],
+    "ValidDisplayValues":  ["1 Queue", "2 Queues", "4 Queues", "8 Queues", "16 Queues"],
+    "NumericParameterMinValue":  null,
+    "NumericParameterMaxValue":  null
+}`
+	maxRSSProcessorsFixture = `{
+    "RegistryKeyword":  "*MaxRssProcessors",
+    "RegistryValue":  ["4"],
+    "DisplayValue":  "4",
+    "ValidRegistryValues":  null,
+    "ValidDisplayValues":  null,
+    "NumericParameterMinValue":  1,
+    "NumericParameterMaxValue":  64
+}`
+)
+
+func rssPropertiesResponder(cmd string) (string, error) {
+	switch {
+	case strings.Contains(cmd, "Get-NetAdapterAdvancedProperty") && strings.Contains(cmd, numRSSQueuesKeyword):
+		return numRSSQueuesFixture, nil
+	case strings.Contains(cmd, "Get-NetAdapterAdvancedProperty") && strings.Contains(cmd, maxRSSProcessorsKeyword):
+		return maxRSSProcessorsFixture, nil
+	}
+	return adapterNamesResponder(cmd)
+}
+
+func setCommands(commands []string) []string {
+	var sets []string
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd, "Set-") {
+			sets = append(sets, cmd)
+		}
+	}
+	return sets
+}
+
+func TestConfigureRSSQueuesExplicit(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(rssPropertiesResponder)
+
+	require.NoError(t, ConfigureRSSQueues(mockExecClient, "Ethernet 2", 8))
+	assert.Equal(t, []string{
+		"Set-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '*NumRssQueues' -RegistryValue '8'",
+		"Set-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '*MaxRssProcessors' -RegistryValue '8'",
+	}, setCommands(mockExecClient.RecordedPowershellCommands()))
+}
+
+func TestConfigureRSSQueuesAutoDerived(t *testing.T) {
+	defer func() { numCPU = runtime.NumCPU }()
+
+	tests := []struct {
+		name     string
+		cpus     int
+		expected []string
+	}{
+		{
+			name: "supported cpu count",
+			cpus: 4,
+			expected: []string{
+				"Set-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '*NumRssQueues' -RegistryValue '4'",
+			},
+		},
+		{
+			name: "unsupported cpu count",
+			cpus: 12,
+			expected: []string{
+				"Set-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '*NumRssQueues' -RegistryValue '8'",
+				"Set-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '*MaxRssProcessors' -RegistryValue '12'",
+			},
+		},
+		{
+			name: "cpu count above supported range",
+			cpus: 96,
+			expected: []string{
+				"Set-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '*NumRssQueues' -RegistryValue '16'",
+				"Set-NetAdapterAdvancedProperty -Name 'Ethernet 2' -RegistryKeyword '*MaxRssProcessors' -RegistryValue '64'",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			numCPU = func() int { return tt.cpus }
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(rssPropertiesResponder)
+
+			require.NoError(t, ConfigureRSSQueues(mockExecClient, "Ethernet 2", 0))
+			assert.Equal(t, tt.expected, setCommands(mockExecClient.RecordedPowershellCommands()))
+		})
+	}
+}
+
+func TestConfigureRSSQueuesInvalid(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(rssPropertiesResponder)
+
+	// 6 queues isn't supported by the adapter, nothing must be set
+	require.Error(t, ConfigureRSSQueues(mockExecClient, "Ethernet 2", 6))
+	require.Error(t, ConfigureRSSQueues(mockExecClient, "Ethernet 2", -1))
+	assert.Empty(t, setCommands(mockExecClient.RecordedPowershellCommands()))
+}
+
+func TestConfigureRSSQueuesUnsupported(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+	require.ErrorIs(t, ConfigureRSSQueues(mockExecClient, "Ethernet 2", 4), adapter.ErrFeatureUnsupported)
+}
+
+func TestGetRSSProcessorInfo(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		require.True(t, strings.HasPrefix(cmd, "Get-NetAdapterRss -Name 'Ethernet 2'"))
+		return `{
+    "Enabled":  true,
+    "BaseProcessorGroup":  0,
+    "BaseProcessorNumber":  2,
+    "MaxProcessorNumber":  7,
+    "MaxProcessors":  4,
+    "NumberOfReceiveQueues":  4
+}`, nil
+	})
+
+	info, err := GetRSSProcessorInfo(mockExecClient, "Ethernet 2")
+	require.NoError(t, err)
+	assert.Equal(t, RSSProcessorInfo{
+		Enabled:               true,
+		BaseProcessorNumber:   2,
+		MaxProcessorNumber:    7,
+		MaxProcessors:         4,
+		NumberOfReceiveQueues: 4,
+	}, info)
+}
+
+func TestGetRSSProcessorInfoUnsupported(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(string) (string, error) {
+		return "", errors.New("exit status 1:Get-NetAdapterRss : No MSFT_NetAdapterRssSettingData objects found " +
+			"with property 'Name' equal to 'Ethernet 2'.")
+	})
+
+	_, err := GetRSSProcessorInfo(mockExecClient, "Ethernet 2")
+	require.ErrorIs(t, err, adapter.ErrFeatureUnsupported)
+
+	_, err = GetRSSProcessorInfo(NewMockExecClient(true), "Ethernet 2")
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+// fakeActiveProcessorCount makes the host have processor groups with the given numbers of active processors
+// for the duration of the test
+func fakeActiveProcessorCount(t *testing.T, groups ...uint32) {
+	t.Helper()
+	t.Cleanup(func() { activeProcessorCount = windows.GetActiveProcessorCount })
+	activeProcessorCount = func(group uint16) uint32 {
+		if int(group) < len(groups) {
+			return groups[group]
+		}
+		return 0
+	}
+}
+
+func TestSetRSSBaseProcessor(t *testing.T) {
+	// an 80 processor host split in two groups of 40
+	fakeActiveProcessorCount(t, 40, 40)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	require.NoError(t, SetRSSBaseProcessor(mockExecClient, "Ethernet 2", 0, 2))
+	require.NoError(t, SetRSSBaseProcessor(mockExecClient, "Ethernet 2", 1, 39))
+	assert.Equal(t, []string{
+		"Set-NetAdapterRss -Name 'Ethernet 2' -BaseProcessorGroup 0 -BaseProcessorNumber 2",
+		"Set-NetAdapterRss -Name 'Ethernet 2' -BaseProcessorGroup 1 -BaseProcessorNumber 39",
+	}, setCommands(mockExecClient.RecordedPowershellCommands()))
+}
+
+func TestSetRSSBaseProcessorInvalid(t *testing.T) {
+	fakeActiveProcessorCount(t, 40, 40)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(adapterNamesResponder)
+
+	for _, processor := range [][2]int{{0, 40}, {1, 40}, {2, 0}, {-1, 2}, {0, -1}, {0, 64}, {math.MaxUint16 + 1, 0}} {
+		require.ErrorIs(t, SetRSSBaseProcessor(mockExecClient, "Ethernet 2", processor[0], processor[1]), ErrInvalidProcessor)
+	}
+	assert.Empty(t, mockExecClient.RecordedPowershellCommands())
+
+	require.ErrorIs(t, SetRSSBaseProcessor(mockExecClient, "Ethernet 3", 0, 2), adapter.ErrAdapterNotFound)
+
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		if cmd == GetAdapterNamesCommand {
+			return adapterNamesResponder(cmd)
+		}
+		return "", errors.New("Set-NetAdapterRss : No MSFT_NetAdapterRssSettingData objects found")
+	})
+	require.ErrorIs(t, SetRSSBaseProcessor(mockExecClient, "Ethernet 2", 0, 2), adapter.ErrFeatureUnsupported)
+
+	require.ErrorIs(t, SetRSSBaseProcessor(NewMockExecClient(true), "Ethernet 2", 0, 2), ErrMockExec)
+}
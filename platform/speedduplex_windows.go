@@ -0,0 +1,40 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+)
+
+// speedDuplexKeyword is the registry keyword of the link speed and duplex mode of an adapter
+const speedDuplexKeyword = "*SpeedDuplex"
+
+// GetSpeedDuplex returns the link speed and duplex mode of the adapter as displayed by its driver,
+// e.g. Auto Negotiation or 10 Gbps Full Duplex.
+// Returns ErrFeatureUnsupported if the adapter has no speed and duplex setting.
+func GetSpeedDuplex(execClient ExecClient, adapterName string) (string, error) {
+	property, err := getAdvancedProperty(execClient, adapterName, speedDuplexKeyword)
+	if err != nil {
+		return "", err
+	}
+
+	return property.DisplayValue, nil
+}
+
+// SetSpeedDuplex forces the link speed and duplex mode of the adapter to value, one of the values displayed
+// by its driver, e.g. 1.0 Gbps Full Duplex. Auto Negotiation restores the negotiation of the link.
+// Returns ErrFeatureUnsupported if the adapter has no speed and duplex setting.
+func SetSpeedDuplex(execClient ExecClient, adapterName, value string) error {
+	property, err := getAdvancedProperty(execClient, adapterName, speedDuplexKeyword)
+	if err != nil {
+		return err
+	}
+
+	registryValue, err := property.registryValueOf(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", adapterName, err)
+	}
+
+	return setAdvancedPropertyIfChanged(execClient, adapterName, speedDuplexKeyword, registryValue)
+}
@@ -0,0 +1,41 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// cniStateFileKeys are the top-level keys of the CNI state file, one per manager persisting its state to it
+var cniStateFileKeys = []string{"Network"}
+
+// ValidateStateFile returns a descriptive error if the CNI state file at path is not valid JSON
+// or lacks the expected top-level keys
+func ValidateStateFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+
+	var state map[string]json.RawMessage
+	if err = json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("state file %s is not a valid JSON object: %w", path, err)
+	}
+
+	for _, key := range cniStateFileKeys {
+		value, ok := state[key]
+		if !ok {
+			return fmt.Errorf("state file %s has no %s key", path, key)
+		}
+
+		var object map[string]json.RawMessage
+		if err = json.Unmarshal(value, &object); err != nil || object == nil {
+			return fmt.Errorf("%s in state file %s is not a JSON object", key, path)
+		}
+	}
+
+	return nil
+}
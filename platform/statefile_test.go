@@ -0,0 +1,56 @@
+package platform
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateStateFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			content: `{
+	"Network": {
+		"Version": "v1.4.35",
+		"TimeStamp": "2026-10-15T09:41:12.0000000Z",
+		"ExternalInterfaces": {}
+	}
+}`,
+		},
+		{name: "corrupt json", content: `{"Network": {"Version": "v1.4.35"`, wantErr: true},
+		{name: "truncated", content: "", wantErr: true},
+		{name: "not an object", content: `["Network"]`, wantErr: true},
+		{name: "missing network key", content: `{"IPAM": {}}`, wantErr: true},
+		{name: "network not an object", content: `{"Network": null}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "azure-vnet.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			err := ValidateStateFile(path)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), path)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateStateFileMissing(t *testing.T) {
+	err := ValidateStateFile(filepath.Join(t.TempDir(), "azure-vnet.json"))
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TCP receive window auto-tuning levels
+const (
+	TCPAutoTuningDisabled         = "Disabled"
+	TCPAutoTuningHighlyRestricted = "HighlyRestricted"
+	TCPAutoTuningRestricted       = "Restricted"
+	TCPAutoTuningNormal           = "Normal"
+	TCPAutoTuningExperimental     = "Experimental"
+)
+
+// GetTCPGlobalSettingsCommand is the command to get the TCP settings applied to the connections of the host.
+// Enums are converted to their names, ConvertTo-Json would serialize them as numbers.
+const GetTCPGlobalSettingsCommand = "Get-NetTCPSetting -SettingName Internet | Select-Object SettingName, " +
+	"@{Name='AutoTuningLevelLocal'; Expression={$_.AutoTuningLevelLocal.ToString()}}, " +
+	"@{Name='CongestionProvider'; Expression={$_.CongestionProvider.ToString()}}, " +
+	"@{Name='EcnCapability'; Expression={$_.EcnCapability.ToString()}}, " +
+	"@{Name='Timestamps'; Expression={$_.Timestamps.ToString()}}, " +
+	"@{Name='ScalingHeuristics'; Expression={$_.ScalingHeuristics.ToString()}}, " +
+	"InitialCongestionWindowMss"
+
+// tcpAutoTuningLevels are the valid TCP receive window auto-tuning levels
+var tcpAutoTuningLevels = []string{
+	TCPAutoTuningDisabled,
+	TCPAutoTuningHighlyRestricted,
+	TCPAutoTuningRestricted,
+	TCPAutoTuningNormal,
+	TCPAutoTuningExperimental,
+}
+
+// TCPGlobalSettings are the TCP settings affecting the throughput of the connections of the host
+type TCPGlobalSettings struct {
+	SettingName                string
+	AutoTuningLevelLocal       string
+	CongestionProvider         string
+	EcnCapability              string
+	Timestamps                 string
+	ScalingHeuristics          string
+	InitialCongestionWindowMss int
+}
+
+// GetTCPGlobalSettings returns the TCP settings applied to the connections of the host
+func GetTCPGlobalSettings(execClient ExecClient) (TCPGlobalSettings, error) {
+	settings, err := ExecutePowershellJSON[TCPGlobalSettings](execClient, GetTCPGlobalSettingsCommand)
+	if err != nil {
+		return TCPGlobalSettings{}, fmt.Errorf("failed to get TCP settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// SetTCPAutoTuning sets the TCP receive window auto-tuning level of the host to one of
+// TCPAutoTuningDisabled, TCPAutoTuningHighlyRestricted, TCPAutoTuningRestricted, TCPAutoTuningNormal
+// or TCPAutoTuningExperimental
+func SetTCPAutoTuning(execClient ExecClient, level string) error {
+	valid := false
+	for _, l := range tcpAutoTuningLevels {
+		valid = valid || l == level
+	}
+
+	if !valid {
+		return fmt.Errorf("invalid TCP auto-tuning level %q, must be one of %v", level, tcpAutoTuningLevels)
+	}
+
+	// the settings of the built-in templates can only be changed through netsh
+	cmd := fmt.Sprintf("netsh interface tcp set global autotuninglevel=%s", strings.ToLower(level))
+	if _, err := execClient.ExecuteCommand(cmd); err != nil {
+		return fmt.Errorf("failed to set TCP auto-tuning level to %s: %w", level, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tcpGlobalSettingsFixture = `{
+    "SettingName":  "Internet",
+    "AutoTuningLevelLocal":  "Normal",
+    "CongestionProvider":  "CUBIC",
+    "EcnCapability":  "Disabled",
+    "Timestamps":  "Disabled",
+    "ScalingHeuristics":  "Disabled",
+    "InitialCongestionWindowMss":  10
+}`
+
+func TestGetTCPGlobalSettings(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(func(cmd string) (string, error) {
+		assert.Equal(t, GetTCPGlobalSettingsCommand+" | ConvertTo-Json -Depth 10", cmd)
+		return tcpGlobalSettingsFixture, nil
+	})
+
+	settings, err := GetTCPGlobalSettings(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, TCPGlobalSettings{
+		SettingName:                "Internet",
+		AutoTuningLevelLocal:       TCPAutoTuningNormal,
+		CongestionProvider:         "CUBIC",
+		EcnCapability:              "Disabled",
+		Timestamps:                 "Disabled",
+		ScalingHeuristics:          "Disabled",
+		InitialCongestionWindowMss: 10,
+	}, settings)
+
+	_, err = GetTCPGlobalSettings(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+}
+
+func TestSetTCPAutoTuning(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	require.NoError(t, SetTCPAutoTuning(mockExecClient, TCPAutoTuningHighlyRestricted))
+	require.NoError(t, SetTCPAutoTuning(mockExecClient, TCPAutoTuningNormal))
+	assert.Equal(t, []string{
+		"netsh interface tcp set global autotuninglevel=highlyrestricted",
+		"netsh interface tcp set global autotuninglevel=normal",
+	}, mockExecClient.RecordedCommands())
+
+	require.Error(t, SetTCPAutoTuning(mockExecClient, "Fast"))
+	require.Error(t, SetTCPAutoTuning(mockExecClient, "normal & shutdown /s"))
+	assert.Len(t, mockExecClient.RecordedCommands(), 2)
+
+	require.ErrorIs(t, SetTCPAutoTuning(NewMockExecClient(true), TCPAutoTuningNormal), ErrMockExec)
+}
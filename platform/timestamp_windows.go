@@ -0,0 +1,57 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+)
+
+// Registry keywords of the timestamping settings of an adapter
+const (
+	ptpHardwareTimestampKeyword = "*PtpHardwareTimestamp"
+	softwareTimestampKeyword    = "*SoftwareTimestamp"
+)
+
+// timestampDisabledValue is the registry value of a disabled timestamping setting
+const timestampDisabledValue = "0"
+
+// HWTimestampCaps is the packet timestamping capability of an adapter
+type HWTimestampCaps struct {
+	// HardwareSupported is whether the adapter can timestamp PTP packets in hardware
+	HardwareSupported bool
+	// HardwareEnabled is whether hardware timestamping of PTP packets is enabled
+	HardwareEnabled bool
+	// SoftwareSupported is whether the miniport driver can timestamp packets in software
+	SoftwareSupported bool
+	// SoftwareEnabled is whether software timestamping is enabled for received or sent packets
+	SoftwareEnabled bool
+}
+
+// GetHardwareTimestampCapability returns the packet timestamping capability of the adapter, needed by PTP.
+// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if it has no timestamping setting.
+func GetHardwareTimestampCapability(execClient ExecClient, adapterName string) (HWTimestampCaps, error) {
+	properties, err := getAdvancedProperties(execClient, adapterName, ptpHardwareTimestampKeyword, softwareTimestampKeyword)
+	if err != nil {
+		return HWTimestampCaps{}, err
+	}
+
+	var caps HWTimestampCaps
+	if property, ok := properties[ptpHardwareTimestampKeyword]; ok {
+		caps.HardwareSupported = true
+		caps.HardwareEnabled = property.value() != timestampDisabledValue
+	}
+
+	if property, ok := properties[softwareTimestampKeyword]; ok {
+		caps.SoftwareSupported = true
+		caps.SoftwareEnabled = property.value() != timestampDisabledValue
+	}
+
+	if !caps.HardwareSupported && !caps.SoftwareSupported {
+		return HWTimestampCaps{}, fmt.Errorf("timestamping on %s: %w", adapterName, adapter.ErrFeatureUnsupported)
+	}
+
+	return caps, nil
+}
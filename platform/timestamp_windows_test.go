@@ -0,0 +1,93 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/platform/windows/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	ptpHardwareTimestampFixture = `{
+    "RegistryKeyword":  "*PtpHardwareTimestamp",
+    "RegistryValue":  ["1"],
+    "DisplayValue":  "Enabled",
+    "ValidRegistryValues":  ["0", "1"],
+    "ValidDisplayValues":  ["Disabled", "Enabled"],
+    "NumericParameterMinValue":  null,
+    "NumericParameterMaxValue":  null
+}`
+	softwareTimestampFixture = `{
+    "RegistryKeyword":  "*SoftwareTimestamp",
+    "RegistryValue":  ["0"],
+    "DisplayValue":  "Disabled",
+    "ValidRegistryValues":  ["0", "1", "2", "3"],
+    "ValidDisplayValues":  ["Disabled", "RxAll", "TxAll", "RxAll & TxAll"],
+    "NumericParameterMinValue":  null,
+    "NumericParameterMaxValue":  null
+}`
+)
+
+// timestampResponder answers the powershell commands of an adapter with the given timestamping advanced properties
+func timestampResponder(properties string) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "Get-NetAdapterAdvancedProperty") {
+			return properties, nil
+		}
+		return adapterNamesResponder(cmd)
+	}
+}
+
+func TestGetHardwareTimestampCapability(t *testing.T) {
+	tests := []struct {
+		name         string
+		properties   string
+		expectedCaps HWTimestampCaps
+	}{
+		{
+			name:         "hardware and software",
+			properties:   "[" + ptpHardwareTimestampFixture + "," + softwareTimestampFixture + "]",
+			expectedCaps: HWTimestampCaps{HardwareSupported: true, HardwareEnabled: true, SoftwareSupported: true},
+		},
+		{
+			name:         "software only",
+			properties:   strings.Replace(softwareTimestampFixture, `["0"]`, `["3"]`, 1),
+			expectedCaps: HWTimestampCaps{SoftwareSupported: true, SoftwareEnabled: true},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecClient := NewMockExecClient(false)
+			mockExecClient.SetPowershellCommandResponder(timestampResponder(tt.properties))
+
+			caps, err := GetHardwareTimestampCapability(mockExecClient, "Ethernet 2")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCaps, caps)
+		})
+	}
+}
+
+func TestGetHardwareTimestampCapabilityUnsupported(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(timestampResponder(""))
+
+	_, err := GetHardwareTimestampCapability(mockExecClient, "Ethernet 2")
+	require.ErrorIs(t, err, adapter.ErrFeatureUnsupported)
+}
+
+func TestGetHardwareTimestampCapabilityError(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetPowershellCommandResponder(timestampResponder(ptpHardwareTimestampFixture))
+	_, err := GetHardwareTimestampCapability(mockExecClient, "Ethernet 3")
+	require.ErrorIs(t, err, adapter.ErrAdapterNotFound)
+
+	_, err = GetHardwareTimestampCapability(NewMockExecClient(true), "Ethernet 2")
+	require.ErrorIs(t, err, ErrMockExec)
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Command to get the time source of the host, output as is regardless of the display language
+	GetTimeSourceCommand = "w32tm /query /source"
+
+	// Command to get the time synchronization status of the host
+	GetTimeSyncStatusCommand = "w32tm /query /status /verbose"
+
+	// w32tmTimeLayout is the layout of the times reported by w32tm on en-US hosts
+	w32tmTimeLayout = "1/2/2006 3:04:05 PM"
+
+	// w32tmUnspecified is the value reported by w32tm on en-US hosts for a clock which never synchronized
+	w32tmUnspecified = "unspecified"
+)
+
+// Sources reported by w32tm when the clock is not synchronized with a time server
+var unsyncedTimeSources = []string{"Local CMOS Clock", "Free-running System Clock"}
+
+// TimeSyncStatus is the time synchronization status of the host
+type TimeSyncStatus struct {
+	// Source is the time source the clock is synchronized with
+	Source string
+	// LastSyncTime is the time of the last successful synchronization, zero if the clock never synchronized
+	// or the time could not be read
+	LastSyncTime time.Time
+	// Offset is the offset of the clock from the time source, zero if it could not be read
+	Offset time.Duration
+	// Synchronized is whether the clock is synchronized with a time server
+	Synchronized bool
+}
+
+// GetTimeSyncStatus returns the time synchronization status of the host as reported by w32tm.
+// The labels and time format of the status are those of the display language of the host, so the last sync time
+// and the offset are only read on en-US hosts, and left zero elsewhere. The source is read regardless.
+func GetTimeSyncStatus(execClient ExecClient) (TimeSyncStatus, error) {
+	source, err := execClient.ExecuteCommand(GetTimeSourceCommand)
+	if err != nil {
+		return TimeSyncStatus{}, fmt.Errorf("failed to query time source: %w", err)
+	}
+
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return TimeSyncStatus{}, fmt.Errorf("no time source in w32tm output %q", source)
+	}
+
+	out, err := execClient.ExecuteCommand(GetTimeSyncStatusCommand)
+	if err != nil {
+		return TimeSyncStatus{}, fmt.Errorf("failed to query time sync status: %w", err)
+	}
+
+	return parseTimeSyncStatus(source, out), nil
+}
+
+// parseTimeSyncStatus parses the output of w32tm /query /status /verbose of a host with the given time source.
+// Fields missing or in an unknown format, e.g. on a host with another display language than en-US, are left zero.
+func parseTimeSyncStatus(source, out string) TimeSyncStatus {
+	fields := map[string]string{}
+	for _, line := range splitPowershellLines(out) {
+		if key, value, found := strings.Cut(line, ":"); found {
+			fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	status := TimeSyncStatus{Source: source}
+
+	lastSync, neverSynced := fields["Last Successful Sync Time"], false
+	switch lastSync {
+	case "":
+		log.Printf("No last sync time in w32tm output, assuming a display language other than en-US")
+	case w32tmUnspecified:
+		neverSynced = true
+	default:
+		if t, err := time.ParseInLocation(w32tmTimeLayout, lastSync, time.Local); err == nil {
+			status.LastSyncTime = t
+		} else {
+			log.Printf("Failed to parse last sync time %q, ignoring it: %v", lastSync, err)
+		}
+	}
+
+	if offset := fields["Phase Offset"]; offset != "" {
+		if d, err := time.ParseDuration(offset); err == nil {
+			status.Offset = d
+		} else {
+			log.Printf("Failed to parse phase offset %q, ignoring it: %v", offset, err)
+		}
+	}
+
+	status.Synchronized = !neverSynced
+	for _, unsynced := range unsyncedTimeSources {
+		if status.Source == unsynced {
+			status.Synchronized = false
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,125 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const w32tmStatusFixture = `Leap Indicator: 0(no warning)
+Stratum: 4 (secondary reference - syncd by (S)NTP)
+Precision: -23 (119.209ns per tick)
+Root Delay: 0.0390600s
+Root Dispersion: 7.8200034s
+ReferenceId: 0xA9FEA9FE (source IP:  169.254.169.254)
+Last Successful Sync Time: 10/15/2026 9:41:12 AM
+Source: time.windows.com,0x8
+Poll Interval: 10 (1024s)
+
+Phase Offset: -0.0012345s
+ClockRate: 0.0156250s
+State Machine: 2 (Sync)
+Time Source Flags: 0 (None)
+Server Role: 0 (None)
+Last Sync Error: 0 (The command completed successfully.)
+Time since Last Good Sync Time: 123.4567890s
+`
+
+const w32tmUnsyncedFixture = `Leap Indicator: 3(not synchronized)
+Stratum: 0 (unspecified)
+Precision: -23 (119.209ns per tick)
+Root Delay: 0.0000000s
+Root Dispersion: 0.0000000s
+ReferenceId: 0x00000000 (unspecified)
+Last Successful Sync Time: unspecified
+Source: Local CMOS Clock
+Poll Interval: 10 (1024s)
+
+Phase Offset: 0.0000000s
+`
+
+// w32tmStatusGermanFixture is w32tmStatusFixture on a host whose display language is German
+const w32tmStatusGermanFixture = `Sprungindikator: 0(keine Warnung)
+Stratum: 4 (Sekundärreferenz - synchr. über (S)NTP)
+Präzision: -23 (119.209ns pro Tick)
+Letzte erfolgreiche Synchronisierungszeit: 15.10.2026 09:41:12
+Quelle: time.windows.com,0x8
+Abrufintervall: 10 (1024s)
+
+Phasendifferenz: -0.0012345s
+`
+
+// w32tmResponder answers the w32tm commands of a host with the given time source and status
+func w32tmResponder(source, status string) func(string) (string, error) {
+	return func(cmd string) (string, error) {
+		if cmd == GetTimeSourceCommand {
+			return source + "\r\n", nil
+		}
+		return status, nil
+	}
+}
+
+func TestGetTimeSyncStatus(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(w32tmResponder("time.windows.com,0x8", w32tmStatusFixture))
+
+	status, err := GetTimeSyncStatus(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, TimeSyncStatus{
+		Source:       "time.windows.com,0x8",
+		LastSyncTime: time.Date(2026, 10, 15, 9, 41, 12, 0, time.Local),
+		Offset:       -1234500 * time.Nanosecond,
+		Synchronized: true,
+	}, status)
+	assert.Equal(t, []string{GetTimeSourceCommand, GetTimeSyncStatusCommand}, mockExecClient.RecordedCommands())
+}
+
+func TestGetTimeSyncStatusUnsynced(t *testing.T) {
+	status := parseTimeSyncStatus("Local CMOS Clock", w32tmUnsyncedFixture)
+	assert.Equal(t, "Local CMOS Clock", status.Source)
+	assert.True(t, status.LastSyncTime.IsZero())
+	assert.False(t, status.Synchronized)
+
+	// never synchronized with a time server configured
+	status = parseTimeSyncStatus("time.windows.com,0x8", w32tmUnsyncedFixture)
+	assert.False(t, status.Synchronized)
+}
+
+func TestGetTimeSyncStatusOtherLanguage(t *testing.T) {
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(w32tmResponder("time.windows.com,0x8", w32tmStatusGermanFixture))
+
+	status, err := GetTimeSyncStatus(mockExecClient)
+	require.NoError(t, err)
+	assert.Equal(t, TimeSyncStatus{Source: "time.windows.com,0x8", Synchronized: true}, status)
+
+	// a time in another format than en-US is ignored
+	status = parseTimeSyncStatus("time.windows.com,0x8", "Last Successful Sync Time: 15/10/2026 09:41:12\nPhase Offset: 0,5s")
+	assert.Equal(t, TimeSyncStatus{Source: "time.windows.com,0x8", Synchronized: true}, status)
+}
+
+func TestGetTimeSyncStatusError(t *testing.T) {
+	_, err := GetTimeSyncStatus(NewMockExecClient(true))
+	require.ErrorIs(t, err, ErrMockExec)
+
+	mockExecClient := NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(w32tmResponder("", w32tmStatusFixture))
+	_, err = GetTimeSyncStatus(mockExecClient)
+	require.Error(t, err)
+	assert.Equal(t, []string{GetTimeSourceCommand}, mockExecClient.RecordedCommands())
+
+	mockExecClient = NewMockExecClient(false)
+	mockExecClient.SetCommandResponder(func(cmd string) (string, error) {
+		if cmd == GetTimeSyncStatusCommand {
+			return "", ErrMockExec
+		}
+		return "time.windows.com,0x8", nil
+	})
+	_, err = GetTimeSyncStatus(mockExecClient)
+	require.ErrorIs(t, err, ErrMockExec)
+}
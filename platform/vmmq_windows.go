@@ -0,0 +1,47 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// vmmqKeyword is the registry keyword of the Virtual Machine Multi-Queue setting of an adapter,
+	// i.e. RSS on the host virtual ports of the virtual switch
+	vmmqKeyword = "*RssOnHostVPorts"
+
+	// vmmqQueuePairsKeyword is the registry keyword of the number of queue pairs of the default virtual port of an adapter
+	vmmqQueuePairsKeyword = "*NumQueuePairsForDefaultVPort"
+)
+
+// vmmqRegistryValues are the registry values of the VMMQ setting of an adapter, by whether VMMQ is enabled
+var vmmqRegistryValues = map[bool]string{
+	false: "0",
+	true:  "1",
+}
+
+// GetVMMQEnabled returns whether Virtual Machine Multi-Queue is enabled on the adapter.
+// Returns ErrFeatureUnsupported if the adapter has no VMMQ setting.
+func GetVMMQEnabled(execClient ExecClient, adapterName string) (bool, error) {
+	return getEnumAdvancedProperty(execClient, adapterName, vmmqKeyword, vmmqRegistryValues)
+}
+
+// SetVMMQEnabled enables or disables Virtual Machine Multi-Queue on the adapter.
+// Returns ErrFeatureUnsupported if the adapter has no VMMQ setting.
+func SetVMMQEnabled(execClient ExecClient, adapterName string, enabled bool) error {
+	return setEnumAdvancedProperty(execClient, adapterName, vmmqKeyword, vmmqRegistryValues, enabled)
+}
+
+// SetVMMQQueuePairs sets the number of send and receive queue pairs of the default virtual port of the adapter,
+// which must be supported by the adapter.
+// Returns ErrFeatureUnsupported if the adapter has no such setting.
+func SetVMMQQueuePairs(execClient ExecClient, adapterName string, pairs int) error {
+	if pairs < 1 {
+		return fmt.Errorf("invalid number of VMMQ queue pairs %d", pairs)
+	}
+
+	return setAdvancedPropertyIfChanged(execClient, adapterName, vmmqQueuePairsKeyword, strconv.Itoa(pairs))
+}
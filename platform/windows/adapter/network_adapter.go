@@ -0,0 +1,45 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package adapter
+
+import "errors"
+
+var (
+	// ErrFeatureUnsupported is returned when the adapter does not support the requested feature
+	ErrFeatureUnsupported = errors.New("feature not supported by the network adapter")
+	// ErrNoAdaptersFound is returned by GetAdapterNames when the host has no network adapters
+	ErrNoAdaptersFound = errors.New("no network adapters found")
+	// ErrAdapterNotFound is returned when the host has no adapter with the given name
+	ErrAdapterNotFound = errors.New("network adapter not found")
+)
+
+// RSCSettings are the Receive Segment Coalescing settings of an adapter, per address family
+type RSCSettings struct {
+	IPv4Enabled bool
+	IPv6Enabled bool
+}
+
+// NetworkAdapter is the set of operations on the network adapters of the host
+type NetworkAdapter interface {
+	// GetAdapterNames returns the names of the network adapters of the host.
+	// Must return ErrNoAdaptersFound if no adapters are found.
+	GetAdapterNames() ([]string, error)
+	// AdapterExists returns whether the host has an adapter with the given name.
+	AdapterExists(adapterName string) (bool, error)
+	// GetRSCSettings returns whether Receive Segment Coalescing is enabled for IPv4 and for IPv6.
+	// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if the adapter does not support RSC.
+	GetRSCSettings(adapterName string) (RSCSettings, error)
+	// SetRSCSettings enables or disables Receive Segment Coalescing for IPv4 and for IPv6.
+	// Returns ErrAdapterNotFound if there is no such adapter, ErrFeatureUnsupported if the adapter does not support RSC.
+	SetRSCSettings(adapterName string, settings RSCSettings) error
+	// GetSRIOVEnabled returns whether SR-IOV (accelerated networking) is enabled on the adapter.
+	// Returns ErrFeatureUnsupported if the adapter does not support SR-IOV.
+	GetSRIOVEnabled(adapterName string) (bool, error)
+	// EnableAdapter administratively enables the adapter.
+	// Returns ErrAdapterNotFound if there is no such adapter.
+	EnableAdapter(adapterName string) error
+	// DisableAdapter administratively disables the adapter.
+	// Returns ErrAdapterNotFound if there is no such adapter.
+	DisableAdapter(adapterName string) error
+}
@@ -28,16 +28,28 @@ const (
 
 // jsonFileStore is an implementation of KeyValueStore using a local JSON file.
 type jsonFileStore struct {
-	fileName    string
-	data        map[string]*json.RawMessage
-	inSync      bool
-	processLock processlock.Interface
+	fileName         string
+	data             map[string]*json.RawMessage
+	inSync           bool
+	processLock      processlock.Interface
+	minFreeDiskSpace uint64
 	sync.Mutex
 }
 
+// JSONFileStoreOption configures a jsonFileStore.
+type JSONFileStoreOption func(*jsonFileStore)
+
+// WithMinFreeDiskSpace makes the store fail to flush with platform.ErrInsufficientDiskSpace, without touching
+// the file, when less than bytes would remain available on its volume after writing it.
+func WithMinFreeDiskSpace(bytes uint64) JSONFileStoreOption {
+	return func(kvs *jsonFileStore) {
+		kvs.minFreeDiskSpace = bytes
+	}
+}
+
 //nolint:revive // ignoring name change
 // NewJsonFileStore creates a new jsonFileStore object, accessed as a KeyValueStore.
-func NewJsonFileStore(fileName string, lockclient processlock.Interface) (KeyValueStore, error) {
+func NewJsonFileStore(fileName string, lockclient processlock.Interface, opts ...JSONFileStoreOption) (KeyValueStore, error) {
 	if fileName == "" {
 		return &jsonFileStore{}, errors.New("need to pass in a json file path")
 	}
@@ -47,6 +59,10 @@ func NewJsonFileStore(fileName string, lockclient processlock.Interface) (KeyVal
 		data:        make(map[string]*json.RawMessage),
 	}
 
+	for _, opt := range opts {
+		opt(kvs)
+	}
+
 	return kvs, nil
 }
 
@@ -136,6 +152,12 @@ func (kvs *jsonFileStore) flush() error {
 		dir = "."
 	}
 
+	if kvs.minFreeDiskSpace > 0 {
+		if err = platform.CheckFreeDiskSpace(dir, kvs.minFreeDiskSpace+uint64(len(buf))); err != nil {
+			return fmt.Errorf("cannot write state file %s: %w", kvs.fileName, err)
+		}
+	}
+
 	f, err := os.CreateTemp(dir, file)
 	if err != nil {
 		return fmt.Errorf("cannot create temp file: %v", err)
@@ -4,11 +4,14 @@
 package store
 
 import (
+	"math"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/Azure/azure-container-networking/platform"
 	"github.com/Azure/azure-container-networking/processlock"
 	"github.com/stretchr/testify/require"
 )
@@ -233,3 +236,18 @@ func TestFileName(t *testing.T) {
 		t.Fatalf("This should not fail for a non-empty file %v", err)
 	}
 }
+
+// Tests that the store is not written when its volume lacks the configured free space.
+func TestMinFreeDiskSpace(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), testFileName)
+
+	kvs, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false), WithMinFreeDiskSpace(math.MaxUint64/2))
+	require.NoError(t, err)
+	require.ErrorIs(t, kvs.Write(testKey1, &testType1{"test", 42}), platform.ErrInsufficientDiskSpace)
+	require.False(t, kvs.Exists())
+
+	kvs, err = NewJsonFileStore(fileName, processlock.NewMockFileLock(false), WithMinFreeDiskSpace(1))
+	require.NoError(t, err)
+	require.NoError(t, kvs.Write(testKey1, &testType1{"test", 42}))
+	require.True(t, kvs.Exists())
+}